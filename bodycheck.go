@@ -0,0 +1,1121 @@
+package pas
+
+import "strings"
+
+// typeKind is what the checks of routine bodies know about a type.
+type typeKind int
+
+const (
+	// unknownType is any type that the checks do not know, e.g. enumerations,
+	// sets, pointers and the types of undeclared names. Expressions of unknown
+	// type are compatible with everything.
+	unknownType typeKind = iota
+	integerType
+	realType
+	stringType
+	charType
+	booleanType
+	variantType
+	nilType
+	// The scope of an exprType of these kinds is the class, interface or
+	// record.
+	classType
+	interfaceType
+	recordType
+)
+
+// predeclaredKinds are the kinds of the predeclared types, by folded name.
+var predeclaredKinds = map[string]typeKind{
+	"boolean": booleanType, "bytebool": booleanType, "wordbool": booleanType,
+	"longbool": booleanType,
+	"shortint": integerType, "smallint": integerType, "integer": integerType,
+	"longint": integerType, "int64": integerType, "nativeint": integerType,
+	"byte": integerType, "word": integerType, "cardinal": integerType,
+	"longword": integerType, "uint64": integerType, "nativeuint": integerType,
+	"int8": integerType, "int16": integerType, "int32": integerType,
+	"uint8": integerType, "uint16": integerType, "uint32": integerType,
+	"single": realType, "double": realType, "extended": realType,
+	"real": realType, "currency": realType, "comp": realType, "tdatetime": realType,
+	"char": charType, "ansichar": charType, "widechar": charType,
+	"string": stringType, "ansistring": stringType, "widestring": stringType,
+	"unicodestring": stringType, "shortstring": stringType,
+	"rawbytestring": stringType, "utf8string": stringType,
+	"variant": variantType, "olevariant": variantType,
+}
+
+// predeclaredNames are the routines, constants and variables of the System
+// unit that bodies use, by folded name. They are never undeclared.
+var predeclaredNames = map[string]bool{
+	"abs": true, "addr": true, "append": true, "arctan": true, "assert": true,
+	"assign": true, "assigned": true, "assignfile": true, "blockread": true,
+	"blockwrite": true, "break": true, "chr": true, "close": true,
+	"closefile": true, "cmdline": true, "concat": true, "continue": true,
+	"copy": true, "cos": true, "dec": true, "default": true, "delete": true,
+	"dispose": true, "eof": true, "eoln": true, "erase": true,
+	"erroutput": true, "exceptaddr": true, "exceptobject": true,
+	"exclude": true, "exit": true, "exp": true, "false": true,
+	"filemode": true, "filepos": true, "filesize": true, "fillchar": true,
+	"finalize": true, "flush": true, "frac": true, "freemem": true,
+	"getmem": true, "gettypekind": true, "halt": true, "hasweakref": true,
+	"hi": true, "high": true, "hinstance": true, "inc": true,
+	"include": true, "initialize": true, "input": true, "insert": true,
+	"int": true, "ioresult": true, "isconsole": true,
+	"ismanagedtype": true, "ismultithread": true, "length": true, "ln": true,
+	"lo": true, "low": true, "maininstance": true, "maxint": true,
+	"maxlongint": true, "move": true, "new": true, "odd": true, "ord": true,
+	"output": true, "paramcount": true, "paramstr": true, "pi": true,
+	"pos": true, "pred": true, "ptr": true, "random": true,
+	"randomize": true, "randseed": true, "read": true, "readln": true,
+	"reallocmem": true, "rename": true, "reset": true, "result": true,
+	"rewrite": true, "round": true, "seek": true, "self": true,
+	"setlength": true, "setstring": true, "sin": true, "sizeof": true,
+	"slice": true, "sqr": true, "sqrt": true, "str": true, "succ": true,
+	"swap": true, "true": true, "trunc": true, "truncate": true,
+	"typehandle": true, "typeinfo": true, "typeof": true,
+	"uniquestring": true, "upcase": true, "val": true, "write": true,
+	"writeln": true,
+}
+
+// objectMembers are the members of TObject, TInterfacedObject and IInterface,
+// which the classes and interfaces of the files do not declare, by folded name.
+var objectMembers = map[string]bool{
+	"afterconstruction": true, "beforedestruction": true,
+	"classinfo": true, "classname": true, "classnameis": true,
+	"classparent": true, "classtype": true, "cleanupinstance": true,
+	"create": true, "defaulthandler": true, "destroy": true,
+	"dispatch": true, "disposeof": true, "equals": true,
+	"fieldaddress": true, "free": true, "freeinstance": true,
+	"gethashcode": true, "getinterface": true, "inheritsfrom": true,
+	"initinstance": true, "instancesize": true, "methodaddress": true,
+	"methodname": true, "newinstance": true, "queryinterface": true,
+	"refcount": true, "safecallexception": true, "tostring": true,
+	"unitname": true, "_addref": true, "_release": true,
+}
+
+// exprType is the type of an expression in a routine body.
+type exprType struct {
+	kind typeKind
+	// name is the type as it is written in error messages, e.g. "Integer".
+	name  string
+	scope *Scope
+	// isType is set for the names of types, like TFoo in "TFoo.Create".
+	isType bool
+	// variable is set for expressions that can be passed to var and out
+	// parameters.
+	variable bool
+	// readOnly is set for constants, const parameters and properties without
+	// write specifier, which cannot be assigned to.
+	readOnly bool
+}
+
+// unknown is an expression of unknown type. It is a variable, since it might
+// be one.
+var unknown = exprType{variable: true}
+
+// argument is an actual parameter of a call.
+type argument struct {
+	typ exprType
+	pos Position
+}
+
+// bodyChecker checks the block of a routine.
+type bodyChecker struct {
+	info    *Info
+	routine *Scope
+	tokens  []Token
+	// declared are the folded names of the inline variables and constants and
+	// of the exception variables in "on E: Exception do".
+	declared map[string]bool
+	refs     []Reference
+	errs     []*TypeError
+}
+
+// checkBody checks the expressions, assignments and calls in the block of the
+// routine scope and returns the references to the names in it and the errors.
+// Nested routines have their own scopes and are checked with them.
+func (info *Info) checkBody(routine *Scope) ([]Reference, []*TypeError) {
+	r := routine.routine
+	s := NewScanner(r.Body)
+	s.SkipWhiteSpace = true
+	s.SkipComments = true
+	s.tokens.filename = r.BodyPos.Filename
+	s.tokens.offset = r.BodyPos.Offset
+	s.tokens.line = r.BodyPos.Line
+	s.tokens.col = r.BodyPos.Col
+	c := &bodyChecker{
+		info:     info,
+		routine:  routine,
+		declared: make(map[string]bool),
+	}
+	for t := s.Scan(); t.Kind != EOFToken; t = s.Scan() {
+		if t.Pos.Offset < routine.block.Offset {
+			continue
+		}
+		// The symbols of the two character operators are separate tokens.
+		if n := len(c.tokens); n > 0 && c.tokens[n-1].End == t.Pos {
+			switch c.tokens[n-1].Text + t.Text {
+			case ":=", "<>", "<=", ">=", "..":
+				c.tokens[n-1].Text += t.Text
+				c.tokens[n-1].End = t.End
+				continue
+			}
+		}
+		c.tokens = append(c.tokens, t)
+	}
+	for i := 0; i < len(c.tokens); {
+		i = c.statementPart(i)
+	}
+	return c.refs, c.errs
+}
+
+func (c *bodyChecker) report(pos Position, msg string) {
+	c.errs = append(c.errs, &TypeError{Pos: pos, Msg: msg})
+}
+
+// reference records the reference to sym, which is nil if the name is not
+// declared, in the scope. The name starts at pos and ends at end.
+func (c *bodyChecker) reference(name string, sym *Symbol, in *Scope, pos, end Position) {
+	c.refs = append(c.refs, Reference{
+		Name:   name,
+		Node:   *c.routine.routine,
+		Scope:  in,
+		Symbol: sym,
+		Pos:    pos,
+		End:    end,
+		value:  true,
+	})
+}
+
+// text returns the text of the i'th token or the empty string at the end.
+func (c *bodyChecker) text(i int) string {
+	if i < len(c.tokens) {
+		return c.tokens[i].Text
+	}
+	return ""
+}
+
+func (c *bodyChecker) word(i int) string {
+	if i < len(c.tokens) && c.tokens[i].Kind == WordToken {
+		return FoldIdentifier(c.tokens[i].Text)
+	}
+	return ""
+}
+
+// statementPart checks the part of a statement at tokens[i], i.e. a keyword or
+// an expression with an optional assignment, and returns the index after it.
+func (c *bodyChecker) statementPart(i int) int {
+	t := c.tokens[i]
+	if t.Kind == WordToken {
+		switch word := c.word(i); word {
+		case "asm":
+			return c.skipBlock(i)
+		case "procedure", "function":
+			// Anonymous methods are skipped.
+			for i < len(c.tokens) && c.word(i) != "begin" && c.word(i) != "asm" {
+				i++
+			}
+			return c.skipBlock(i)
+		case "var", "const":
+			return c.inlineDeclaration(i + 1)
+		case "on":
+			if c.text(i+2) == ":" {
+				c.declared[c.word(i+1)] = true
+				return i + 3
+			}
+			return i + 1
+		case "goto":
+			return i + 2
+		case "raise":
+			_, j := c.expression(i + 1)
+			if c.word(j) == "at" {
+				j++
+			}
+			return j
+		case "if", "while", "until":
+			typ, j := c.expression(i + 1)
+			if j == i+1 {
+				return i + 1
+			}
+			if typ.kind != unknownType && typ.kind != booleanType &&
+				typ.kind != variantType && !typ.isType {
+				c.report(c.tokens[i+1].Pos, `condition of type "`+typ.name+`" is not Boolean`)
+			}
+			return j
+		default:
+			if IsReserved(word) && word != "not" && word != "nil" && word != "inherited" {
+				return i + 1
+			}
+		}
+	}
+	left, j := c.expression(i)
+	if j == i {
+		return i + 1
+	}
+	if c.text(j) == ":=" {
+		right, k := c.expression(j + 1)
+		if k == j+1 {
+			return k
+		}
+		c.assign(left, right, c.tokens[i].Pos)
+		return k
+	}
+	return j
+}
+
+// skipBlock returns the index after the "end" of the block that starts at
+// tokens[i].
+func (c *bodyChecker) skipBlock(i int) int {
+	depth := 0
+	for ; i < len(c.tokens); i++ {
+		switch c.word(i) {
+		case "begin", "asm", "case", "try", "record":
+			depth++
+		case "end":
+			depth--
+			if depth <= 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+// inlineDeclaration declares the names of an inline var or const declaration
+// starting at tokens[i] and returns the index of its value, if any.
+func (c *bodyChecker) inlineDeclaration(i int) int {
+	for c.word(i) != "" {
+		c.declared[c.word(i)] = true
+		i++
+		if c.text(i) != "," {
+			break
+		}
+		i++
+	}
+	for i < len(c.tokens) && c.text(i) != ":=" && c.text(i) != ";" && c.text(i) != "=" &&
+		c.word(i) != "in" {
+		i++
+	}
+	return i
+}
+
+// assign checks the assignment of right to left, at pos.
+func (c *bodyChecker) assign(left, right exprType, pos Position) {
+	if left.readOnly {
+		c.report(pos, "left side cannot be assigned to")
+	} else if !assignable(left, right) {
+		c.report(pos, `incompatible types "`+left.name+`" and "`+right.name+`"`)
+	}
+}
+
+// assignable reports whether a value of type from can be assigned to a
+// variable of type to. Types that are not known are assignable.
+func assignable(to, from exprType) bool {
+	if to.isType || from.isType || to.kind == unknownType || from.kind == unknownType ||
+		to.kind == variantType || from.kind == variantType {
+		return true
+	}
+	switch to.kind {
+	case integerType, charType, booleanType:
+		return from.kind == to.kind
+	case realType:
+		return from.kind == integerType || from.kind == realType
+	case stringType:
+		return from.kind == stringType || from.kind == charType
+	case classType, interfaceType:
+		if from.kind == nilType {
+			return true
+		}
+		if from.kind != classType && from.kind != interfaceType {
+			return false
+		}
+		return from.scope == to.scope || inheritsFrom(from.scope, to.scope, nil) ||
+			!knownHierarchy(from.scope, nil)
+	case recordType:
+		return from.kind == recordType && from.scope == to.scope
+	}
+	return true
+}
+
+// knownHierarchy reports whether all super classes of the class are declared,
+// directly and indirectly. The predeclared classes do not inherit from the
+// classes of the files, they count as declared.
+func knownHierarchy(class *Scope, visited map[*Scope]bool) bool {
+	if visited == nil {
+		visited = make(map[*Scope]bool)
+	}
+	visited[class] = true
+	if class.Parent != nil {
+		for _, sym := range class.Parent.Symbols {
+			if sym.Scope != class {
+				continue
+			}
+			if d, ok := sym.Decl.(Class); ok {
+				for _, super := range d.SuperClasses {
+					if class.Parent.Lookup(super) == nil {
+						return false
+					}
+				}
+			}
+		}
+	}
+	for _, base := range class.bases {
+		if !visited[base] && !knownHierarchy(base, visited) {
+			return false
+		}
+	}
+	return true
+}
+
+// expression parses the expression at tokens[i] and returns its type and the
+// index after it. If there is no expression at i, it returns i.
+func (c *bodyChecker) expression(i int) (exprType, int) {
+	left, j := c.simpleExpression(i)
+	if j == i {
+		return unknown, i
+	}
+	for {
+		op := c.text(j)
+		switch op {
+		case "=", "<>", "<", ">", "<=", ">=":
+		default:
+			switch op = c.word(j); op {
+			case "in", "is":
+			default:
+				return left, j
+			}
+		}
+		right, k := c.simpleExpression(j + 1)
+		if k == j+1 {
+			return unknown, j
+		}
+		// Generic types like TList<Integer> look like comparisons, so only
+		// comparisons of values of known types are Boolean.
+		if op == "in" || op == "is" || left.kind != unknownType && right.kind != unknownType &&
+			!left.isType && !right.isType {
+			left = exprType{kind: booleanType, name: "Boolean"}
+		} else {
+			left = exprType{}
+		}
+		j = k
+	}
+}
+
+func (c *bodyChecker) simpleExpression(i int) (exprType, int) {
+	left, j := c.term(i)
+	if j == i {
+		return unknown, i
+	}
+	for {
+		op := c.text(j)
+		switch op {
+		case "+", "-":
+		default:
+			switch op = c.word(j); op {
+			case "or", "xor":
+			default:
+				return left, j
+			}
+		}
+		right, k := c.term(j + 1)
+		if k == j+1 {
+			return unknown, j
+		}
+		left = operation(op, left, right)
+		j = k
+	}
+}
+
+func (c *bodyChecker) term(i int) (exprType, int) {
+	left, j := c.factor(i)
+	if j == i {
+		return unknown, i
+	}
+	for {
+		op := c.text(j)
+		switch op {
+		case "*", "/":
+		default:
+			switch op = c.word(j); op {
+			case "div", "mod", "and", "shl", "shr", "as":
+			default:
+				return left, j
+			}
+		}
+		right, k := c.factor(j + 1)
+		if k == j+1 {
+			return unknown, j
+		}
+		if op == "as" {
+			left = exprType{}
+			if right.isType {
+				left = right
+				left.isType = false
+			}
+		} else {
+			left = operation(op, left, right)
+		}
+		j = k
+	}
+}
+
+// operation returns the type of the result of a binary operator. Operators on
+// types that are not known and overloaded operators have a result of unknown
+// type.
+func operation(op string, left, right exprType) exprType {
+	integer := exprType{kind: integerType, name: "Integer"}
+	real := exprType{kind: realType, name: "Extended"}
+	numeric := (left.kind == integerType || left.kind == realType) &&
+		(right.kind == integerType || right.kind == realType)
+	both := func(kind typeKind) bool { return left.kind == kind && right.kind == kind }
+	switch op {
+	case "+", "-", "*":
+		if both(integerType) {
+			return integer
+		}
+		if numeric {
+			return real
+		}
+		if op == "+" && (left.kind == stringType || left.kind == charType) &&
+			(right.kind == stringType || right.kind == charType) {
+			return exprType{kind: stringType, name: "string"}
+		}
+	case "/":
+		if numeric {
+			return real
+		}
+	case "div", "mod", "shl", "shr":
+		if both(integerType) {
+			return integer
+		}
+	case "and", "or", "xor":
+		if both(integerType) {
+			return integer
+		}
+		if both(booleanType) {
+			return exprType{kind: booleanType, name: "Boolean"}
+		}
+	}
+	return exprType{}
+}
+
+func (c *bodyChecker) factor(i int) (exprType, int) {
+	if i >= len(c.tokens) {
+		return unknown, i
+	}
+	t := c.tokens[i]
+	switch t.Kind {
+	case NumberToken:
+		if !strings.HasPrefix(t.Text, "$") && strings.ContainsAny(t.Text, ".eE") {
+			return exprType{kind: realType, name: "Extended"}, i + 1
+		}
+		return exprType{kind: integerType, name: "Integer"}, i + 1
+	case StringToken:
+		if stringLength(t.Text) == 1 {
+			return exprType{kind: charType, name: "Char"}, i + 1
+		}
+		return exprType{kind: stringType, name: "string"}, i + 1
+	case SymbolToken:
+		switch t.Text {
+		case "(":
+			typ, j := c.expression(i + 1)
+			for c.text(j) == "," {
+				_, j = c.expression(j + 1)
+			}
+			if c.text(j) != ")" {
+				return exprType{}, j
+			}
+			typ.variable = false
+			typ.readOnly = false
+			return c.selectors(typ, j+1)
+		case "[":
+			j := i + 1
+			for j < len(c.tokens) && c.text(j) != "]" {
+				_, k := c.expression(j)
+				if c.text(k) == ".." {
+					_, k = c.expression(k + 1)
+				}
+				if c.text(k) != "," {
+					j = k
+					break
+				}
+				j = k + 1
+			}
+			if c.text(j) == "]" {
+				j++
+			}
+			return exprType{}, j
+		case "@":
+			_, j := c.factor(i + 1)
+			return exprType{}, j
+		case "-", "+":
+			typ, j := c.factor(i + 1)
+			if j == i+1 {
+				return unknown, i
+			}
+			if typ.kind != integerType && typ.kind != realType {
+				typ = exprType{}
+			}
+			typ.variable = false
+			typ.readOnly = false
+			return typ, j
+		}
+		return unknown, i
+	case WordToken:
+		switch c.word(i) {
+		case "not":
+			typ, j := c.factor(i + 1)
+			if j == i+1 {
+				return unknown, i
+			}
+			if typ.kind != integerType && typ.kind != booleanType {
+				typ = exprType{}
+			}
+			typ.variable = false
+			typ.readOnly = false
+			return typ, j
+		case "nil":
+			return exprType{kind: nilType, name: "nil"}, i + 1
+		case "inherited":
+			// The inherited method might be declared in a super class that
+			// is not known, so it is not checked.
+			j := i + 1
+			if c.word(j) != "" && !IsReserved(c.word(j)) {
+				j++
+				if c.text(j) == "(" {
+					_, j = c.arguments(j)
+				}
+			}
+			return c.selectors(exprType{}, j)
+		}
+		if IsReserved(t.Text) {
+			return unknown, i
+		}
+		return c.designator(i)
+	}
+	return unknown, i
+}
+
+// stringLength returns the number of characters of a string literal like
+// 'It”s'#13#10.
+func stringLength(literal string) int {
+	n := 0
+	for i := 0; i < len(literal); {
+		if literal[i] == '#' {
+			i++
+			for i < len(literal) && literal[i] != '#' && literal[i] != '\'' {
+				i++
+			}
+			n++
+			continue
+		}
+		// A quoted part, '' is a quote.
+		i++
+		for i < len(literal) {
+			if literal[i] == '\'' {
+				if i+1 < len(literal) && literal[i+1] == '\'' {
+					n++
+					i += 2
+					continue
+				}
+				i++
+				break
+			}
+			if literal[i] < 0x80 || literal[i] >= 0xC0 {
+				n++
+			}
+			i++
+		}
+	}
+	return n
+}
+
+// designator parses a name with its selectors, like "A.B[1].C(2)".
+func (c *bodyChecker) designator(i int) (exprType, int) {
+	t := c.tokens[i]
+	name := strings.TrimPrefix(t.Text, "&")
+	folded := FoldIdentifier(name)
+	scope := c.info.ScopeAt(c.routine.File, t.Pos.Offset)
+	j := i + 1
+	if c.declared[folded] {
+		return c.selectors(unknown, j)
+	}
+	if folded == "result" {
+		for s := scope; s != nil; s = s.Parent {
+			if s.Kind == RoutineScope && s.routine != nil && s.routine.Header.Returns != "" {
+				typ := typeOf(s, s.routine.Header.Returns)
+				typ.variable = true
+				return c.selectors(typ, j)
+			}
+		}
+	}
+	if folded == "self" {
+		for s := scope; s != nil; s = s.Parent {
+			if s.Kind == RoutineScope && len(s.bases) > 0 {
+				typ := instanceOf(s.bases[0])
+				typ.variable = true
+				return c.selectors(typ, j)
+			}
+		}
+	}
+
+	// A name qualified with a unit, like SysUtils.IntToStr, is one symbol.
+	sym := scope.Lookup(name)
+	qualified := name
+	for k := j; sym == nil && c.text(k) == "." && c.word(k+1) != ""; k += 2 {
+		if scope.unit(qualified) != nil {
+			if s := scope.Lookup(qualified + "." + c.tokens[k+1].Text); s != nil {
+				sym, name, j = s, qualified+"."+c.tokens[k+1].Text, k+2
+			}
+			break
+		}
+		qualified += "." + c.tokens[k+1].Text
+	}
+	if sym == nil {
+		if folded == "true" || folded == "false" {
+			return exprType{kind: booleanType, name: "Boolean"}, j
+		}
+		if !predeclaredNames[folded] && !objectMembers[folded] {
+			c.reference(name, nil, scope, t.Pos, t.End)
+			if c.mayBeUndeclared(scope) {
+				c.report(t.Pos, `undeclared identifier "`+name+`"`)
+			}
+		}
+		if c.text(j) == "(" {
+			_, j = c.arguments(j)
+		}
+		return c.selectors(unknown, j)
+	}
+	typ, j := c.symbol(sym, name, exprType{}, scope, t.Pos, j)
+	return c.selectors(typ, j)
+}
+
+// mayBeUndeclared reports whether a name that is not found in the scope is
+// undeclared. It might be declared in a unit that was not found, in a super
+// class that is not known or in the type of a with expression that is not
+// known.
+func (c *bodyChecker) mayBeUndeclared(scope *Scope) bool {
+	if scope.hasUnresolvedUses() {
+		return false
+	}
+	for s := scope; s != nil; s = s.Parent {
+		if s.Kind == WithScope && len(s.bases) == 0 {
+			return false
+		}
+		for _, base := range s.bases {
+			if !knownHierarchy(base, nil) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// symbol returns the type of the symbol with the name at pos, followed by
+// tokens[j], and records the reference to it. The name was looked up in the
+// scope in. For methods, routines and array properties, it checks their
+// arguments. of is the type of the expression in front of a member, e.g. of A
+// in "A.B", it is zero for names that are not members.
+func (c *bodyChecker) symbol(sym *Symbol, name string, of exprType, in *Scope, pos Position, j int) (exprType, int) {
+	end := c.tokens[j-1].End
+	if sym.Kind != MethodSymbol && sym.Kind != RoutineSymbol {
+		c.reference(name, sym, in, pos, end)
+	}
+	switch sym.Kind {
+	case ClassSymbol, InterfaceSymbol, RecordSymbol, TypeSymbol:
+		var typ exprType
+		if sym.Scope != nil {
+			typ = instanceOf(sym.Scope)
+			typ.name = sym.Name
+		} else {
+			typ = exprType{kind: predeclaredKinds[FoldIdentifier(sym.Name)], name: sym.Name}
+		}
+		if c.text(j) == "(" {
+			// A type cast.
+			_, j = c.arguments(j)
+			typ.variable = true
+			return typ, j
+		}
+		typ.isType = true
+		return typ, j
+	case MethodSymbol, RoutineSymbol:
+		overloads := c.overloads(sym, of)
+		var args []argument
+		call := c.text(j) == "("
+		if call {
+			args, j = c.arguments(j)
+		}
+		f := c.call(name, overloads, args, call, pos)
+		if f == nil {
+			c.reference(name, sym, in, pos, end)
+			return exprType{}, j
+		}
+		c.reference(name, f, in, pos, end)
+		d := f.Decl.(Function)
+		if d.FunctionKind == Constructor && of.isType {
+			typ := of
+			typ.isType = false
+			return typ, j
+		}
+		if d.Returns == "" {
+			return exprType{}, j
+		}
+		return typeOf(c.declScope(f), d.Returns), j
+	case PropertySymbol:
+		d, _ := sym.Decl.(Property)
+		typ := unknown
+		if d.Type != "" {
+			typ = typeOf(c.declScope(sym), d.Type)
+			typ.readOnly = d.Write == ""
+		}
+		typ.variable = false
+		if len(d.Parameters) > 0 {
+			if c.text(j) != "[" {
+				return exprType{}, j
+			}
+			j = c.index(j)
+		}
+		return typ, j
+	case ConstantSymbol:
+		d, _ := sym.Decl.(Constant)
+		if d.Type != "" {
+			// Typed constants are variables if writeable constants are on.
+			typ := typeOf(c.declScope(sym), d.Type)
+			typ.variable = true
+			return typ, j
+		}
+		typ := constantType(d.Value)
+		typ.readOnly = true
+		return typ, j
+	case ParameterSymbol:
+		d, _ := sym.Decl.(Parameter)
+		typ := unknown
+		if d.Type != "" {
+			typ = typeOf(c.declScope(sym), d.Type)
+		}
+		typ.variable = true
+		if d.Qualifier == Const || d.Qualifier == ConstRef || d.Qualifier == RefConst {
+			typ.variable = false
+			typ.readOnly = true
+		}
+		return typ, j
+	case VariableSymbol, FieldSymbol:
+		d, _ := sym.Decl.(Variable)
+		typ := typeOf(c.declScope(sym), d.Type)
+		typ.variable = true
+		return typ, j
+	}
+	return unknown, j
+}
+
+// constantType returns the type of the value of a true constant if it is a
+// single literal, otherwise the type is not known.
+func constantType(value string) exprType {
+	s := NewScanner(value)
+	s.SkipWhiteSpace = true
+	t := s.Scan()
+	if s.Scan().Kind != EOFToken {
+		return exprType{}
+	}
+	switch t.Kind {
+	case NumberToken:
+		if !strings.HasPrefix(t.Text, "$") && strings.ContainsAny(t.Text, ".eE") {
+			return exprType{kind: realType, name: "Extended"}
+		}
+		return exprType{kind: integerType, name: "Integer"}
+	case StringToken:
+		if stringLength(t.Text) == 1 {
+			return exprType{kind: charType, name: "Char"}
+		}
+		return exprType{kind: stringType, name: "string"}
+	}
+	return exprType{}
+}
+
+// declScope returns the scope in which the types of a declaration are looked
+// up.
+func (c *bodyChecker) declScope(sym *Symbol) *Scope {
+	if sym.File == nil || sym.Decl == nil {
+		return c.info.System
+	}
+	pos, _ := sym.Decl.Span()
+	if s := c.info.ScopeAt(sym.File, pos.Offset); s != nil {
+		return s
+	}
+	return c.info.System
+}
+
+// typeOf returns the type with the given name in the scope.
+func typeOf(in *Scope, name string) exprType {
+	sym := in.Lookup(name)
+	if sym == nil {
+		return exprType{name: name}
+	}
+	switch sym.Kind {
+	case TypeSymbol:
+		return exprType{kind: predeclaredKinds[FoldIdentifier(sym.Name)], name: sym.Name}
+	case ClassSymbol, InterfaceSymbol, RecordSymbol:
+		typ := instanceOf(sym.Scope)
+		typ.name = sym.Name
+		return typ
+	}
+	return exprType{name: name}
+}
+
+// instanceOf returns the type of the values of a class, interface or record.
+func instanceOf(scope *Scope) exprType {
+	typ := exprType{kind: classType, name: scope.Name, scope: scope}
+	if scope.Parent != nil {
+		if sym := scope.Parent.names[FoldIdentifier(scope.Name)]; sym != nil {
+			switch sym.Kind {
+			case InterfaceSymbol:
+				typ.kind = interfaceType
+			case RecordSymbol:
+				typ.kind = recordType
+			}
+		}
+	}
+	return typ
+}
+
+// overloads returns the declarations of the routine or method sym. Methods can
+// be overloaded in super classes, routines in the scopes around the call and in
+// the unit that declares them.
+func (c *bodyChecker) overloads(sym *Symbol, of exprType) []*Symbol {
+	var list []*Symbol
+	seen := make(map[*Symbol]bool)
+	add := func(s *Scope) {
+		for _, other := range s.Symbols {
+			if _, ok := other.Decl.(Function); ok && !seen[other] &&
+				other.Kind == sym.Kind && SameIdentifier(other.Name, sym.Name) {
+				seen[other] = true
+				list = append(list, other)
+			}
+		}
+	}
+	var hierarchy func(s *Scope, visited map[*Scope]bool)
+	hierarchy = func(s *Scope, visited map[*Scope]bool) {
+		visited[s] = true
+		add(s)
+		for _, base := range s.bases {
+			if !visited[base] {
+				hierarchy(base, visited)
+			}
+		}
+	}
+	if sym.Kind == MethodSymbol {
+		class := of.scope
+		if class == nil {
+			class = c.declScope(sym)
+			for class != nil && class.Kind != ClassScope {
+				class = class.Parent
+			}
+		}
+		if class != nil {
+			hierarchy(class, make(map[*Scope]bool))
+		}
+	} else {
+		for s := c.declScope(sym); s != nil; s = s.Parent {
+			add(s)
+		}
+		for _, s := range c.info.Scopes {
+			if s.File == sym.File && s.Kind == InterfaceScope {
+				add(s)
+			}
+		}
+	}
+	if len(list) == 0 {
+		if _, ok := sym.Decl.(Function); ok {
+			list = append(list, sym)
+		}
+	}
+	return list
+}
+
+// call checks the arguments of a call of the overloaded routine or method
+// name and returns the called overload, or nil if it is not known. A call
+// without parentheses has no arguments.
+func (c *bodyChecker) call(name string, overloads []*Symbol, args []argument, parens bool, pos Position) *Symbol {
+	if len(overloads) == 0 {
+		return nil
+	}
+	var fitting []*Symbol
+	for _, f := range overloads {
+		required, total := parameterCounts(f.Decl.(Function))
+		if required <= len(args) && len(args) <= total {
+			fitting = append(fitting, f)
+		}
+	}
+	if len(fitting) == 0 {
+		if !parens {
+			// This is not a call but e.g. the address of the routine in an
+			// assignment to an event.
+			return nil
+		}
+		if len(overloads) > 1 {
+			c.report(pos, `no overloaded version of "`+name+`" can be called with these arguments`)
+		} else if required, _ := parameterCounts(overloads[0].Decl.(Function)); len(args) < required {
+			c.report(pos, `not enough actual parameters for "`+name+`"`)
+		} else {
+			c.report(pos, `too many actual parameters for "`+name+`"`)
+		}
+		return nil
+	}
+	var errs []*TypeError
+	for _, f := range fitting {
+		errs = c.argumentErrors(f, args)
+		if len(errs) == 0 {
+			return f
+		}
+	}
+	if len(overloads) > 1 {
+		c.report(pos, `no overloaded version of "`+name+`" can be called with these arguments`)
+		return nil
+	}
+	for _, err := range errs {
+		c.report(err.Pos, err.Msg)
+	}
+	return overloads[0]
+}
+
+// parameterCounts returns the number of parameters that a call must pass and
+// the number that it can pass. Parameters with default values are optional.
+func parameterCounts(f Function) (required, total int) {
+	for _, p := range f.Parameters {
+		if p.Default == "" {
+			required += len(p.Names)
+		}
+		total += len(p.Names)
+	}
+	return
+}
+
+// argumentErrors returns the errors of passing the arguments to the routine or
+// method f. Arguments for var and out parameters must be variables.
+func (c *bodyChecker) argumentErrors(f *Symbol, args []argument) []*TypeError {
+	var params []Parameter
+	var names []string
+	for _, p := range f.Decl.(Function).Parameters {
+		for _, name := range p.Names {
+			params = append(params, p)
+			names = append(names, name)
+		}
+	}
+	in := c.declScope(f)
+	var errs []*TypeError
+	for i, a := range args {
+		p := params[i]
+		if (p.Qualifier == Var || p.Qualifier == Out) && !a.typ.variable {
+			errs = append(errs, &TypeError{
+				Pos: a.pos,
+				Msg: "argument for the " + p.Qualifier.String() + ` parameter "` +
+					names[i] + `" must be a variable`,
+			})
+		} else if typ := typeOf(in, p.Type); p.Type != "" && !assignable(typ, a.typ) {
+			errs = append(errs, &TypeError{
+				Pos: a.pos,
+				Msg: `incompatible types "` + typ.name + `" and "` + a.typ.name + `"`,
+			})
+		}
+	}
+	return errs
+}
+
+// arguments parses the arguments in parentheses at tokens[i] and returns them
+// with the index after the closing parenthesis.
+func (c *bodyChecker) arguments(i int) ([]argument, int) {
+	return c.list(i, ")")
+}
+
+// index parses the indices in brackets at tokens[i] and returns the index after
+// the closing bracket.
+func (c *bodyChecker) index(i int) int {
+	_, j := c.list(i, "]")
+	return j
+}
+
+func (c *bodyChecker) list(i int, close string) ([]argument, int) {
+	var args []argument
+	j := i + 1
+	if c.text(j) == close {
+		return nil, j + 1
+	}
+	for j < len(c.tokens) {
+		typ, k := c.expression(j)
+		a := argument{typ: typ, pos: c.tokens[j].Pos}
+		// Skip what is not understood, e.g. the width of Write(X:10).
+		for depth := 0; k < len(c.tokens); k++ {
+			t := c.text(k)
+			if depth == 0 && (t == "," || t == close) {
+				break
+			}
+			a.typ = unknown
+			switch t {
+			case "(", "[":
+				depth++
+			case ")", "]":
+				depth--
+			}
+		}
+		args = append(args, a)
+		if c.text(k) != "," {
+			return args, k + 1
+		}
+		j = k + 1
+	}
+	return args, j
+}
+
+// selectors parses the member accesses, indices and dereferences after an
+// expression of type typ at tokens[i].
+func (c *bodyChecker) selectors(typ exprType, i int) (exprType, int) {
+	for {
+		switch c.text(i) {
+		case ".":
+			if c.word(i+1) == "" {
+				return unknown, i + 1
+			}
+			t := c.tokens[i+1]
+			name := strings.TrimPrefix(t.Text, "&")
+			var sym *Symbol
+			if typ.scope != nil {
+				sym = typ.scope.member(name, nil)
+			}
+			if sym == nil {
+				folded := FoldIdentifier(name)
+				if typ.scope != nil && !objectMembers[folded] {
+					c.reference(name, nil, typ.scope, t.Pos, t.End)
+					if typ.kind != recordType && knownHierarchy(typ.scope, nil) {
+						c.report(t.Pos, `undeclared identifier "`+name+`"`)
+					}
+				}
+				next := unknown
+				if folded == "create" && typ.isType {
+					next = typ
+					next.isType = false
+				}
+				i += 2
+				if c.text(i) == "(" {
+					_, i = c.arguments(i)
+				}
+				typ = next
+				continue
+			}
+			typ, i = c.symbol(sym, name, typ, typ.scope, t.Pos, i+2)
+		case "[":
+			i = c.index(i)
+			if typ.kind == stringType {
+				typ = exprType{kind: charType, name: "Char", variable: true}
+			} else {
+				typ = unknown
+			}
+		case "^":
+			typ = unknown
+			i++
+		case "(":
+			_, i = c.arguments(i)
+			typ = exprType{}
+		default:
+			return typ, i
+		}
+	}
+}
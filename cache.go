@@ -0,0 +1,92 @@
+package pas
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Cache stores parsed files by a key, see ParseAllOptions. It must be safe for
+// concurrent use. Files from a cache can be shared between callers, they must
+// not be modified.
+type Cache interface {
+	// Get returns the file that was stored under the key.
+	Get(key string) (*File, bool)
+	// Put stores the file under the key.
+	Put(key string, f *File)
+}
+
+// NewMemoryCache returns a Cache that keeps all files in memory.
+func NewMemoryCache() Cache {
+	return &memoryCache{files: make(map[string]*File)}
+}
+
+type memoryCache struct {
+	mu    sync.Mutex
+	files map[string]*File
+}
+
+func (c *memoryCache) Get(key string) (*File, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, ok := c.files[key]
+	return f, ok
+}
+
+func (c *memoryCache) Put(key string, f *File) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[key] = f
+}
+
+// NewDiskCache returns a Cache that stores files as JSON in the directory, so
+// they can be reused between runs. The directory is created if it does not
+// exist. Files that cannot be read or written are treated as not cached.
+func NewDiskCache(dir string) Cache {
+	return diskCache(dir)
+}
+
+type diskCache string
+
+func (dir diskCache) Get(key string) (*File, bool) {
+	data, err := os.ReadFile(dir.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, false
+	}
+	return &f, true
+}
+
+func (dir diskCache) Put(key string, f *File) {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(string(dir), 0777); err != nil {
+		return
+	}
+	// Write to a temporary file first, so other processes never read a file
+	// that is only partly written.
+	tmp, err := os.CreateTemp(string(dir), key+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dir.path(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+func (dir diskCache) path(key string) string {
+	return filepath.Join(string(dir), key+".json")
+}
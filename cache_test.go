@@ -0,0 +1,74 @@
+package pas
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// treeShapes are the hashes of the syntax tree's types for each cacheVersion.
+// If this test fails, the tree changed and cacheVersion must be incremented.
+// Then add the new hash for the new version here. When the parser makes
+// different trees of the same types, e.g. because it parses code that it did
+// not parse before, cacheVersion must be incremented as well, the hash then
+// stays the same.
+var treeShapes = map[int]string{
+	5:  "d9fae2a2de90fd3eee1ea47c9dc18091806e6ca0630b3506e28081839950d844",
+	6:  "75f3efa23d830e519d1ad5413120ab1d1ddd4bd642f154b83de76d1385d851b5",
+	7:  "fa07dce9ea3f2110812a3ecec9410c3225aac7bdad3903080cc4fb42bac0f74a",
+	8:  "fa07dce9ea3f2110812a3ecec9410c3225aac7bdad3903080cc4fb42bac0f74a",
+	9:  "81eb91e8579d1c7605f9f9d1fee7b5aa3d9f67a89f79195dc4c4e6f63ce3d7cb",
+	10: "abec661da9094b4ff99532b92a76a69c4348df113812f6d8fac7a05eb0f77b39",
+	11: "9b8683a9df59c294efdd2603367d9bbdaa707b1ae4a995a46f1b2a16b9651019",
+	12: "8d6a7b56c989a4f2ac1e9da78541dd2038e325f4a8ecbc1063d8e817c046f320",
+}
+
+func TestCacheVersionChangesWithTheSyntaxTree(t *testing.T) {
+	have := treeShape()
+	if want := treeShapes[cacheVersion]; have != want {
+		t.Errorf("the syntax tree changed, increment cacheVersion and add "+
+			"the tree shape for the new version to treeShapes:\n%d: %q",
+			cacheVersion+1, have)
+	}
+}
+
+// treeShape returns a hash of the fields of all node types.
+func treeShape() string {
+	var b strings.Builder
+	seen := make(map[reflect.Type]bool)
+	var describe func(t reflect.Type)
+	describe = func(t reflect.Type) {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice:
+			describe(t.Elem())
+			return
+		case reflect.Struct:
+		default:
+			return
+		}
+		if seen[t] {
+			return
+		}
+		seen[t] = true
+		fmt.Fprintf(&b, "%s{", t.Name())
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			fmt.Fprintf(&b, "%s %s %s;", f.Name, f.Type, f.Tag)
+		}
+		b.WriteString("}\n")
+		for i := 0; i < t.NumField(); i++ {
+			describe(t.Field(i).Type)
+		}
+	}
+	// The implementations of the node interfaces are not reachable through
+	// the fields of File, they are listed explicitly.
+	for _, node := range []interface{}{
+		File{}, TypeBlock{}, VarBlock{}, ConstBlock{}, Routine{},
+		Class{}, Variable{}, Constant{}, Function{}, Property{},
+	} {
+		describe(reflect.TypeOf(node))
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(b.String())))
+}
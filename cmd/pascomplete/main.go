@@ -0,0 +1,112 @@
+// pascomplete completes the classes of Delphi units like class completion in
+// the Delphi IDE does.
+//
+// Accessor fields and methods of properties that are not declared yet are
+// added to the classes' private sections and empty implementations of all
+// methods that have none are appended to the implementation section. The rest
+// of the code is not changed.
+//
+// Without file arguments, it reads code from stdin and writes the completed
+// code to stdout. With files, it writes the completed code of each file to
+// stdout, unless -w or -l is given. The files must be UTF-8 encoded.
+//
+// Usage:
+//
+//     pascomplete [flags] [files...]
+//
+// Flags:
+//
+//     -w   write the result back to the source file
+//     -l   list the files with incomplete classes
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"github.com/gonutz/pas"
+)
+
+var (
+	write = flag.Bool("w", false, "write the result back to the source file")
+	list  = flag.Bool("l", false, "list the files with incomplete classes")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: pascomplete [flags] [files...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "pascomplete: cannot use -w with standard input")
+			os.Exit(2)
+		}
+		if err := processFile("<standard input>", os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	exitCode := 0
+	for _, path := range flag.Args() {
+		if err := processPath(path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+		}
+	}
+	os.Exit(exitCode)
+}
+
+func processPath(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return processFile(path, f, os.Stdout)
+}
+
+func processFile(name string, r io.Reader, out io.Writer) error {
+	code, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if !utf8.Valid(code) {
+		return errors.New(name + ": the code is not UTF-8 encoded")
+	}
+	src, err := pas.ParseLossless(string(code))
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if err := src.CompleteClasses(); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	res := []byte(src.String())
+
+	changed := !bytes.Equal(code, res)
+	if *list && changed {
+		fmt.Fprintln(out, name)
+	}
+	if *write && changed {
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(name, res, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if !*list && !*write {
+		_, err = out.Write(res)
+	}
+	return err
+}
@@ -0,0 +1,135 @@
+// pasdeps prints the dependency graph of Delphi units.
+//
+// The units are either given as files or as a .dproj or .dpr project, in which
+// case all units of the project are loaded. Interface-level cycles, which are
+// compile errors, are printed to stderr and make pasdeps exit with code 1.
+//
+// Usage:
+//
+//     pasdeps [flags] files...
+//     pasdeps [flags] project.dproj
+//
+// Flags:
+//
+//     -format text|dot|json   output format, text lists the uses of each unit
+//                             and the cycles
+//     -path From,To           only print the shortest chain of uses from unit
+//                             From to unit To
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gonutz/pas"
+)
+
+var (
+	format = flag.String("format", "text", "output format: text, dot or json")
+	path   = flag.String("path", "", "print the shortest path between two units, given as From,To")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: pasdeps [flags] files... | project.dproj")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	g, err := load(flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if g == nil {
+			os.Exit(1)
+		}
+	}
+
+	if *path != "" {
+		units := strings.Split(*path, ",")
+		if len(units) != 2 {
+			fmt.Fprintln(os.Stderr, "-path must be two units separated by a comma")
+			os.Exit(2)
+		}
+		p := g.ShortestPath(strings.TrimSpace(units[0]), strings.TrimSpace(units[1]))
+		if p == nil {
+			fmt.Fprintln(os.Stderr, "no path from", units[0], "to", units[1])
+			os.Exit(1)
+		}
+		fmt.Println(strings.Join(p, " -> "))
+		return
+	}
+
+	switch *format {
+	case "text":
+		err = writeText(os.Stdout, g)
+	case "dot":
+		err = g.WriteDOT(os.Stdout)
+	case "json":
+		err = g.WriteJSON(os.Stdout)
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	cycles := g.InterfaceCycles()
+	for _, c := range cycles {
+		fmt.Fprintln(os.Stderr, "interface cycle:", strings.Join(c, " -> "))
+	}
+	if len(cycles) > 0 {
+		os.Exit(1)
+	}
+}
+
+// load parses the given files or the single project. Syntax errors are
+// returned together with the graph of what could be parsed.
+func load(args []string) (*pas.DependencyGraph, error) {
+	if len(args) == 1 {
+		ext := strings.ToLower(filepath.Ext(args[0]))
+		if ext == ".dproj" || ext == ".dpr" {
+			p, err := pas.LoadProject(args[0])
+			if p == nil {
+				return nil, err
+			}
+			return p.DependencyGraph(), err
+		}
+	}
+	files, err := pas.ParseAll(args)
+	if _, isSyntaxError := err.(pas.ErrorList); err != nil && !isSyntaxError {
+		return nil, err
+	}
+	return pas.NewDependencyGraph(files), err
+}
+
+func writeText(w io.Writer, g *pas.DependencyGraph) error {
+	var b strings.Builder
+	for _, u := range g.Units {
+		intf := g.Uses(u, pas.InterfaceSection)
+		impl := g.Uses(u, pas.ImplementationSection)
+		if len(intf) == 0 && len(impl) == 0 {
+			continue
+		}
+		fmt.Fprintln(&b, u)
+		if len(intf) > 0 {
+			fmt.Fprintln(&b, "  interface:     ", strings.Join(intf, ", "))
+		}
+		if len(impl) > 0 {
+			fmt.Fprintln(&b, "  implementation:", strings.Join(impl, ", "))
+		}
+	}
+	for _, c := range g.ImplementationCycles() {
+		fmt.Fprintln(&b, "implementation cycle:", strings.Join(c, " -> "))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
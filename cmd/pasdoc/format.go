@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// format writes the pages in one output format. All functions that take text
+// expect it to be escaped already, see format.escape, so that links can be
+// part of it.
+type format interface {
+	// ext is the file extension of the pages, including the dot.
+	ext() string
+	escape(s string) string
+	link(text, url string) string
+	// page wraps the body in a complete page, with links to the index and the
+	// hierarchy.
+	page(title, body string) string
+	heading(level int, text string) string
+	paragraph(text string) string
+	// declaration is a line of code.
+	declaration(text string) string
+	table(header []string, rows [][]string) string
+	tree(items []treeItem) string
+	// search is the search box of the index page, formats that cannot run
+	// scripts return "".
+	search() string
+}
+
+// treeItem is an entry of a nested list, e.g. a class and its descendants.
+type treeItem struct {
+	text     string
+	children []treeItem
+}
+
+type htmlFormat struct{}
+
+func (htmlFormat) ext() string { return ".html" }
+
+func (htmlFormat) escape(s string) string { return html.EscapeString(s) }
+
+func (htmlFormat) link(text, url string) string {
+	return `<a href="` + html.EscapeString(url) + `">` + text + "</a>"
+}
+
+func (htmlFormat) page(title, body string) string {
+	return `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>` + title + `</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: auto; padding: 1em; }
+code { font-family: monospace; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+</style>
+</head>
+<body>
+<nav><a href="index.html">Index</a> | <a href="hierarchy.html">Class hierarchy</a></nav>
+` + body + `</body>
+</html>
+`
+}
+
+func (htmlFormat) heading(level int, text string) string {
+	return fmt.Sprintf("<h%d>%s</h%d>\n", level, text, level)
+}
+
+func (htmlFormat) paragraph(text string) string {
+	return "<p>" + strings.Replace(text, "\n", "<br>\n", -1) + "</p>\n"
+}
+
+func (htmlFormat) declaration(text string) string {
+	return "<pre><code>" + text + "</code></pre>\n"
+}
+
+func (htmlFormat) table(header []string, rows [][]string) string {
+	var b strings.Builder
+	b.WriteString("<table>\n<tr>")
+	for _, h := range header {
+		b.WriteString("<th>" + h + "</th>")
+	}
+	b.WriteString("</tr>\n")
+	for _, row := range rows {
+		b.WriteString("<tr>")
+		for _, cell := range row {
+			b.WriteString("<td>" + cell + "</td>")
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</table>\n")
+	return b.String()
+}
+
+func (f htmlFormat) tree(items []treeItem) string {
+	if len(items) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("<ul>\n")
+	for _, item := range items {
+		b.WriteString("<li>" + item.text + "\n" + f.tree(item.children) + "</li>\n")
+	}
+	b.WriteString("</ul>\n")
+	return b.String()
+}
+
+// search filters the entries of searchIndex, which search.js declares, while
+// typing.
+func (htmlFormat) search() string {
+	return `<input id="search" type="search" placeholder="Search">
+<ul id="results"></ul>
+<script src="search.js"></script>
+<script>
+var input = document.getElementById("search");
+var results = document.getElementById("results");
+input.oninput = function() {
+  var query = input.value.toLowerCase();
+  results.innerHTML = "";
+  if (query === "") {
+    return;
+  }
+  searchIndex.forEach(function(entry) {
+    if (entry.name.toLowerCase().indexOf(query) !== -1) {
+      var a = document.createElement("a");
+      a.href = entry.url;
+      a.textContent = entry.name + " (" + entry.kind +
+        (entry.scope ? " in " + entry.scope : "") + ")";
+      var li = document.createElement("li");
+      li.appendChild(a);
+      results.appendChild(li);
+    }
+  });
+};
+</script>
+`
+}
+
+type markdownFormat struct{}
+
+func (markdownFormat) ext() string { return ".md" }
+
+// markdownSpecial are the characters that are escaped with a backslash. The '|'
+// would end table cells.
+const markdownSpecial = "\\`*_[]<>#|"
+
+func (markdownFormat) escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(markdownSpecial, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (markdownFormat) link(text, url string) string {
+	return "[" + text + "](" + url + ")"
+}
+
+func (markdownFormat) page(title, body string) string {
+	return "[Index](index.md) | [Class hierarchy](hierarchy.md)\n\n" + body
+}
+
+func (markdownFormat) heading(level int, text string) string {
+	return strings.Repeat("#", level) + " " + text + "\n\n"
+}
+
+func (markdownFormat) paragraph(text string) string {
+	// Two spaces at the line end keep the line break.
+	return strings.Replace(text, "\n", "  \n", -1) + "\n\n"
+}
+
+// declaration cannot use a code block because those cannot contain links.
+func (markdownFormat) declaration(text string) string {
+	return "> " + text + "\n\n"
+}
+
+func (markdownFormat) table(header []string, rows [][]string) string {
+	var b strings.Builder
+	b.WriteString("| " + strings.Join(header, " | ") + " |\n")
+	b.WriteString("|" + strings.Repeat(" --- |", len(header)) + "\n")
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = strings.Replace(cell, "\n", " ", -1)
+		}
+		b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func (f markdownFormat) tree(items []treeItem) string {
+	if len(items) == 0 {
+		return ""
+	}
+	return f.treeLevel(items, "") + "\n"
+}
+
+func (f markdownFormat) treeLevel(items []treeItem, indent string) string {
+	var b strings.Builder
+	for _, item := range items {
+		b.WriteString(indent + "- " + item.text + "\n")
+		b.WriteString(f.treeLevel(item.children, indent+"  "))
+	}
+	return b.String()
+}
+
+func (markdownFormat) search() string { return "" }
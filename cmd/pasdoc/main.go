@@ -0,0 +1,101 @@
+// pasdoc generates API documentation for Delphi units as static HTML or
+// Markdown pages.
+//
+// The units are either given as files or as a .dproj or .dpr project, in which
+// case all units of the project are loaded. Only the interface sections are
+// documented, they are the public API of the units. Private class members are
+// left out as well. Not documented are types other than classes, interfaces
+// and records, e.g. enumerations, sets, arrays and aliases, as well as variant
+// parts of records and resourcestring sections, because they are not parsed
+// yet.
+//
+// The output directory contains an index of all units and types, a class
+// hierarchy, one page per unit and one page per class, interface and record.
+// Unit pages list the constants, variables and routines of the unit. Type
+// pages list the members in one table per visibility and link to the types
+// they refer to. Dots in unit names are written as '-' in the page names, e.g.
+// the page of type TForm in unit Vcl.Forms is Vcl-Forms.TForm.html. The search
+// index search.json lists all documented declarations, the HTML index page
+// uses it for its search box.
+//
+// Usage:
+//
+//     pasdoc [flags] files...
+//     pasdoc [flags] project.dproj
+//
+// Flags:
+//
+//     -o dir                   output directory, the default is "doc"
+//     -format html|markdown    output format, the default is html
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gonutz/pas"
+)
+
+var (
+	output     = flag.String("o", "doc", "output directory")
+	formatFlag = flag.String("format", "html", "output format: html or markdown")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: pasdoc [flags] files... | project.dproj")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var f format
+	switch *formatFlag {
+	case "html":
+		f = htmlFormat{}
+	case "markdown":
+		f = markdownFormat{}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *formatFlag)
+		os.Exit(2)
+	}
+
+	info, err := load(flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if info == nil {
+			os.Exit(1)
+		}
+	}
+
+	if err := newSite(info).write(*output, f); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// load parses the given files or the single project and resolves all names.
+// Syntax errors are returned together with what could be parsed.
+func load(args []string) (*pas.Info, error) {
+	if len(args) == 1 {
+		ext := strings.ToLower(filepath.Ext(args[0]))
+		if ext == ".dproj" || ext == ".dpr" {
+			p, err := pas.LoadProject(args[0])
+			if p == nil {
+				return nil, err
+			}
+			return p.ResolveNames(), err
+		}
+	}
+	files, err := pas.ParseAll(args)
+	if _, isSyntaxError := err.(pas.ErrorList); err != nil && !isSyntaxError {
+		return nil, err
+	}
+	return pas.ResolveNames(files), err
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/pas"
+)
+
+const shapesUnit = `unit Shapes;
+interface
+uses Graphics;
+type
+  /// <summary>Something that can be drawn.</summary>
+  IDrawable = interface
+    procedure Draw(Canvas: TCanvas);
+  end;
+type
+  // TShape is the base of all shapes.
+  TShape = class(TInterfacedObject, IDrawable)
+  private
+    FName: string;
+  protected
+    function Area: Double; virtual; abstract;
+  public
+    procedure Draw(Canvas: TCanvas);
+    property Name: string read FName write FName;
+  end;
+type
+  TCircle = class(TShape)
+  public
+    Radius: Double;
+  end;
+type
+  TPoint = packed record
+    X: Double;
+  end;
+const
+  /// The number of corners of a triangle.
+  Corners = 3;
+  Origin: TPoint = (X: 0);
+var
+  Default: TShape;
+// Distance returns the length of the line from A to B.
+function Distance(const A, B: TPoint): Double;
+implementation
+type
+  THidden = class(TCircle)
+  end;
+end.
+`
+
+const graphicsUnit = `unit Graphics;
+interface
+type
+  TCanvas = class
+    procedure Clear;
+  end;
+implementation
+end.
+`
+
+func testSite(t *testing.T) *site {
+	var files []*pas.File
+	for _, code := range []string{shapesUnit, graphicsUnit} {
+		f, err := pas.ParseString(code)
+		check.Eq(t, err, nil)
+		files = append(files, f)
+	}
+	return newSite(pas.ResolveNames(files))
+}
+
+func pageNames(pages map[string]string) map[string]bool {
+	names := make(map[string]bool)
+	for name := range pages {
+		names[name] = true
+	}
+	return names
+}
+
+func TestOnlyInterfaceSectionIsDocumented(t *testing.T) {
+	pages := testSite(t).pages(htmlFormat{})
+	check.Eq(t, pageNames(pages), map[string]bool{
+		"index.html":            true,
+		"hierarchy.html":        true,
+		"search.json":           true,
+		"search.js":             true,
+		"Graphics.html":         true,
+		"Graphics.TCanvas.html": true,
+		"Shapes.html":           true,
+		"Shapes.IDrawable.html": true,
+		"Shapes.TShape.html":    true,
+		"Shapes.TCircle.html":   true,
+		"Shapes.TPoint.html":    true,
+	})
+	for name, page := range pages {
+		check.Eq(t, strings.Contains(page, "THidden"), false, name)
+	}
+}
+
+func TestTypePageHasMemberTablesWithoutPrivateMembers(t *testing.T) {
+	page := testSite(t).pages(markdownFormat{})["Shapes.TShape.md"]
+	check.Eq(t, strings.Contains(page, "FName\n"), false)
+	check.Eq(t, strings.Contains(page, "| FName |"), false)
+	check.Eq(t, strings.Contains(page,
+		"> type TShape = class(TInterfacedObject, [IDrawable](Shapes.IDrawable.md))\n\n"+
+			"TShape is the base of all shapes.\n\n"), true)
+	check.Eq(t, strings.Contains(page, "## Public members\n\n"+
+		"| Name | Declaration | Description |\n"+
+		"| --- | --- | --- |\n"+
+		"| Draw | procedure Draw(Canvas: [TCanvas](Graphics.TCanvas.md)); |  |\n"+
+		"| Name | property Name: string read FName write FName; |  |\n\n"), true)
+	check.Eq(t, strings.Contains(page, "## Protected members\n\n"+
+		"| Name | Declaration | Description |\n"+
+		"| --- | --- | --- |\n"+
+		"| Area | function Area: Double; virtual; abstract; |  |\n\n"), true)
+	check.Eq(t, strings.Index(page, "Public members") < strings.Index(page, "Protected members"), true)
+	check.Eq(t, strings.Contains(page, "Private"), false)
+}
+
+func TestHierarchyNestsDocumentedTypes(t *testing.T) {
+	pages := testSite(t).pages(markdownFormat{})
+	check.Eq(t, strings.Contains(pages["hierarchy.md"], "## Classes\n\n"+
+		"- [TCanvas](Graphics.TCanvas.md)\n"+
+		"- [TShape](Shapes.TShape.md) (TInterfacedObject)\n"+
+		"  - [TCircle](Shapes.TCircle.md)\n\n"+
+		"## Interfaces\n\n"+
+		"- [IDrawable](Shapes.IDrawable.md)\n\n"), true)
+	check.Eq(t, strings.Contains(pages["Shapes.TCircle.md"], "## Hierarchy\n\n"+
+		"- TInterfacedObject\n"+
+		"  - [TShape](Shapes.TShape.md)\n"+
+		"    - TCircle\n\n"), true)
+	check.Eq(t, strings.Contains(pages["Shapes.IDrawable.md"],
+		"Implemented by: [TShape](Shapes.TShape.md)\n\n"), true)
+	check.Eq(t, strings.Contains(pages["Shapes.IDrawable.md"],
+		"Something that can be drawn.\n\n"), true)
+}
+
+func TestUnitPageLinksUsedUnitsAndTypes(t *testing.T) {
+	page := testSite(t).pages(htmlFormat{})["Shapes.html"]
+	check.Eq(t, strings.Contains(page, `<p>Uses: <a href="Graphics.html">Graphics</a></p>`), true)
+	check.Eq(t, strings.Contains(page,
+		`<tr><td>Default</td><td><a href="Shapes.TShape.html">TShape</a></td><td></td></tr>`), true)
+}
+
+func TestUnitPageListsConstantsAndRoutines(t *testing.T) {
+	page := testSite(t).pages(markdownFormat{})["Shapes.md"]
+	check.Eq(t, strings.Contains(page, "## Constants\n\n"+
+		"| Name | Declaration | Description |\n"+
+		"| --- | --- | --- |\n"+
+		"| Corners | Corners = 3; | The number of corners of a triangle. |\n"+
+		"| Origin | Origin: [TPoint](Shapes.TPoint.md) = (X: 0); |  |\n\n"), true)
+	check.Eq(t, strings.Contains(page, "## Routines\n\n"+
+		"| Name | Declaration | Description |\n"+
+		"| --- | --- | --- |\n"+
+		"| Distance | function Distance(const A, B: [TPoint](Shapes.TPoint.md)): Double; | "+
+		"Distance returns the length of the line from A to B. |\n\n"), true)
+}
+
+func TestRecordFieldsArePublic(t *testing.T) {
+	page := testSite(t).pages(markdownFormat{})["Shapes.TPoint.md"]
+	check.Eq(t, strings.Contains(page, "> type TPoint = packed record\n\n"), true)
+	check.Eq(t, strings.Contains(page, "## Public members\n\n"), true)
+	check.Eq(t, strings.Contains(page, "Published"), false)
+}
+
+func TestPageNamesOfDottedUnitsDoNotCollide(t *testing.T) {
+	var files []*pas.File
+	for _, code := range []string{
+		"unit A.B; interface type C = class end; implementation end.",
+		"unit A.B.C; interface implementation end.",
+	} {
+		f, err := pas.ParseString(code)
+		check.Eq(t, err, nil)
+		files = append(files, f)
+	}
+	pages := newSite(pas.ResolveNames(files)).pages(htmlFormat{})
+	check.Eq(t, pageNames(pages), map[string]bool{
+		"index.html":     true,
+		"hierarchy.html": true,
+		"search.json":    true,
+		"search.js":      true,
+		"A-B.html":       true,
+		"A-B.C.html":     true,
+		"A-B-C.html":     true,
+	})
+}
+
+func TestTextIsEscaped(t *testing.T) {
+	check.Eq(t, htmlFormat{}.escape("a<b>&"), "a&lt;b&gt;&amp;")
+	check.Eq(t, markdownFormat{}.escape("TList<T>|_x"), `TList\<T\>\|\_x`)
+}
+
+func TestSearchIndexListsAllDocumentedDeclarations(t *testing.T) {
+	pages := testSite(t).pages(markdownFormat{})
+	_, hasScript := pages["search.js"]
+	check.Eq(t, hasScript, false)
+	var index []searchEntry
+	check.Eq(t, json.Unmarshal([]byte(pages["search.json"]), &index), nil)
+	var entries []string
+	for _, e := range index {
+		entries = append(entries, e.Kind+" "+e.Name+" "+e.Scope+" "+e.URL)
+	}
+	check.Eq(t, entries, []string{
+		"unit Graphics  Graphics.md",
+		"class TCanvas Graphics Graphics.TCanvas.md",
+		"method Clear Graphics.TCanvas Graphics.TCanvas.md",
+		"unit Shapes  Shapes.md",
+		"interface IDrawable Shapes Shapes.IDrawable.md",
+		"method Draw Shapes.IDrawable Shapes.IDrawable.md",
+		"class TShape Shapes Shapes.TShape.md",
+		"method Draw Shapes.TShape Shapes.TShape.md",
+		"property Name Shapes.TShape Shapes.TShape.md",
+		"method Area Shapes.TShape Shapes.TShape.md",
+		"class TCircle Shapes Shapes.TCircle.md",
+		"field Radius Shapes.TCircle Shapes.TCircle.md",
+		"packed record TPoint Shapes Shapes.TPoint.md",
+		"field X Shapes.TPoint Shapes.TPoint.md",
+		"constant Corners Shapes Shapes.md",
+		"constant Origin Shapes Shapes.md",
+		"variable Default Shapes Shapes.md",
+		"routine Distance Shapes Shapes.md",
+	})
+
+	script := testSite(t).pages(htmlFormat{})["search.js"]
+	check.Eq(t, strings.HasPrefix(script, "var searchIndex = [\n"), true)
+}
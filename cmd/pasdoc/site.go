@@ -0,0 +1,637 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gonutz/pas"
+)
+
+// site is the documentation of a set of units. Only the declarations in the
+// interface sections are documented, they are the public API of the units.
+type site struct {
+	units []*unitDoc
+	// types are the classes, interfaces and records of all units, sorted by
+	// name.
+	types    []*typeDoc
+	bySymbol map[*pas.Symbol]*typeDoc
+}
+
+type unitDoc struct {
+	name string
+	// scope is the interface scope of the unit.
+	scope    *pas.Scope
+	types    []*typeDoc
+	consts   []pas.Constant
+	vars     []pas.Variable
+	routines []pas.Routine
+}
+
+type typeDoc struct {
+	unit   *unitDoc
+	symbol *pas.Symbol
+	class  pas.Class
+	// hierarchy is nil if the type is not in the hierarchy, which only
+	// happens for duplicate declarations.
+	hierarchy *pas.HierarchyType
+}
+
+func newSite(info *pas.Info) *site {
+	s := &site{bySymbol: make(map[*pas.Symbol]*typeDoc)}
+	for _, scope := range info.Scopes {
+		if scope.Kind != pas.InterfaceScope {
+			continue
+		}
+		u := &unitDoc{name: scope.Name, scope: scope}
+		declared := make(map[string]bool)
+		for _, sym := range scope.Symbols {
+			// Duplicates would overwrite each other's pages.
+			key := pas.FoldIdentifier(sym.Name)
+			if declared[key] {
+				continue
+			}
+			declared[key] = true
+			switch sym.Kind {
+			case pas.ClassSymbol, pas.InterfaceSymbol, pas.RecordSymbol:
+				t := &typeDoc{unit: u, symbol: sym, class: sym.Decl.(pas.Class)}
+				u.types = append(u.types, t)
+				s.types = append(s.types, t)
+				s.bySymbol[sym] = t
+			case pas.VariableSymbol:
+				u.vars = append(u.vars, sym.Decl.(pas.Variable))
+			case pas.ConstantSymbol:
+				u.consts = append(u.consts, sym.Decl.(pas.Constant))
+			}
+		}
+		// Routines are not symbols of the scope, overloads have the same name
+		// and are all documented.
+		for _, section := range scope.File.Sections {
+			if section.Kind != pas.InterfaceSection {
+				continue
+			}
+			for _, block := range section.Blocks {
+				if r, ok := block.(pas.Routine); ok {
+					u.routines = append(u.routines, r)
+				}
+			}
+		}
+		s.units = append(s.units, u)
+	}
+	for _, h := range pas.NewHierarchy(info).Types {
+		if t := s.bySymbol[h.Symbol]; t != nil {
+			t.hierarchy = h
+		}
+	}
+	sort.SliceStable(s.units, func(i, j int) bool {
+		return strings.ToLower(s.units[i].name) < strings.ToLower(s.units[j].name)
+	})
+	sort.SliceStable(s.types, func(i, j int) bool {
+		return strings.ToLower(s.types[i].name()) < strings.ToLower(s.types[j].name())
+	})
+	return s
+}
+
+func (t *typeDoc) name() string { return t.symbol.Name }
+
+func (t *typeDoc) kind() string {
+	if t.class.IsInterface {
+		return "interface"
+	}
+	if t.class.IsPacked {
+		return "packed record"
+	}
+	if t.class.IsRecord {
+		return "record"
+	}
+	return "class"
+}
+
+func unitURL(f format, u *unitDoc) string {
+	return pageName(u.name) + f.ext()
+}
+
+func typeURL(f format, t *typeDoc) string {
+	return pageName(t.unit.name) + "." + pageName(t.name()) + f.ext()
+}
+
+// pageName escapes the dots in a unit or type name, so the only dot of a type
+// page name separates the unit from the type. Otherwise type C of unit A.B
+// and the unit A.B.C would both get the page A.B.C. Identifiers cannot contain
+// '-', so the escaped names cannot collide with other names.
+func pageName(name string) string {
+	return strings.Replace(name, ".", "-", -1)
+}
+
+// write writes all pages in the format to the directory, which is created if
+// it does not exist.
+func (s *site) write(dir string, f format) error {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	for name, content := range s.pages(f) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pages returns the contents of all files by their names. Besides the pages,
+// these are the search index search.json and, for HTML, search.js, which
+// declares the index for the search box.
+func (s *site) pages(f format) map[string]string {
+	pages := map[string]string{
+		"index" + f.ext():     s.indexPage(f),
+		"hierarchy" + f.ext(): s.hierarchyPage(f),
+	}
+	for _, u := range s.units {
+		pages[unitURL(f, u)] = s.unitPage(f, u)
+		for _, t := range u.types {
+			pages[typeURL(f, t)] = s.typePage(f, t)
+		}
+	}
+	index, _ := json.MarshalIndent(s.searchIndex(f), "", "\t")
+	pages["search.json"] = string(index) + "\n"
+	if f.search() != "" {
+		pages["search.js"] = "var searchIndex = " + string(index) + ";\n"
+	}
+	return pages
+}
+
+func (s *site) indexPage(f format) string {
+	var b strings.Builder
+	b.WriteString(f.heading(1, "API documentation"))
+	b.WriteString(f.search())
+	b.WriteString(f.heading(2, "Units"))
+	var units []treeItem
+	for _, u := range s.units {
+		units = append(units, treeItem{text: f.link(f.escape(u.name), unitURL(f, u))})
+	}
+	b.WriteString(f.tree(units))
+	if len(s.types) > 0 {
+		b.WriteString(f.heading(2, "Types"))
+		var rows [][]string
+		for _, t := range s.types {
+			rows = append(rows, []string{
+				f.link(f.escape(t.name()), typeURL(f, t)),
+				t.kind(),
+				f.link(f.escape(t.unit.name), unitURL(f, t.unit)),
+				f.escape(summary(t.class.Doc)),
+			})
+		}
+		b.WriteString(f.table([]string{"Name", "Kind", "Unit", "Description"}, rows))
+	}
+	return f.page("API documentation", b.String())
+}
+
+func (s *site) hierarchyPage(f format) string {
+	var b strings.Builder
+	b.WriteString(f.heading(1, "Class hierarchy"))
+	for _, interfaces := range []bool{false, true} {
+		var roots []treeItem
+		for _, t := range s.types {
+			if t.class.IsInterface != interfaces || t.hierarchy == nil {
+				continue
+			}
+			if parent := t.hierarchy.Parent; parent != nil && s.bySymbol[parent.Symbol] != nil {
+				continue
+			}
+			root := s.subTree(f, t)
+			if t.hierarchy.ParentName != "" {
+				root.text += " (" + f.escape(t.hierarchy.ParentName) + ")"
+			}
+			roots = append(roots, root)
+		}
+		if len(roots) > 0 {
+			if interfaces {
+				b.WriteString(f.heading(2, "Interfaces"))
+			} else {
+				b.WriteString(f.heading(2, "Classes"))
+			}
+			b.WriteString(f.tree(roots))
+		}
+	}
+	return f.page("Class hierarchy", b.String())
+}
+
+// subTree returns the type with all its documented descendants.
+func (s *site) subTree(f format, t *typeDoc) treeItem {
+	item := treeItem{text: f.link(f.escape(t.name()), typeURL(f, t))}
+	for _, c := range s.children(t) {
+		item.children = append(item.children, s.subTree(f, c))
+	}
+	return item
+}
+
+func (s *site) children(t *typeDoc) []*typeDoc {
+	var children []*typeDoc
+	if t.hierarchy != nil {
+		for _, c := range t.hierarchy.Children {
+			if doc := s.bySymbol[c.Symbol]; doc != nil && doc != t {
+				children = append(children, doc)
+			}
+		}
+	}
+	return children
+}
+
+func (s *site) unitPage(f format, u *unitDoc) string {
+	var b strings.Builder
+	b.WriteString(f.heading(1, "Unit "+f.escape(u.name)))
+	if uses := s.uses(f, u); uses != "" {
+		b.WriteString(f.paragraph("Uses: " + uses))
+	}
+	if len(u.types) > 0 {
+		b.WriteString(f.heading(2, "Types"))
+		var rows [][]string
+		for _, t := range u.types {
+			rows = append(rows, []string{
+				f.link(f.escape(t.name()), typeURL(f, t)),
+				t.kind(),
+				f.escape(summary(t.class.Doc)),
+			})
+		}
+		b.WriteString(f.table([]string{"Name", "Kind", "Description"}, rows))
+	}
+	if len(u.consts) > 0 {
+		b.WriteString(f.heading(2, "Constants"))
+		var rows [][]string
+		for _, c := range u.consts {
+			rows = append(rows, []string{
+				f.escape(c.Name),
+				s.constantDeclaration(f, u.scope, c),
+				f.escape(summary(c.Doc)),
+			})
+		}
+		b.WriteString(f.table([]string{"Name", "Declaration", "Description"}, rows))
+	}
+	if len(u.vars) > 0 {
+		b.WriteString(f.heading(2, "Variables"))
+		var rows [][]string
+		for _, v := range u.vars {
+			rows = append(rows, []string{
+				f.escape(v.Name),
+				s.typeText(f, u.scope, v.Type),
+				f.escape(summary(v.Doc)),
+			})
+		}
+		b.WriteString(f.table([]string{"Name", "Type", "Description"}, rows))
+	}
+	if len(u.routines) > 0 {
+		b.WriteString(f.heading(2, "Routines"))
+		var rows [][]string
+		for _, r := range u.routines {
+			rows = append(rows, []string{
+				f.escape(r.Header.Name),
+				s.memberDeclaration(f, u.scope, r.Header),
+				f.escape(summary(r.Header.Doc)),
+			})
+		}
+		b.WriteString(f.table([]string{"Name", "Declaration", "Description"}, rows))
+	}
+	return f.page("Unit "+f.escape(u.name), b.String())
+}
+
+// uses returns the interface uses clause of the unit, with links to the
+// documented units.
+func (s *site) uses(f format, u *unitDoc) string {
+	var names []string
+	for _, section := range u.scope.File.Sections {
+		if section.Kind != pas.InterfaceSection {
+			continue
+		}
+		for _, name := range section.Uses {
+			text := f.escape(name)
+			for _, used := range s.units {
+				if pas.SameIdentifier(used.name, name) {
+					text = f.link(text, unitURL(f, used))
+				}
+			}
+			names = append(names, text)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+func (s *site) typePage(f format, t *typeDoc) string {
+	var b strings.Builder
+	title := t.kind() + " " + f.escape(t.name())
+	b.WriteString(f.heading(1, title))
+	b.WriteString(f.paragraph("Unit: " + f.link(f.escape(t.unit.name), unitURL(f, t.unit))))
+	b.WriteString(f.declaration(s.classDeclaration(f, t)))
+	for _, p := range paragraphs(t.class.Doc) {
+		b.WriteString(f.paragraph(f.escape(p)))
+	}
+
+	if h := t.hierarchy; h != nil {
+		b.WriteString(f.heading(2, "Hierarchy"))
+		// The ancestors from the root down to this type, followed by its
+		// children.
+		item := treeItem{text: f.escape(t.name())}
+		for _, c := range s.children(t) {
+			item.children = append(item.children, treeItem{
+				text: f.link(f.escape(c.name()), typeURL(f, c)),
+			})
+		}
+		root := h
+		for _, a := range h.Ancestors() {
+			text := f.escape(a.Name())
+			if doc := s.bySymbol[a.Symbol]; doc != nil {
+				text = f.link(text, typeURL(f, doc))
+			}
+			item = treeItem{text: text, children: []treeItem{item}}
+			root = a
+		}
+		if root.Parent == nil && root.ParentName != "" {
+			item = treeItem{text: f.escape(root.ParentName), children: []treeItem{item}}
+		}
+		b.WriteString(f.tree([]treeItem{item}))
+
+		if len(h.InterfaceNames) > 0 {
+			var names []string
+			for _, name := range h.InterfaceNames {
+				names = append(names, s.typeText(f, t.unit.scope, name))
+			}
+			b.WriteString(f.paragraph("Implements: " + strings.Join(names, ", ")))
+		}
+		var implementers []string
+		for _, i := range h.Implementers {
+			if doc := s.bySymbol[i.Symbol]; doc != nil {
+				implementers = append(implementers, f.link(f.escape(doc.name()), typeURL(f, doc)))
+			}
+		}
+		if len(implementers) > 0 {
+			b.WriteString(f.paragraph("Implemented by: " + strings.Join(implementers, ", ")))
+		}
+	}
+
+	for _, v := range documentedVisibilities {
+		var rows [][]string
+		for _, m := range members(t.class, v) {
+			name, doc := memberInfo(m)
+			rows = append(rows, []string{
+				f.escape(name),
+				s.memberDeclaration(f, t.symbol.Scope, m),
+				f.escape(summary(doc)),
+			})
+		}
+		if len(rows) > 0 {
+			b.WriteString(f.heading(2, strings.ToUpper(v.String()[:1])+v.String()[1:]+" members"))
+			b.WriteString(f.table([]string{"Name", "Declaration", "Description"}, rows))
+		}
+	}
+	return f.page(title, b.String())
+}
+
+// documentedVisibilities are the class sections that are part of the public
+// API, in the order of the member tables.
+var documentedVisibilities = []pas.Visibility{pas.Published, pas.Public, pas.Protected}
+
+// members returns the members of the class with the visibility. Members
+// without visibility keyword count as published, in records they are public.
+// Private members are not part of the public API and are never documented.
+func members(c pas.Class, v pas.Visibility) []pas.ClassMember {
+	var list []pas.ClassMember
+	for _, section := range c.Sections {
+		vis := section.Visibility
+		if vis == pas.DefaultPublished && c.IsRecord {
+			vis = pas.Public
+		} else if vis == pas.DefaultPublished {
+			vis = pas.Published
+		}
+		if vis == v {
+			list = append(list, section.Members...)
+		}
+	}
+	return list
+}
+
+func memberInfo(m pas.ClassMember) (name string, doc *pas.CommentGroup) {
+	switch m := m.(type) {
+	case pas.Variable:
+		return m.Name, m.Doc
+	case pas.Function:
+		return m.Name, m.Doc
+	case pas.Property:
+		return m.Name, m.Doc
+	}
+	return "", nil
+}
+
+func (s *site) classDeclaration(f format, t *typeDoc) string {
+	decl := "type " + f.escape(t.name()) + " = " + t.kind()
+	if len(t.class.SuperClasses) > 0 {
+		var supers []string
+		for _, super := range t.class.SuperClasses {
+			supers = append(supers, s.typeText(f, t.unit.scope, super))
+		}
+		decl += "(" + strings.Join(supers, ", ") + ")"
+	}
+	return decl
+}
+
+func (s *site) constantDeclaration(f format, scope *pas.Scope, c pas.Constant) string {
+	decl := f.escape(c.Name)
+	if c.Type != "" {
+		decl += ": " + s.typeText(f, scope, c.Type)
+	}
+	return decl + " = " + f.escape(c.Value) + ";"
+}
+
+func (s *site) memberDeclaration(f format, scope *pas.Scope, m pas.ClassMember) string {
+	switch m := m.(type) {
+	case pas.Variable:
+		return f.escape(m.Name) + ": " + s.typeText(f, scope, m.Type)
+	case pas.Function:
+		decl := ""
+		if m.IsClassMethod {
+			decl = "class "
+		}
+		if m.FunctionKind != pas.PlainFunction {
+			decl += m.FunctionKind.String()
+		} else if m.Returns == "" {
+			decl += "procedure"
+		} else {
+			decl += "function"
+		}
+		decl += " " + f.escape(m.Name) + s.parameters(f, scope, m.Parameters, "(", ")")
+		if m.Returns != "" {
+			decl += ": " + s.typeText(f, scope, m.Returns)
+		}
+		decl += ";"
+		for _, d := range m.Directives {
+			decl += " " + f.escape(d) + ";"
+		}
+		return decl
+	case pas.Property:
+		decl := "property " + f.escape(m.Name) + s.parameters(f, scope, m.Parameters, "[", "]")
+		if m.Type != "" {
+			decl += ": " + s.typeText(f, scope, m.Type)
+		}
+		if m.Read != "" {
+			decl += " read " + f.escape(m.Read)
+		}
+		if m.Write != "" {
+			decl += " write " + f.escape(m.Write)
+		}
+		for _, spec := range m.Specifiers {
+			decl += " " + f.escape(spec)
+		}
+		if m.IsDefault {
+			decl += "; default"
+		}
+		return decl + ";"
+	}
+	return ""
+}
+
+func (s *site) parameters(f format, scope *pas.Scope, params []pas.Parameter, open, close string) string {
+	if len(params) == 0 {
+		return ""
+	}
+	var list []string
+	for _, p := range params {
+		param := ""
+		if p.Qualifier != pas.NoQualifier {
+			param = p.Qualifier.String() + " "
+		}
+		param += f.escape(strings.Join(p.Names, ", "))
+		if p.Type != "" {
+			param += ": " + s.typeText(f, scope, p.Type)
+		}
+		if p.Default != "" {
+			param += " = " + f.escape(p.Default)
+		}
+		list = append(list, param)
+	}
+	return f.escape(open) + strings.Join(list, "; ") + f.escape(close)
+}
+
+// typeText returns the type with links to the documented types in it. Type
+// names are looked up in the scope, e.g. the class scope for the types of
+// members. Types like "array of TFoo" or "TList<TFoo>" link each name.
+func (s *site) typeText(f format, scope *pas.Scope, typ string) string {
+	var b strings.Builder
+	for typ != "" {
+		n := 0
+		for n < len(typ) && isNameByte(typ[n]) {
+			n++
+		}
+		if n == 0 {
+			n = 1
+			for n < len(typ) && !isNameByte(typ[n]) {
+				n++
+			}
+			b.WriteString(f.escape(typ[:n]))
+		} else if t := s.bySymbol[scope.Lookup(typ[:n])]; t != nil {
+			b.WriteString(f.link(f.escape(typ[:n]), typeURL(f, t)))
+		} else {
+			b.WriteString(f.escape(typ[:n]))
+		}
+		typ = typ[n:]
+	}
+	return b.String()
+}
+
+// isNameByte reports whether the byte can be part of a qualified identifier.
+// All bytes of multi-byte UTF-8 characters count as well, since Delphi
+// identifiers can contain Unicode letters.
+func isNameByte(b byte) bool {
+	return b == '_' || b == '&' || b == '.' || 'a' <= b && b <= 'z' ||
+		'A' <= b && b <= 'Z' || '0' <= b && b <= '9' || b >= 0x80
+}
+
+// summary returns the documentation in one line, for tables. For XML
+// documentation comments, this is the summary.
+func summary(g *pas.CommentGroup) string {
+	return strings.Join(paragraphs(g), " ")
+}
+
+// paragraphs returns the paragraphs of the documentation comment, each in one
+// line. XML documentation comments are split into their summary, parameters,
+// result and remarks.
+func paragraphs(g *pas.CommentGroup) []string {
+	text := g.Text()
+	if strings.Contains(text, "<summary>") {
+		if doc, err := pas.ParseXMLDoc(g); err == nil {
+			var list []string
+			if doc.Summary != "" {
+				list = append(list, doc.Summary)
+			}
+			for _, p := range doc.Params {
+				list = append(list, p.Name+": "+p.Text)
+			}
+			if doc.Returns != "" {
+				list = append(list, "Returns: "+doc.Returns)
+			}
+			if doc.Remarks != "" {
+				list = append(list, doc.Remarks)
+			}
+			return list
+		}
+	}
+	var list []string
+	for _, p := range strings.Split(text, "\n\n") {
+		if p = strings.Join(strings.Fields(p), " "); p != "" {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// searchEntry is a declaration in search.json.
+type searchEntry struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	// Scope is the unit of types, constants, variables and routines and the
+	// unit and type of members, e.g. "Shapes.TCircle".
+	Scope string `json:"scope,omitempty"`
+	URL   string `json:"url"`
+}
+
+func (s *site) searchIndex(f format) []searchEntry {
+	entries := []searchEntry{}
+	for _, u := range s.units {
+		entries = append(entries, searchEntry{Name: u.name, Kind: "unit", URL: unitURL(f, u)})
+		for _, t := range u.types {
+			url := typeURL(f, t)
+			entries = append(entries, searchEntry{Name: t.name(), Kind: t.kind(), Scope: u.name, URL: url})
+			for _, v := range documentedVisibilities {
+				for _, m := range members(t.class, v) {
+					name, _ := memberInfo(m)
+					entries = append(entries, searchEntry{
+						Name:  name,
+						Kind:  memberKind(m),
+						Scope: u.name + "." + t.name(),
+						URL:   url,
+					})
+				}
+			}
+		}
+		for _, c := range u.consts {
+			entries = append(entries, searchEntry{Name: c.Name, Kind: "constant", Scope: u.name, URL: unitURL(f, u)})
+		}
+		for _, v := range u.vars {
+			entries = append(entries, searchEntry{Name: v.Name, Kind: "variable", Scope: u.name, URL: unitURL(f, u)})
+		}
+		for _, r := range u.routines {
+			entries = append(entries, searchEntry{Name: r.Header.Name, Kind: "routine", Scope: u.name, URL: unitURL(f, u)})
+		}
+	}
+	return entries
+}
+
+func memberKind(m pas.ClassMember) string {
+	switch m.(type) {
+	case pas.Variable:
+		return "field"
+	case pas.Function:
+		return "method"
+	case pas.Property:
+		return "property"
+	}
+	return ""
+}
@@ -0,0 +1,66 @@
+// pasdump prints the syntax tree of Delphi units as JSON.
+//
+// Without file arguments, it reads a unit from stdin. With files, it prints one
+// JSON document per file. If a file has syntax errors, the errors are printed
+// to stderr and the tree that could be parsed is still printed.
+//
+// Usage:
+//
+//     pasdump [flags] [files...]
+//
+// Flags:
+//
+//     -compact   print the JSON on a single line instead of indented
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gonutz/pas"
+)
+
+var compact = flag.Bool("compact", false, "print the JSON on a single line instead of indented")
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: pasdump [flags] [files...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	exitCode := 0
+	dump := func(f *pas.File, err error) {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+		}
+		if f == nil {
+			return
+		}
+		if err := write(os.Stdout, f); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+		}
+	}
+
+	if flag.NArg() == 0 {
+		dump(pas.ParseReader(os.Stdin))
+	}
+	for _, path := range flag.Args() {
+		dump(pas.ParseFile(path))
+	}
+	os.Exit(exitCode)
+}
+
+func write(w io.Writer, f *pas.File) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if !*compact {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(f)
+}
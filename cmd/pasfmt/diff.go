@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// edit is one line of a diff. kind is ' ' for unchanged lines, '-' for lines
+// that were removed from a and '+' for lines that were added in b.
+type edit struct {
+	kind byte
+	line string
+}
+
+// unifiedDiff returns the differences between a and b in the unified diff
+// format, with 3 lines of context around each change. It returns nil if a and
+// b are equal.
+func unifiedDiff(name string, a, b []byte) []byte {
+	if bytes.Equal(a, b) {
+		return nil
+	}
+	edits := diffLines(splitLines(a), splitLines(b))
+
+	// aLine[i] and bLine[i] are the line numbers in a and b at edits[i].
+	aLine := make([]int, len(edits)+1)
+	bLine := make([]int, len(edits)+1)
+	aLine[0], bLine[0] = 1, 1
+	var changes []int
+	for i, e := range edits {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if e.kind != '+' {
+			aLine[i+1]++
+		}
+		if e.kind != '-' {
+			bLine[i+1]++
+		}
+		if e.kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s.orig\n+++ %s\n", name, name)
+	const context = 3
+	for len(changes) > 0 {
+		// Changes that are close together go into the same hunk.
+		last := 0
+		for last+1 < len(changes) && changes[last+1]-changes[last] <= 2*context {
+			last++
+		}
+		start := changes[0] - context
+		if start < 0 {
+			start = 0
+		}
+		end := changes[last] + context + 1
+		if end > len(edits) {
+			end = len(edits)
+		}
+		changes = changes[last+1:]
+
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n",
+			aLine[start], aLine[end]-aLine[start],
+			bLine[start], bLine[end]-bLine[start],
+		)
+		for _, e := range edits[start:end] {
+			out.WriteByte(e.kind)
+			out.WriteString(strings.TrimRight(e.line, "\r\n"))
+			out.WriteByte('\n')
+		}
+	}
+	return out.Bytes()
+}
+
+func splitLines(b []byte) []string {
+	lines := strings.SplitAfter(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines finds the shortest edit script from a to b with the linear space
+// variant of Myers' algorithm. It splits the problem at the middle snake of the
+// edit graph and solves both halves recursively, so it needs O(n+m) memory
+// even if all lines differ.
+//
+// If a and b have no line in common, e.g. when all line endings change, the
+// script removes all of a and adds all of b without searching.
+func diffLines(a, b []string) []edit {
+	var edits []edit
+	inA := make(map[string]bool)
+	for _, line := range a {
+		inA[line] = true
+	}
+	common := false
+	for _, line := range b {
+		common = common || inA[line]
+	}
+	if !common {
+		for _, line := range a {
+			edits = append(edits, edit{'-', line})
+		}
+		for _, line := range b {
+			edits = append(edits, edit{'+', line})
+		}
+		return edits
+	}
+	diffRange(a, b, &edits)
+	return edits
+}
+
+// diffRange appends the edits from a to b to edits.
+func diffRange(a, b []string, edits *[]edit) {
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		*edits = append(*edits, edit{' ', a[0]})
+		a, b = a[1:], b[1:]
+	}
+	common := 0
+	for common < len(a) && common < len(b) &&
+		a[len(a)-1-common] == b[len(b)-1-common] {
+		common++
+	}
+	suffix := a[len(a)-common:]
+	a, b = a[:len(a)-common], b[:len(b)-common]
+
+	if len(a) == 0 {
+		for _, line := range b {
+			*edits = append(*edits, edit{'+', line})
+		}
+	} else if len(b) == 0 {
+		for _, line := range a {
+			*edits = append(*edits, edit{'-', line})
+		}
+	} else {
+		// Without common prefix and suffix, there are at least two edits, so
+		// both halves around the middle snake have fewer edits than the whole.
+		x, y, u, v := middleSnake(a, b)
+		diffRange(a[:x], b[:y], edits)
+		for _, line := range a[x:u] {
+			*edits = append(*edits, edit{' ', line})
+		}
+		diffRange(a[u:], b[v:], edits)
+	}
+
+	for _, line := range suffix {
+		*edits = append(*edits, edit{' ', line})
+	}
+}
+
+// middleSnake returns the start (x, y) and end (u, v) of the middle snake of a
+// shortest edit script from a to b. It searches forward from the start and
+// backward from the end until the paths overlap. The backward search runs on
+// the reversed lines, its diagonal kr is the forward diagonal delta-kr.
+func middleSnake(a, b []string) (x, y, u, v int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	max := (n + m + 1) / 2
+	offset := max + 1
+	forward := make([]int, 2*max+3)
+	backward := make([]int, 2*max+3)
+	for d := 0; d <= max; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && forward[offset+k-1] < forward[offset+k+1] {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+			if kr := delta - k; odd && -(d-1) <= kr && kr <= d-1 &&
+				x >= n-backward[offset+kr] {
+				return startX, startY, x, y
+			}
+		}
+		for kr := -d; kr <= d; kr += 2 {
+			var x int
+			if kr == -d || kr != d && backward[offset+kr-1] < backward[offset+kr+1] {
+				x = backward[offset+kr+1]
+			} else {
+				x = backward[offset+kr-1] + 1
+			}
+			y := x - kr
+			startX, startY := x, y
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			backward[offset+kr] = x
+			if k := delta - kr; !odd && -d <= k && k <= d &&
+				forward[offset+k] >= n-x {
+				return n - x, m - y, n - startX, m - startY
+			}
+		}
+	}
+	// Not reached, the paths always overlap.
+	return 0, 0, n, m
+}
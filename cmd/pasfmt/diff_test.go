@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n14\n15\n16\n"
+	check.Eq(t, string(unifiedDiff("f.pas", []byte(a), []byte(b))), `--- f.pas.orig
++++ f.pas
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -10,6 +10,6 @@
+ 10
+ 11
+ 12
+-13
+ 14
+ 15
++16
+`)
+}
+
+func TestEqualFilesHaveNoDiff(t *testing.T) {
+	check.Eq(t, unifiedDiff("f.pas", []byte("a\n"), []byte("a\n")), []byte(nil))
+}
+
+func TestDiffOfFilesWithOnlyDifferentLines(t *testing.T) {
+	// Converting a CRLF file to LF changes every line. The diff must not need
+	// memory quadratic in the number of lines. The last line of b has no line
+	// break, so the lines have one in common and the search runs.
+	var a, b []string
+	for i := 0; i < 5000; i++ {
+		a = append(a, "line\r\n")
+		b = append(b, "line\n")
+	}
+	a = append(a, "line")
+	b = append(b, "line")
+	edits := diffLines(a, b)
+	check.Eq(t, len(edits), 10001)
+	check.Eq(t, edits[10000], edit{' ', "line"})
+	for i, e := range edits[:10000] {
+		if e.kind == ' ' || e.kind == '-' && e.line != a[0] || e.kind == '+' && e.line != b[0] {
+			t.Fatalf("edit %d is %c %q", i, e.kind, e.line)
+		}
+	}
+}
+
+func TestDiffFindsTheShortestEditScript(t *testing.T) {
+	a := []string{"a", "b", "c", "a", "b", "b", "a"}
+	b := []string{"c", "b", "a", "b", "a", "c"}
+	var from, to []string
+	changes := 0
+	for _, e := range diffLines(a, b) {
+		if e.kind != '+' {
+			from = append(from, e.line)
+		}
+		if e.kind != '-' {
+			to = append(to, e.line)
+		}
+		if e.kind != ' ' {
+			changes++
+		}
+	}
+	check.Eq(t, from, a)
+	check.Eq(t, to, b)
+	check.Eq(t, changes, 5)
+}
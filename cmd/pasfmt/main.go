@@ -0,0 +1,181 @@
+// pasfmt formats Delphi source code.
+//
+// Without file arguments, it reads code from stdin and writes the formatted
+// code to stdout. With files, it writes the formatted code of each file to
+// stdout, unless -w, -d or -l is given. The code keeps its encoding: byte
+// order marks, UTF-16 and ANSI code pages are written the way they were read.
+//
+// Usage:
+//
+//     pasfmt [flags] [files...]
+//
+// Flags:
+//
+//     -w              write the result back to the source file
+//     -d              print a diff instead of the formatted code
+//     -l              list the files whose formatting differs
+//     -indent string  indentation of one level, "tab" for tabs (default "  ")
+//     -keywords case  lower, upper or title (default lower)
+//     -colon          write a space before colons, "A : Integer"
+//     -equals         write spaces around equal signs, "C = class" (default true)
+//     -begin style    put "begin" after "then", "do" and "else" on a new line,
+//                     on the same line or keep it: newline, sameline or keep
+//                     (default newline)
+//     -width int      wrap parameter lists longer than this, 0 to never wrap
+//                     (default 80)
+//     -lf             end lines with "\n" instead of "\r\n"
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gonutz/pas"
+	"github.com/gonutz/pas/printer"
+)
+
+var (
+	write    = flag.Bool("w", false, "write the result back to the source file")
+	diff     = flag.Bool("d", false, "print a diff instead of the formatted code")
+	list     = flag.Bool("l", false, "list the files whose formatting differs")
+	indent   = flag.String("indent", "  ", `indentation of one level, "tab" for tabs`)
+	keywords = flag.String("keywords", "lower", "keyword case: lower, upper or title")
+	colon    = flag.Bool("colon", false, `write a space before colons, "A : Integer"`)
+	equals   = flag.Bool("equals", true, `write spaces around equal signs, "C = class"`)
+	begin    = flag.String("begin", "newline", `placement of "begin" after "then", "do" and "else": newline, sameline or keep`)
+	width    = flag.Int("width", 80, "wrap parameter lists longer than this, 0 to never wrap")
+	lf       = flag.Bool("lf", false, `end lines with "\n" instead of "\r\n"`)
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: pasfmt [flags] [files...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	config, err := makeConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pasfmt:", err)
+		os.Exit(2)
+	}
+
+	if flag.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "pasfmt: cannot use -w with standard input")
+			os.Exit(2)
+		}
+		if err := processFile(config, "<standard input>", os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	exitCode := 0
+	for _, path := range flag.Args() {
+		if err := processPath(config, path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+		}
+	}
+	os.Exit(exitCode)
+}
+
+func makeConfig() (printer.Config, error) {
+	c := printer.DefaultConfig
+	c.Indent = *indent
+	if c.Indent == "tab" {
+		c.Indent = "\t"
+	}
+	switch *keywords {
+	case "lower":
+		c.Keywords = printer.LowerCase
+	case "upper":
+		c.Keywords = printer.UpperCase
+	case "title":
+		c.Keywords = printer.TitleCase
+	default:
+		return c, fmt.Errorf("unknown keyword case %q", *keywords)
+	}
+	c.SpaceBeforeColon = *colon
+	c.SpaceAroundEquals = *equals
+	switch *begin {
+	case "newline":
+		c.Begin = printer.BeginOnNewLine
+	case "sameline":
+		c.Begin = printer.BeginOnSameLine
+	case "keep":
+		c.Begin = printer.KeepBegin
+	default:
+		return c, fmt.Errorf("unknown begin style %q", *begin)
+	}
+	c.MaxLineLength = *width
+	if *lf {
+		c.LineBreak = "\n"
+	}
+	return c, nil
+}
+
+func processPath(config printer.Config, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return processFile(config, path, f, os.Stdout)
+}
+
+// processFile formats the code from r. The formatted code is written in the
+// encoding of the original code, so units keep their byte order mark or ANSI
+// code page.
+func processFile(config printer.Config, name string, r io.Reader, out io.Writer) error {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	// Positions in parse errors contain the file name.
+	opts := pas.Options{Filename: name}
+	code, encoding, err := opts.Decode(src)
+	if err != nil {
+		return err
+	}
+	f, err := opts.ParseString(code)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := config.Fprint(&buf, f); err != nil {
+		return err
+	}
+	formatted := buf.Bytes()
+	res, err := opts.Encode(buf.String(), encoding)
+	if err != nil {
+		return err
+	}
+
+	changed := !bytes.Equal(src, res)
+	if *list && changed {
+		fmt.Fprintln(out, name)
+	}
+	if *write && changed {
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(name, res, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if *diff {
+		// The diff is of the decoded code, it is printed as UTF-8.
+		out.Write(unifiedDiff(name, []byte(code), formatted))
+	}
+	if !*list && !*write && !*diff {
+		_, err = out.Write(res)
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/pas/printer"
+)
+
+func TestWriteKeepsTheEncoding(t *testing.T) {
+	// The unformatted and formatted code, both with a character that is
+	// encoded differently in each encoding.
+	const code = "unit Ä;interface implementation end."
+	const formatted = "unit Ä;\r\n\r\ninterface\r\n\r\nimplementation\r\n\r\nend.\r\n"
+	utf16LE := func(s string) []byte {
+		var b []byte
+		for _, w := range utf16.Encode([]rune("\uFEFF" + s)) {
+			b = append(b, byte(w), byte(w>>8))
+		}
+		return b
+	}
+	// In Windows-1252, 'Ä' is the byte 0xC4.
+	ansi := func(s string) []byte {
+		return []byte(strings.Replace(s, "Ä", "\xC4", -1))
+	}
+	tests := []struct {
+		name      string
+		code      []byte
+		formatted []byte
+	}{
+		{"UTF-8 BOM", []byte("\xEF\xBB\xBF" + code), []byte("\xEF\xBB\xBF" + formatted)},
+		{"ANSI", ansi(code), ansi(formatted)},
+		{"UTF-16", utf16LE(code), utf16LE(formatted)},
+	}
+
+	*write = true
+	defer func() { *write = false }()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "U.pas")
+			check.Eq(t, os.WriteFile(path, test.code, 0666), nil)
+			check.Eq(t, processPath(printer.DefaultConfig, path), nil)
+			have, err := os.ReadFile(path)
+			check.Eq(t, err, nil)
+			check.Eq(t, have, test.formatted)
+		})
+	}
+}
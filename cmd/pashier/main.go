@@ -0,0 +1,123 @@
+// pashier prints the class hierarchy of Delphi units.
+//
+// The units are either given as files or as a .dproj or .dpr project, in which
+// case all units of the project are loaded. By default, the classes and
+// interfaces are printed as trees, each type indented below its parent.
+//
+// Usage:
+//
+//     pashier [flags] files...
+//     pashier [flags] project.dproj
+//
+// Flags:
+//
+//     -ancestors Name     print the ancestors of the type, nearest first
+//     -descendants Name   print all descendants of the type
+//     -check              print problems like unimplemented interface methods
+//                         and exit with code 1 if there are any
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gonutz/pas"
+)
+
+var (
+	ancestors   = flag.String("ancestors", "", "print the ancestors of the given type")
+	descendants = flag.String("descendants", "", "print the descendants of the given type")
+	checkFlag   = flag.Bool("check", false, "print problems in the hierarchy")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: pashier [flags] files... | project.dproj")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	info, err := load(flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if info == nil {
+			os.Exit(1)
+		}
+	}
+	h := pas.NewHierarchy(info)
+
+	switch {
+	case *ancestors != "":
+		printTypes(h, *ancestors, (*pas.HierarchyType).Ancestors)
+	case *descendants != "":
+		printTypes(h, *descendants, (*pas.HierarchyType).Descendants)
+	case *checkFlag:
+		errs := h.Check()
+		for _, err := range errs {
+			fmt.Println(err)
+		}
+		if len(errs) > 0 {
+			os.Exit(1)
+		}
+	default:
+		for _, t := range h.Types {
+			if t.Parent == nil {
+				printTree(t, 0)
+			}
+		}
+	}
+}
+
+// load parses the given files or the single project and resolves all names.
+// Syntax errors are returned together with what could be parsed.
+func load(args []string) (*pas.Info, error) {
+	if len(args) == 1 {
+		ext := strings.ToLower(filepath.Ext(args[0]))
+		if ext == ".dproj" || ext == ".dpr" {
+			p, err := pas.LoadProject(args[0])
+			if p == nil {
+				return nil, err
+			}
+			return p.ResolveNames(), err
+		}
+	}
+	files, err := pas.ParseAll(args)
+	if _, isSyntaxError := err.(pas.ErrorList); err != nil && !isSyntaxError {
+		return nil, err
+	}
+	return pas.ResolveNames(files), err
+}
+
+func printTypes(h *pas.Hierarchy, name string, list func(*pas.HierarchyType) []*pas.HierarchyType) {
+	t := h.Find(name)
+	if t == nil {
+		fmt.Fprintln(os.Stderr, "unknown type", name)
+		os.Exit(1)
+	}
+	for _, t := range list(t) {
+		fmt.Println(t.Name())
+	}
+}
+
+// printTree prints the type and, indented below it, its children. Types whose
+// parent is unknown show the parent's name in parentheses.
+func printTree(t *pas.HierarchyType, depth int) {
+	line := strings.Repeat("  ", depth) + t.Name()
+	if t.Parent == nil && t.ParentName != "" {
+		line += " (" + t.ParentName + ")"
+	}
+	if len(t.InterfaceNames) > 0 {
+		line += " implements " + strings.Join(t.InterfaceNames, ", ")
+	}
+	fmt.Println(line)
+	for _, c := range t.Children {
+		printTree(c, depth+1)
+	}
+}
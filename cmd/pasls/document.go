@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gonutz/pas"
+)
+
+// document is a text document that is open in the editor, or a unit that was
+// loaded from disk because an open document uses it.
+type document struct {
+	uri     string
+	version int
+	text    string
+	// lines are the byte offsets of the line starts in text.
+	lines []int
+	// file is the syntax tree of text. If there are syntax errors, it has
+	// everything that could be parsed and errs has the errors.
+	file *pas.File
+	errs pas.ErrorList
+	// parsed keeps the syntax tree up to date with changes, it only parses
+	// the declarations that changed.
+	parsed *pas.Incremental
+}
+
+func newDocument(uri string, version int, text string) *document {
+	d := &document{uri: uri, version: version}
+	d.setText(text)
+	return d
+}
+
+func (d *document) setText(text string) {
+	d.parsed, _ = pas.ParseIncremental(text)
+	d.update()
+}
+
+// update takes the text and syntax tree from d.parsed.
+func (d *document) update() {
+	d.text = d.parsed.Code()
+	d.lines = lineStarts(d.text)
+	d.file = d.parsed.File
+	d.errs = nil
+	if list, ok := d.parsed.Err().(pas.ErrorList); ok {
+		d.errs = list
+	}
+}
+
+// apply applies the changes of a didChange notification in order, each one
+// to the result of the previous ones.
+func (d *document) apply(changes []contentChange) error {
+	for _, c := range changes {
+		if c.Range == nil {
+			d.setText(c.Text)
+			continue
+		}
+		start, end := d.offset(c.Range.Start), d.offset(c.Range.End)
+		if end < start {
+			return errors.New("invalid range in change")
+		}
+		d.parsed.Edit(pas.TextEdit{Pos: start, End: end, Text: c.Text})
+		d.update()
+	}
+	return nil
+}
+
+func lineStarts(text string) []int {
+	lines := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return lines
+}
+
+// offset converts an LSP position to a byte offset in the text. Positions
+// past the end of a line are clamped to the line end, positions after the last
+// line to the end of the text.
+func (d *document) offset(p position) int {
+	if p.Line < 0 {
+		return 0
+	}
+	if p.Line >= len(d.lines) {
+		return len(d.text)
+	}
+	i := d.lines[p.Line]
+	for units := 0; units < p.Character && i < len(d.text); {
+		r, size := utf8.DecodeRuneInString(d.text[i:])
+		if r == '\n' || r == '\r' && strings.HasPrefix(d.text[i:], "\r\n") {
+			break
+		}
+		units += utf16Len(r)
+		i += size
+	}
+	return i
+}
+
+// position converts a byte offset in the text to an LSP position.
+func (d *document) position(offset int) position {
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+	line := 0
+	for line+1 < len(d.lines) && d.lines[line+1] <= offset {
+		line++
+	}
+	char := 0
+	for _, r := range d.text[d.lines[line]:offset] {
+		char += utf16Len(r)
+	}
+	return position{Line: line, Character: char}
+}
+
+func (d *document) rangeOf(pos, end pas.Position) lspRange {
+	return lspRange{Start: d.position(pos.Offset), End: d.position(end.Offset)}
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// source returns the code from pos to end.
+func (d *document) source(pos, end pas.Position) string {
+	if pos.Offset < 0 || end.Offset > len(d.text) || end.Offset < pos.Offset {
+		return ""
+	}
+	return d.text[pos.Offset:end.Offset]
+}
+
+// expressionAt returns the qualified name that ends with the identifier at the
+// offset, e.g. "Edit1.Text" if the offset is in "Text" of "Edit1.Text". It
+// returns "" if there is no identifier at the offset.
+func (d *document) expressionAt(offset int) string {
+	start, end := offset, offset
+	for end < len(d.text) && isWordByte(d.text[end]) {
+		end++
+	}
+	for start > 0 && (isWordByte(d.text[start-1]) || d.text[start-1] == '.') {
+		start--
+	}
+	// Escaped reserved words like &Type are declared without the '&'.
+	expr := strings.Replace(d.text[start:end], "&", "", -1)
+	return strings.Trim(expr, ".")
+}
+
+// expressionBefore returns the qualified name in front of a '.' that ends
+// right before the offset, e.g. "Edit1" for "Edit1.|" or "Edit1.Te|".
+func (d *document) expressionBefore(offset int) (expr, prefix string, ok bool) {
+	i := offset
+	for i > 0 && isWordByte(d.text[i-1]) {
+		i--
+	}
+	prefix = d.text[i:offset]
+	if i < 2 || d.text[i-1] != '.' {
+		return "", prefix, false
+	}
+	return d.expressionAt(i - 2), prefix, true
+}
+
+// isWordByte reports whether the byte can be part of an identifier. All bytes
+// of multi-byte UTF-8 characters count as well, since Delphi identifiers can
+// contain Unicode letters.
+func isWordByte(b byte) bool {
+	return b == '_' || b == '&' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' ||
+		'0' <= b && b <= '9' || b >= 0x80
+}
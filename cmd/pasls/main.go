@@ -0,0 +1,34 @@
+// pasls is a language server for Delphi. It speaks the Language Server
+// Protocol over stdin and stdout, so editors like VS Code or Neovim can use it
+// for Delphi units.
+//
+// It offers:
+//
+//     - diagnostics for syntax errors
+//     - document symbols, an outline of classes, their members, variables and
+//       routines
+//     - go to definition
+//     - hover with the declaration and its doc comment
+//     - completion of members after a '.' and of the names in scope
+//
+// Documents are synchronized incrementally. Names are resolved across all
+// open documents. Units that an open document uses but which are not open are
+// loaded from the directory of that document.
+//
+// Usage:
+//
+//     pasls
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	code, err := newServer(os.Stdin, os.Stdout).run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pasls:", err)
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,588 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gonutz/pas"
+)
+
+type server struct {
+	conn *conn
+	// docs are the open documents by URI.
+	docs map[string]*document
+	// disk are the units that open documents use but which are not open
+	// themselves, by path. They are parsed again when they change on disk.
+	disk     map[string]*diskUnit
+	shutdown bool
+}
+
+type diskUnit struct {
+	doc     *document
+	modTime time.Time
+}
+
+func newServer(r io.Reader, w io.Writer) *server {
+	return &server{
+		conn: newConn(r, w),
+		docs: make(map[string]*document),
+		disk: make(map[string]*diskUnit),
+	}
+}
+
+// run handles messages until the client sends "exit" or closes the
+// connection. It returns the exit code, which is 0 only if the client sent
+// "shutdown" before "exit".
+func (s *server) run() (int, error) {
+	for {
+		msg, err := s.conn.read()
+		if err == io.EOF {
+			return 1, nil
+		}
+		if err != nil {
+			return 1, err
+		}
+		if msg.Error != nil {
+			// The message was not valid JSON.
+			if err := s.conn.reply(nil, nil, msg.Error); err != nil {
+				return 1, err
+			}
+			continue
+		}
+		if msg.Method == "exit" {
+			if s.shutdown {
+				return 0, nil
+			}
+			return 1, nil
+		}
+		result, rerr := s.handle(msg)
+		if msg.ID != nil {
+			if err := s.conn.reply(msg.ID, result, rerr); err != nil {
+				return 1, err
+			}
+		}
+	}
+}
+
+func (s *server) handle(msg *message) (interface{}, *responseError) {
+	switch msg.Method {
+	case "initialize":
+		return initializeResult{
+			Capabilities: serverCapabilities{
+				TextDocumentSync: textDocumentSyncOptions{
+					OpenClose: true,
+					Change:    syncIncremental,
+				},
+				DocumentSymbolProvider: true,
+				DefinitionProvider:     true,
+				HoverProvider:          true,
+				CompletionProvider:     completionOptions{TriggerCharacters: []string{"."}},
+			},
+			ServerInfo: serverInfo{Name: "pasls"},
+		}, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var p didOpenParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, invalid(err)
+		}
+		d := newDocument(p.TextDocument.URI, p.TextDocument.Version, p.TextDocument.Text)
+		s.docs[d.uri] = d
+		s.publishDiagnostics(d)
+		return nil, nil
+	case "textDocument/didChange":
+		var p didChangeParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, invalid(err)
+		}
+		d := s.docs[p.TextDocument.URI]
+		if d == nil {
+			return nil, nil
+		}
+		if err := d.apply(p.ContentChanges); err != nil {
+			return nil, invalid(err)
+		}
+		d.version = p.TextDocument.Version
+		s.publishDiagnostics(d)
+		return nil, nil
+	case "textDocument/didClose":
+		var p didCloseParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, invalid(err)
+		}
+		delete(s.docs, p.TextDocument.URI)
+		// The errors of closed documents are not shown anymore.
+		s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+			URI:         p.TextDocument.URI,
+			Diagnostics: []diagnostic{},
+		})
+		return nil, nil
+	case "textDocument/documentSymbol":
+		var p documentSymbolParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, invalid(err)
+		}
+		d := s.docs[p.TextDocument.URI]
+		if d == nil {
+			return []documentSymbol{}, nil
+		}
+		return documentSymbols(d), nil
+	case "textDocument/definition", "textDocument/hover", "textDocument/completion":
+		var p textDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, invalid(err)
+		}
+		d := s.docs[p.TextDocument.URI]
+		if d == nil {
+			return nil, nil
+		}
+		a := s.analyze()
+		offset := d.offset(p.Position)
+		switch msg.Method {
+		case "textDocument/definition":
+			return a.definition(d, offset), nil
+		case "textDocument/hover":
+			return a.hover(d, offset), nil
+		default:
+			return a.completion(d, offset), nil
+		}
+	}
+	if msg.ID != nil {
+		return nil, &responseError{Code: methodNotFound, Message: "method not supported: " + msg.Method}
+	}
+	// Other notifications like $/cancelRequest are ignored.
+	return nil, nil
+}
+
+func invalid(err error) *responseError {
+	return &responseError{Code: invalidParams, Message: err.Error()}
+}
+
+func (s *server) publishDiagnostics(d *document) {
+	diagnostics := []diagnostic{}
+	for _, e := range d.errs {
+		r := d.rangeOf(e.Pos, e.Pos)
+		diagnostics = append(diagnostics, diagnostic{
+			Range:    r,
+			Severity: severityError,
+			Source:   "pasls",
+			Message:  e.Expected + " expected but was " + e.Found,
+		})
+	}
+	s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         d.uri,
+		Version:     d.version,
+		Diagnostics: diagnostics,
+	})
+}
+
+// documentSymbols returns the outline of the document: its classes with their
+// members, its variables and its routines.
+func documentSymbols(d *document) []documentSymbol {
+	symbols := []documentSymbol{}
+	add := func(list *[]documentSymbol, name, detail string, kind int, n pas.Node) {
+		pos, end := n.Span()
+		r := d.rangeOf(pos, end)
+		*list = append(*list, documentSymbol{
+			Name:           name,
+			Detail:         detail,
+			Kind:           kind,
+			Range:          r,
+			SelectionRange: r,
+		})
+	}
+	for _, section := range d.file.Sections {
+		for _, b := range section.Blocks {
+			switch b := b.(type) {
+			case pas.TypeBlock:
+				for _, t := range b {
+					c, ok := t.(pas.Class)
+					if !ok {
+						continue
+					}
+					kind := symbolClass
+					if c.IsInterface {
+						kind = symbolInterface
+					} else if c.IsRecord {
+						kind = symbolStruct
+					}
+					add(&symbols, c.Name, strings.Join(c.SuperClasses, ", "), kind, c)
+					members := &symbols[len(symbols)-1].Children
+					for _, cs := range c.Sections {
+						for _, m := range cs.Members {
+							switch m := m.(type) {
+							case pas.Variable:
+								add(members, m.Name, m.Type, symbolField, m)
+							case pas.Function:
+								add(members, m.Name, m.Returns, symbolMethod, m)
+							case pas.Property:
+								add(members, m.Name, m.Type, symbolProperty, m)
+							}
+						}
+					}
+				}
+			case pas.VarBlock:
+				for _, v := range b {
+					add(&symbols, v.Name, v.Type, symbolVariable, v)
+				}
+			case pas.ConstBlock:
+				for _, c := range b {
+					add(&symbols, c.Name, c.Type, symbolConstant, c)
+				}
+			case pas.Routine:
+				if b.ClassName != "" {
+					add(&symbols, b.ClassName+"."+b.Header.Name, b.Header.Returns, symbolMethod, b)
+				} else {
+					add(&symbols, b.Header.Name, b.Header.Returns, symbolFunction, b)
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+// analysis is the result of name resolution over the open documents and the
+// units they use.
+type analysis struct {
+	info *pas.Info
+	docs map[*pas.File]*document
+}
+
+// analyze resolves the names in all open documents. Units that they use which
+// are not open are loaded from the directory of the document that uses them.
+func (s *server) analyze() *analysis {
+	a := &analysis{docs: make(map[*pas.File]*document)}
+	var files []*pas.File
+	known := make(map[string]bool)
+	var uris []string
+	for uri := range s.docs {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris) // Keep the results independent of map order.
+	for _, uri := range uris {
+		d := s.docs[uri]
+		files = append(files, d.file)
+		a.docs[d.file] = d
+		known[pas.FoldIdentifier(d.file.Name)] = true
+	}
+	for i := 0; i < len(files); i++ {
+		dir := ""
+		if path := uriToPath(a.docs[files[i]].uri); path != "" {
+			dir = filepath.Dir(path)
+		}
+		for _, section := range files[i].Sections {
+			for _, unit := range section.Uses {
+				if known[pas.FoldIdentifier(unit)] || dir == "" {
+					continue
+				}
+				known[pas.FoldIdentifier(unit)] = true
+				if d := s.loadUnit(dir, unit); d != nil {
+					files = append(files, d.file)
+					a.docs[d.file] = d
+				}
+			}
+		}
+	}
+	a.info = pas.ResolveNames(files)
+	return a
+}
+
+// loadUnit finds the file of the unit in the directory, ignoring case, and
+// parses it. Like pas.ResolveNames, it tries the pas.DefaultUnitScopeNames in
+// front of the unit name if there is no file of that name. It returns nil if
+// there is no such file or if it cannot be decoded.
+func (s *server) loadUnit(dir, unit string) *document {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	names := []string{unit}
+	for _, scope := range pas.DefaultUnitScopeNames {
+		names = append(names, scope+"."+unit)
+	}
+	for _, name := range names {
+		if d := s.loadFile(dir, entries, name+".pas"); d != nil {
+			return d
+		}
+	}
+	return nil
+}
+
+func (s *server) loadFile(dir string, entries []os.DirEntry, filename string) *document {
+	for _, e := range entries {
+		if e.IsDir() || !strings.EqualFold(e.Name(), filename) {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		info, err := e.Info()
+		if err != nil {
+			return nil
+		}
+		if u := s.disk[path]; u != nil && u.modTime.Equal(info.ModTime()) {
+			return u.doc
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		code, _, err := pas.Options{}.Decode(data)
+		if err != nil {
+			return nil
+		}
+		d := newDocument(pathToURI(path), 0, code)
+		s.disk[path] = &diskUnit{doc: d, modTime: info.ModTime()}
+		return d
+	}
+	return nil
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func pathToURI(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // Windows paths like C:/x.
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+func (a *analysis) definition(d *document, offset int) interface{} {
+	sym := a.symbolAt(d, offset)
+	if sym == nil || sym.Decl == nil {
+		return nil
+	}
+	target := a.docs[sym.File]
+	if target == nil {
+		return nil
+	}
+	pos, end := sym.Decl.Span()
+	return location{URI: target.uri, Range: target.rangeOf(pos, end)}
+}
+
+func (a *analysis) hover(d *document, offset int) interface{} {
+	sym := a.symbolAt(d, offset)
+	if sym == nil {
+		return nil
+	}
+	text := "```pascal\n" + a.signature(sym) + "\n```"
+	if doc := docComment(sym.Decl); doc != "" {
+		text += "\n\n" + doc
+	}
+	return hover{Contents: markupContent{Kind: "markdown", Value: text}}
+}
+
+func (a *analysis) completion(d *document, offset int) interface{} {
+	list := completionList{Items: []completionItem{}}
+	expr, prefix, dotted := d.expressionBefore(offset)
+	var candidates []*pas.Symbol
+	if dotted {
+		sym := a.resolve(d, offset, expr)
+		if scope := a.info.TypeScope(sym); scope != nil {
+			candidates = scope.Members()
+		}
+	} else {
+		candidates = a.visible(d, offset)
+	}
+	seen := make(map[string]bool)
+	for _, sym := range candidates {
+		key := pas.FoldIdentifier(sym.Name)
+		if seen[key] || !strings.HasPrefix(key, pas.FoldIdentifier(prefix)) {
+			continue
+		}
+		seen[key] = true
+		list.Items = append(list.Items, completionItem{
+			Label:  sym.Name,
+			Kind:   completionKind(sym.Kind),
+			Detail: a.signature(sym),
+		})
+	}
+	return list
+}
+
+// symbolAt returns the symbol that the identifier at the offset refers to.
+func (a *analysis) symbolAt(d *document, offset int) *pas.Symbol {
+	expr := d.expressionAt(offset)
+	if expr == "" {
+		return nil
+	}
+	return a.resolve(d, offset, expr)
+}
+
+// resolve finds the symbol of a qualified name at the offset. The name is
+// either a qualified name like "Vcl.Forms.TForm" or "TFoo.Create" or a chain
+// of fields, properties and functions, like "Edit1.Text".
+func (a *analysis) resolve(d *document, offset int, expr string) *pas.Symbol {
+	if sym := a.lookup(d, offset, expr); sym != nil {
+		return sym
+	}
+	parts := strings.Split(expr, ".")
+	sym := a.lookup(d, offset, parts[0])
+	for _, name := range parts[1:] {
+		scope := a.info.TypeScope(sym)
+		if scope == nil {
+			return nil
+		}
+		sym = nil
+		for _, m := range scope.Members() {
+			if pas.SameIdentifier(m.Name, name) {
+				sym = m
+				break
+			}
+		}
+	}
+	return sym
+}
+
+// lookup looks up the name in the scope at the offset. In routine bodies, this
+// is the routine scope with the parameters, local declarations and, in methods,
+// the class members, or the scope of a with statement. Self in a method is
+// the class.
+func (a *analysis) lookup(d *document, offset int, name string) *pas.Symbol {
+	scope := a.info.ScopeAt(d.file, offset)
+	if scope == nil {
+		return nil
+	}
+	if pas.SameIdentifier(name, "Self") {
+		if r := routineAt(d.file, offset); r != nil && r.ClassName != "" {
+			return scope.Lookup(r.ClassName)
+		}
+	}
+	return scope.Lookup(name)
+}
+
+// visible returns the symbols that can be used without qualification at the
+// offset, innermost first. Symbols of used units are not included.
+func (a *analysis) visible(d *document, offset int) []*pas.Symbol {
+	scope := a.info.ScopeAt(d.file, offset)
+	if scope == nil {
+		return nil
+	}
+	var list []*pas.Symbol
+	for ; scope != nil; scope = scope.Parent {
+		list = append(list, scope.Members()...)
+	}
+	return append(list, a.info.System.Symbols...)
+}
+
+// signature returns the declaration of the symbol as it is written in the
+// code, with white space collapsed.
+func (a *analysis) signature(sym *pas.Symbol) string {
+	switch d := sym.Decl.(type) {
+	case nil:
+		return "type " + sym.Name
+	case pas.Class:
+		kind := "class"
+		if d.IsInterface {
+			kind = "interface"
+		} else if d.IsPacked {
+			kind = "packed record"
+		} else if d.IsRecord {
+			kind = "record"
+		}
+		s := "type " + d.Name + " = " + kind
+		if len(d.SuperClasses) > 0 {
+			s += "(" + strings.Join(d.SuperClasses, ", ") + ")"
+		}
+		return s
+	}
+	doc := a.docs[sym.File]
+	if doc == nil {
+		return sym.Name
+	}
+	pos, end := sym.Decl.Span()
+	return strings.Join(strings.Fields(doc.source(pos, end)), " ")
+}
+
+// docComment returns the documentation of the declaration as Markdown. XML
+// documentation comments are formatted, other comments are returned as text.
+func docComment(n pas.Node) string {
+	var g *pas.CommentGroup
+	switch n := n.(type) {
+	case pas.Class:
+		g = n.Doc
+	case pas.Variable:
+		g = n.Doc
+	case pas.Constant:
+		g = n.Doc
+	case pas.Function:
+		g = n.Doc
+	case pas.Property:
+		g = n.Doc
+	}
+	text := g.Text()
+	if !strings.Contains(text, "<summary>") {
+		return text
+	}
+	doc, err := pas.ParseXMLDoc(g)
+	if err != nil {
+		return text
+	}
+	var parts []string
+	if doc.Summary != "" {
+		parts = append(parts, doc.Summary)
+	}
+	if len(doc.Params) > 0 {
+		var params []string
+		for _, p := range doc.Params {
+			params = append(params, "- `"+p.Name+"`: "+p.Text)
+		}
+		parts = append(parts, strings.Join(params, "\n"))
+	}
+	if doc.Returns != "" {
+		parts = append(parts, "Returns: "+doc.Returns)
+	}
+	if doc.Remarks != "" {
+		parts = append(parts, doc.Remarks)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func completionKind(k pas.SymbolKind) int {
+	switch k {
+	case pas.ClassSymbol, pas.TypeSymbol:
+		return completionClass
+	case pas.InterfaceSymbol:
+		return completionInterface
+	case pas.RecordSymbol:
+		return completionStruct
+	case pas.ConstantSymbol:
+		return completionConstant
+	case pas.FieldSymbol:
+		return completionField
+	case pas.MethodSymbol:
+		return completionMethod
+	case pas.RoutineSymbol:
+		return completionFunction
+	case pas.PropertySymbol:
+		return completionProperty
+	}
+	return completionVariable
+}
+
+// routineAt returns the routine whose body contains the offset, or nil.
+func routineAt(f *pas.File, offset int) *pas.Routine {
+	for _, s := range f.Sections {
+		for _, b := range s.Blocks {
+			if r, ok := b.(pas.Routine); ok && r.Pos.Offset <= offset && offset < r.End.Offset {
+				return &r
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/gonutz/check"
+)
+
+// session runs the server on the given messages and returns the messages that
+// it wrote, in order.
+func session(t *testing.T, messages ...string) []map[string]interface{} {
+	var in bytes.Buffer
+	for _, m := range messages {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(m), m)
+	}
+	var out bytes.Buffer
+	code, err := newServer(&in, &out).run()
+	check.Eq(t, err, nil)
+	check.Eq(t, code, 0)
+
+	var replies []map[string]interface{}
+	c := newConn(&out, nil)
+	for {
+		msg, err := c.read()
+		if err != nil {
+			break
+		}
+		data, _ := json.Marshal(msg)
+		var m map[string]interface{}
+		json.Unmarshal(data, &m)
+		replies = append(replies, m)
+	}
+	return replies
+}
+
+func request(id int, method string, params interface{}) string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0", "id": id, "method": method, "params": params,
+	})
+	return string(data)
+}
+
+func notification(method string, params interface{}) string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0", "method": method, "params": params,
+	})
+	return string(data)
+}
+
+func at(uri string, line, char int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"position":     map[string]interface{}{"line": line, "character": char},
+	}
+}
+
+// toJSON returns v as compact JSON, to compare results in one line.
+func toJSON(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func TestLanguageServerSession(t *testing.T) {
+	base := `unit Base;
+interface
+type
+  /// The base of all shapes.
+  TShape = class
+    Name: string;
+    procedure Draw(Canvas: TObject); virtual;
+  end;
+implementation
+end.`
+	main := `unit Main;
+interface
+uses Base;
+type
+  TCircle = class(TShape)
+    Radius: Integer;
+    procedure Draw(Canvas: TObject); override;
+  end;
+implementation
+procedure TCircle.Draw(Canvas: TObject);
+begin
+  Self.Name;
+end;
+end.`
+	// The change breaks and then fixes the "end;" of TCircle.
+	replies := session(t,
+		request(1, "initialize", map[string]interface{}{}),
+		notification("initialized", map[string]interface{}{}),
+		notification("textDocument/didOpen", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": "file:///p/Base.pas", "version": 1, "text": base},
+		}),
+		notification("textDocument/didOpen", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": "file:///p/Main.pas", "version": 1, "text": main},
+		}),
+		notification("textDocument/didChange", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": "file:///p/Main.pas", "version": 2},
+			"contentChanges": []interface{}{
+				map[string]interface{}{
+					"range": map[string]interface{}{
+						"start": map[string]interface{}{"line": 7, "character": 2},
+						"end":   map[string]interface{}{"line": 7, "character": 5},
+					},
+					"text": "emd",
+				},
+			},
+		}),
+		notification("textDocument/didChange", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": "file:///p/Main.pas", "version": 3},
+			"contentChanges": []interface{}{
+				map[string]interface{}{
+					"range": map[string]interface{}{
+						"start": map[string]interface{}{"line": 7, "character": 3},
+						"end":   map[string]interface{}{"line": 7, "character": 4},
+					},
+					"text": "n",
+				},
+			},
+		}),
+		request(2, "textDocument/definition", at("file:///p/Main.pas", 4, 20)),
+		request(3, "textDocument/hover", at("file:///p/Main.pas", 4, 20)),
+		request(4, "textDocument/completion", at("file:///p/Main.pas", 11, 7)),
+		request(5, "textDocument/documentSymbol", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": "file:///p/Main.pas"},
+		}),
+		request(6, "textDocument/definition", at("file:///p/Main.pas", 11, 8)),
+		request(7, "textDocument/formatting", map[string]interface{}{}),
+		request(8, "shutdown", nil),
+		notification("exit", nil),
+	)
+	check.Eq(t, len(replies), 12)
+
+	check.Eq(t, toJSON(replies[0]["result"].(map[string]interface{})["capabilities"]),
+		`{"completionProvider":{"triggerCharacters":["."]},"definitionProvider":true,`+
+			`"documentSymbolProvider":true,"hoverProvider":true,`+
+			`"textDocumentSync":{"change":2,"openClose":true}}`)
+
+	// Diagnostics are published when opening and after each change.
+	diagnostics := func(i int) string {
+		check.Eq(t, replies[i]["method"], "textDocument/publishDiagnostics")
+		return toJSON(replies[i]["params"].(map[string]interface{})["diagnostics"])
+	}
+	check.Eq(t, diagnostics(1), "[]")
+	check.Eq(t, diagnostics(2), "[]")
+	check.Eq(t, diagnostics(3), `[`+
+		`{"message":"token \":\" expected but was token \";\"",`+
+		`"range":{"end":{"character":5,"line":7},"start":{"character":5,"line":7}},`+
+		`"severity":1,"source":"pasls"},`+
+		`{"message":"keyword \"end\" expected but was word \"implementation\"",`+
+		`"range":{"end":{"character":0,"line":8},"start":{"character":0,"line":8}},`+
+		`"severity":1,"source":"pasls"}]`)
+	check.Eq(t, diagnostics(4), "[]")
+
+	check.Eq(t, toJSON(replies[5]["result"]),
+		`{"range":{"end":{"character":6,"line":7},"start":{"character":2,"line":4}},"uri":"file:///p/Base.pas"}`)
+
+	hover := replies[6]["result"].(map[string]interface{})["contents"].(map[string]interface{})
+	check.Eq(t, hover["value"], "```pascal\ntype TShape = class\n```\n\nThe base of all shapes.")
+
+	var labels []string
+	for _, item := range replies[7]["result"].(map[string]interface{})["items"].([]interface{}) {
+		labels = append(labels, item.(map[string]interface{})["label"].(string))
+	}
+	check.Eq(t, labels, []string{"Radius", "Draw", "Name"})
+
+	symbols := replies[8]["result"].([]interface{})
+	check.Eq(t, len(symbols), 2)
+	check.Eq(t, symbols[0].(map[string]interface{})["name"], "TCircle")
+	check.Eq(t, len(symbols[0].(map[string]interface{})["children"].([]interface{})), 2)
+	check.Eq(t, symbols[1].(map[string]interface{})["name"], "TCircle.Draw")
+
+	// Self is the class of the method.
+	check.Eq(t, toJSON(replies[9]["result"]),
+		`{"range":{"end":{"character":17,"line":5},"start":{"character":4,"line":5}},"uri":"file:///p/Base.pas"}`)
+	check.Eq(t, replies[10]["error"].(map[string]interface{})["code"], float64(methodNotFound))
+	check.Eq(t, replies[11]["result"], nil)
+}
+
+func TestLocalsAndUnitsOnDiskAreResolved(t *testing.T) {
+	// Forms is found as Vcl.Forms, which is stored in UTF-16.
+	dir := t.TempDir()
+	forms := "unit Vcl.Forms;\r\ninterface\r\ntype\r\n  TForm = class\r\n    Caption: string;\r\n  end;\r\nimplementation\r\nend."
+	data := []byte{0xFF, 0xFE}
+	for _, c := range utf16.Encode([]rune(forms)) {
+		data = append(data, byte(c), byte(c>>8))
+	}
+	check.Eq(t, os.WriteFile(filepath.Join(dir, "Vcl.Forms.pas"), data, 0666), nil)
+	uri := pathToURI(filepath.Join(dir, "Main.pas"))
+	main := `unit Main;
+interface
+uses Forms;
+implementation
+procedure P(Count: Integer);
+var
+  I, Total: Integer;
+  Form: TForm;
+begin
+  Total := Count;
+  Form.
+end;
+end.`
+	replies := session(t,
+		request(1, "initialize", map[string]interface{}{}),
+		notification("textDocument/didOpen", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri, "version": 1, "text": main},
+		}),
+		request(2, "textDocument/definition", at(uri, 9, 3)),
+		request(3, "textDocument/hover", at(uri, 9, 3)),
+		request(4, "textDocument/completion", at(uri, 9, 11)),
+		request(5, "textDocument/completion", at(uri, 10, 7)),
+		request(6, "textDocument/definition", at(uri, 7, 9)),
+		request(7, "shutdown", nil),
+		notification("exit", nil),
+	)
+	check.Eq(t, len(replies), 8)
+
+	check.Eq(t, toJSON(replies[2]["result"]),
+		`{"range":{"end":{"character":20,"line":6},"start":{"character":2,"line":6}},"uri":"`+uri+`"}`)
+	hover := replies[3]["result"].(map[string]interface{})["contents"].(map[string]interface{})
+	check.Eq(t, hover["value"], "```pascal\nI, Total: Integer;\n```")
+	labels := func(i int) []string {
+		var labels []string
+		for _, item := range replies[i]["result"].(map[string]interface{})["items"].([]interface{}) {
+			labels = append(labels, item.(map[string]interface{})["label"].(string))
+		}
+		return labels
+	}
+	check.Eq(t, labels(4)[0], "Count")
+	check.Eq(t, labels(5), []string{"Caption"})
+	check.Eq(t, toJSON(replies[6]["result"]),
+		`{"range":{"end":{"character":6,"line":5},"start":{"character":2,"line":3}},"uri":"`+
+			pathToURI(filepath.Join(dir, "Vcl.Forms.pas"))+`"}`)
+}
+
+func TestDocumentPositionsAreUTF16(t *testing.T) {
+	d := newDocument("file:///u.pas", 0, "a\r\nä😀b\nc")
+	check.Eq(t, d.offset(position{Line: 1, Character: 3}), len("a\r\nä😀"))
+	check.Eq(t, d.position(len("a\r\nä😀")), position{Line: 1, Character: 3})
+	// Positions past the line end are clamped.
+	check.Eq(t, d.offset(position{Line: 0, Character: 9}), 1)
+	check.Eq(t, d.offset(position{Line: 5, Character: 0}), len(d.text))
+}
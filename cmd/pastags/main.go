@@ -0,0 +1,119 @@
+// pastags writes tag files for the declarations in Delphi units, for jumping to
+// definitions in editors.
+//
+// The units are either given as files or as a .dproj or .dpr project, in which
+// case all units of the project are loaded. Tags are written for units,
+// classes, interfaces, records, methods, properties, fields, global variables,
+// constants and free functions and procedures. Syntax errors are printed to
+// stderr, the tags of everything that could be parsed are still written.
+//
+// Usage:
+//
+//     pastags [flags] files...
+//     pastags [flags] project.dproj
+//
+// Flags:
+//
+//     -format ctags|etags|json   output format: a Universal Ctags file for Vim
+//                                and others, an Emacs TAGS file or a JSON index
+//     -o file                    output file, "-" for stdout, the default is
+//                                "tags" for ctags, "TAGS" for etags and stdout
+//                                for json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gonutz/pas"
+)
+
+var (
+	format = flag.String("format", "ctags", "output format: ctags, etags or json")
+	output = flag.String("o", "", `output file, "-" for stdout`)
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: pastags [flags] files... | project.dproj")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var write func(*pas.TagIndex, io.Writer) error
+	defaultOutput := "-"
+	switch *format {
+	case "ctags":
+		write = (*pas.TagIndex).WriteCtags
+		defaultOutput = "tags"
+	case "etags":
+		write = (*pas.TagIndex).WriteETags
+		defaultOutput = "TAGS"
+	case "json":
+		write = (*pas.TagIndex).WriteJSON
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
+		os.Exit(2)
+	}
+	if *output == "" {
+		*output = defaultOutput
+	}
+
+	index, err := load(flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if index == nil {
+			os.Exit(1)
+		}
+	}
+
+	if *output == "-" {
+		err = write(index, os.Stdout)
+	} else {
+		err = writeFile(*output, index, write)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// load parses the given files or the single project. Syntax errors are
+// returned together with the tags of what could be parsed.
+func load(args []string) (*pas.TagIndex, error) {
+	if len(args) == 1 {
+		ext := strings.ToLower(filepath.Ext(args[0]))
+		if ext == ".dproj" || ext == ".dpr" {
+			p, err := pas.LoadProject(args[0])
+			if p == nil {
+				return nil, err
+			}
+			return p.TagIndex(), err
+		}
+	}
+	files, err := pas.ParseAll(args)
+	if _, isSyntaxError := err.(pas.ErrorList); err != nil && !isSyntaxError {
+		return nil, err
+	}
+	return pas.NewTagIndex(files), err
+}
+
+func writeFile(path string, index *pas.TagIndex, write func(*pas.TagIndex, io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(index, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,476 @@
+package pas
+
+import (
+	"errors"
+	"strings"
+)
+
+// ClassCompletion is what class completion adds to a class, see
+// CompleteClasses.
+type ClassCompletion struct {
+	Class Class
+	// Fields and Methods are the accessors of the class' properties that are
+	// not declared yet. They go into the class' private section, which is
+	// created if the class has none.
+	Fields  []Variable
+	Methods []Function
+	// Routines are empty implementations of the methods that have none,
+	// including the new Methods, in the order of their declarations.
+	Routines []Routine
+}
+
+// CompleteClasses does what class completion in the Delphi IDE does for all
+// classes in the file. It returns one ClassCompletion for each class that is
+// not complete, see Source.CompleteClasses to apply them to the code.
+//
+// A property accessor that is neither declared in the class nor in one of its
+// ancestors in the same file is added as a field, e.g. FName, or as a method
+// if its name starts with "Get" or "Set":
+//
+//     property Name: string read FName write SetName;
+//
+// adds
+//
+//     FName: string;
+//     procedure SetName(const Value: string);
+//
+// Abstract and external methods and methods of interfaces need no
+// implementation.
+func CompleteClasses(f *File) []ClassCompletion {
+	var classes []Class
+	var routines []Routine
+	for _, s := range f.Sections {
+		for _, b := range s.Blocks {
+			switch b := b.(type) {
+			case TypeBlock:
+				for _, t := range b {
+					if c, ok := t.(Class); ok && !c.IsInterface {
+						classes = append(classes, c)
+					}
+				}
+			case Routine:
+				routines = append(routines, b)
+			}
+		}
+	}
+	implemented := func(class, method string) bool {
+		for _, r := range routines {
+			if SameIdentifier(r.ClassName, class) &&
+				SameIdentifier(r.Header.Name, method) {
+				return true
+			}
+		}
+		return false
+	}
+
+	unimplemented := make(map[Position]bool)
+	for _, m := range matchImplementations(f, func(Node, string, ...interface{}) {}) {
+		if m.impl == nil {
+			unimplemented[m.decl.Pos] = true
+		}
+	}
+
+	var completions []ClassCompletion
+	for _, c := range classes {
+		comp := ClassCompletion{Class: c}
+		comp.Fields, comp.Methods = missingAccessors(c, classes)
+
+		// Setters of properties that read from a field assign that field.
+		bodies := make(map[string]string)
+		for _, p := range properties(c) {
+			if isField(c, comp.Fields, p.Read) {
+				bodies[FoldIdentifier(p.Write)] = "  " + p.Read + " := Value;\n"
+			}
+		}
+		addNew := func() {
+			for _, m := range comp.Methods {
+				if implemented(c.Name, m.Name) {
+					continue
+				}
+				var body string
+				if len(m.Parameters) == 1 && m.Returns == "" {
+					body = bodies[FoldIdentifier(m.Name)]
+				}
+				comp.Routines = append(comp.Routines, emptyRoutine(c.Name, m, body))
+			}
+		}
+
+		// The new methods are declared at the end of the private section, so
+		// their implementations go between those of the sections around it.
+		at, exists := privateSection(c)
+		for i, s := range c.Sections {
+			if i == at && !exists {
+				addNew()
+			}
+			for _, m := range s.Members {
+				if f, ok := m.(Function); ok && unimplemented[f.Pos] {
+					comp.Routines = append(comp.Routines, emptyRoutine(c.Name, f, ""))
+				}
+			}
+			if i == at && exists {
+				addNew()
+			}
+		}
+		if at == len(c.Sections) {
+			addNew()
+		}
+
+		if len(comp.Fields)+len(comp.Methods)+len(comp.Routines) > 0 {
+			completions = append(completions, comp)
+		}
+	}
+	return completions
+}
+
+// missingAccessors returns the fields and methods that the properties of c
+// read from or write to, but which are not declared. The classes are used to
+// find the ancestors of c.
+func missingAccessors(c Class, classes []Class) ([]Variable, []Function) {
+	var fields []Variable
+	var methods []Function
+	declared := func(name string) bool {
+		for _, f := range fields {
+			if SameIdentifier(f.Name, name) {
+				return true
+			}
+		}
+		for _, m := range methods {
+			if SameIdentifier(m.Name, name) {
+				return true
+			}
+		}
+		return hasMember(c, classes, name, nil)
+	}
+
+	for _, p := range properties(c) {
+		if p.Type == "" {
+			continue // A redeclared property, it is declared in the parent.
+		}
+		// Properties with an index specifier pass the index to their
+		// accessor methods.
+		params := p.Parameters
+		for _, s := range p.Specifiers {
+			if strings.EqualFold(firstWord(s), "index") {
+				params = append([]Parameter{{Names: []string{"Index"}, Type: "Integer"}}, params...)
+			}
+		}
+
+		if name := p.Read; name != "" && !strings.Contains(name, ".") && !declared(name) {
+			if isAccessorMethod(name, "Get") {
+				methods = append(methods, Function{
+					Name:       name,
+					Parameters: params,
+					Returns:    p.Type,
+				})
+			} else {
+				fields = append(fields, Variable{Name: name, Type: p.Type})
+			}
+		}
+		if name := p.Write; name != "" && !strings.Contains(name, ".") && !declared(name) {
+			if isAccessorMethod(name, "Set") {
+				value := Parameter{Names: []string{"Value"}, Type: p.Type, Qualifier: Const}
+				methods = append(methods, Function{
+					Name:       name,
+					Parameters: append(append([]Parameter{}, params...), value),
+				})
+			} else {
+				fields = append(fields, Variable{Name: name, Type: p.Type})
+			}
+		}
+	}
+	return fields, methods
+}
+
+// hasMember reports whether the class or one of its ancestors among the
+// classes declares a member of the given name.
+func hasMember(c Class, classes []Class, name string, visited map[string]bool) bool {
+	for _, s := range c.Sections {
+		for _, m := range s.Members {
+			if SameIdentifier(memberName(m), name) {
+				return true
+			}
+		}
+	}
+	if visited == nil {
+		visited = make(map[string]bool)
+	}
+	visited[FoldIdentifier(c.Name)] = true
+	if len(c.SuperClasses) == 0 || visited[FoldIdentifier(c.SuperClasses[0])] {
+		return false
+	}
+	for _, parent := range classes {
+		if SameIdentifier(parent.Name, c.SuperClasses[0]) {
+			return hasMember(parent, classes, name, visited)
+		}
+	}
+	return false
+}
+
+// isField reports whether the name is a field of c or one of the new fields.
+func isField(c Class, fields []Variable, name string) bool {
+	for _, f := range fields {
+		if SameIdentifier(f.Name, name) {
+			return true
+		}
+	}
+	for _, s := range c.Sections {
+		for _, m := range s.Members {
+			if v, ok := m.(Variable); ok && SameIdentifier(v.Name, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isAccessorMethod reports whether the name is the prefix, e.g. "Get",
+// followed by the name of the property.
+func isAccessorMethod(name, prefix string) bool {
+	return len(name) > len(prefix) && strings.EqualFold(name[:len(prefix)], prefix)
+}
+
+func memberName(m ClassMember) string {
+	switch m := m.(type) {
+	case Variable:
+		return m.Name
+	case Function:
+		return m.Name
+	case Property:
+		return m.Name
+	}
+	return ""
+}
+
+func properties(c Class) []Property {
+	var list []Property
+	for _, s := range c.Sections {
+		for _, m := range s.Members {
+			if p, ok := m.(Property); ok {
+				list = append(list, p)
+			}
+		}
+	}
+	return list
+}
+
+func firstWord(s string) string {
+	if i := strings.IndexByte(s, ' '); i != -1 {
+		return s[:i]
+	}
+	return s
+}
+
+// privateSection returns the index of the private section of c, which is the
+// first one, and true. If c has no private section, it returns the index at
+// which a new one is inserted and false. This is after the unnamed first
+// section, which in forms holds the components.
+func privateSection(c Class) (int, bool) {
+	for i, s := range c.Sections {
+		if s.Visibility == Private {
+			return i, true
+		}
+	}
+	if len(c.Sections) > 0 && c.Sections[0].Visibility == DefaultPublished {
+		return 1, false
+	}
+	return 0, false
+}
+
+// emptyRoutine returns the implementation of the method with the given body,
+// or an empty body if body is "". Directives are not repeated in the
+// implementation.
+func emptyRoutine(className string, f Function, body string) Routine {
+	header := Function{
+		Name:          f.Name,
+		FunctionKind:  f.FunctionKind,
+		IsClassMethod: f.IsClassMethod,
+		Parameters:    f.Parameters,
+		Returns:       f.Returns,
+	}
+	if body == "" {
+		body = "\n"
+	}
+	return Routine{
+		ClassName: className,
+		Header:    header,
+		Body:      "begin\n" + body + "end;",
+	}
+}
+
+// CompleteClasses applies CompleteClasses to the code. The new fields and
+// methods are inserted into the classes and the new routines are appended to
+// the implementation section.
+func (s *Source) CompleteClasses() error {
+	completions := CompleteClasses(s.File)
+	if len(completions) == 0 {
+		return nil
+	}
+	var impl *FileSection
+	for i := range s.File.Sections {
+		if s.File.Sections[i].Kind == ImplementationSection {
+			impl = &s.File.Sections[i]
+		}
+	}
+	if impl == nil {
+		return errors.New("the file has no implementation section")
+	}
+
+	br := s.lineBreak()
+	for _, comp := range completions {
+		if err := s.addPrivateMembers(comp, br); err != nil {
+			return err
+		}
+	}
+	for _, comp := range completions {
+		for _, r := range comp.Routines {
+			code := br + br + strings.Replace(routineCode(r), "\n", br, -1)
+			if err := s.InsertBefore(impl.End, code); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addPrivateMembers inserts the new fields and methods into the private section
+// of the class. Fields are inserted after the existing fields, since fields
+// must come before methods and properties in Delphi.
+func (s *Source) addPrivateMembers(comp ClassCompletion, br string) error {
+	if len(comp.Fields)+len(comp.Methods) == 0 {
+		return nil
+	}
+	c := comp.Class
+	var fields, methods []string
+	for _, f := range comp.Fields {
+		fields = append(fields, f.Name+": "+f.Type+";")
+	}
+	for _, m := range comp.Methods {
+		methods = append(methods, functionCode(m, "")+";")
+	}
+
+	at, ok := privateSection(c)
+	if !ok {
+		keywordIndent := strings.Repeat(" ", c.Pos.Col-1)
+		indent := br + keywordIndent + "  "
+		members := indent + strings.Join(append(fields, methods...), indent)
+		if at == 0 {
+			return s.InsertBefore(c.Sections[0].Pos, "private"+members+br+keywordIndent)
+		}
+		return s.insertAfterLineComments(c.Sections[0].End, br+keywordIndent+"private"+members)
+	}
+
+	private := c.Sections[at]
+	// The fields go after the last field or right after the keyword.
+	fieldsEnd := Position{Offset: private.Pos.Offset + len("private")}
+	col := private.Pos.Col + 2
+	for i, m := range private.Members {
+		pos, end := m.Span()
+		if i == 0 {
+			col = pos.Col
+		}
+		if _, ok := m.(Variable); ok {
+			fieldsEnd = end
+		}
+	}
+	indent := br + strings.Repeat(" ", col-1)
+	if len(fields) > 0 {
+		if err := s.insertAfterLineComments(fieldsEnd, indent+strings.Join(fields, indent)); err != nil {
+			return err
+		}
+	}
+	if len(methods) > 0 {
+		return s.insertAfterLineComments(private.End, indent+strings.Join(methods, indent))
+	}
+	return nil
+}
+
+// insertAfterLineComments inserts the text after the comments that follow pos
+// on the same line, so a "// comment" stays on the line of the code that it
+// belongs to.
+func (s *Source) insertAfterLineComments(pos Position, text string) error {
+	i, err := s.tokenAt(pos)
+	if err != nil {
+		return err
+	}
+	last := -1
+	for ; i < len(s.tokens); i++ {
+		t := s.tokens[i]
+		if t.tokenType == tokenComment {
+			last = i
+			if strings.HasSuffix(t.text, "\n") {
+				break
+			}
+		} else if t.tokenType != tokenWhiteSpace || strings.Contains(t.text, "\n") {
+			break
+		}
+	}
+	if last == -1 {
+		return s.InsertBefore(pos, text)
+	}
+	// A // comment token includes the line break, the text goes in front of it.
+	c := &s.tokens[last]
+	lineEnd := len(strings.TrimRight(c.current, "\r\n"))
+	c.current = c.current[:lineEnd] + text + c.current[lineEnd:]
+	return nil
+}
+
+// routineCode returns the code of the routine, with "\n" line breaks.
+func routineCode(r Routine) string {
+	return functionCode(r.Header, r.ClassName) + ";\n" + r.Body
+}
+
+// functionCode returns the header of the function without the final ';' and
+// without directives, e.g. "function TFoo.Bar(const A, B: Integer): string".
+func functionCode(f Function, className string) string {
+	var b strings.Builder
+	if f.IsClassMethod {
+		b.WriteString("class ")
+	}
+	b.WriteString(functionKeyword(f) + " ")
+	if className != "" {
+		b.WriteString(className + ".")
+	}
+	b.WriteString(escapeIdentifier(f.Name))
+	if len(f.Parameters) > 0 {
+		params := make([]string, len(f.Parameters))
+		for i, p := range f.Parameters {
+			params[i] = parameterCode(p)
+		}
+		b.WriteString("(" + strings.Join(params, "; ") + ")")
+	}
+	if f.Returns != "" {
+		b.WriteString(": " + f.Returns)
+	}
+	return b.String()
+}
+
+func parameterCode(p Parameter) string {
+	var s string
+	for _, a := range p.Attributes {
+		s += "[" + a.Name
+		if len(a.Arguments) > 0 {
+			s += "(" + strings.Join(a.Arguments, ", ") + ")"
+		}
+		s += "] "
+	}
+	if p.Qualifier != NoQualifier {
+		s += p.Qualifier.String() + " "
+	}
+	names := make([]string, len(p.Names))
+	for i, name := range p.Names {
+		names[i] = escapeIdentifier(name)
+	}
+	s += strings.Join(names, ", ")
+	if p.Type != "" {
+		s += ": " + p.Type
+	}
+	return s
+}
+
+// escapeIdentifier puts a '&' in front of reserved words, e.g. "&Type".
+func escapeIdentifier(name string) string {
+	if IsReserved(name) {
+		return "&" + name
+	}
+	return name
+}
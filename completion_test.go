@@ -0,0 +1,216 @@
+package pas_test
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/pas"
+)
+
+func TestCompleteClasses(t *testing.T) {
+	f, err := pas.ParseString(`unit U;
+interface
+type TBase = class
+  FText: string;
+end;
+type TFoo = class(TBase)
+public
+  constructor Create(A: Integer);
+  procedure Done; virtual; abstract;
+  function Get(const Key: string): Integer; overload;
+  function Get(Index: Integer): Integer; overload;
+  property Name: string read FName write SetName;
+  property Text: string read FText write SetText;
+  property Items[I: Integer]: TObject read GetItem;
+end;
+type TDone = class
+  procedure P;
+end;
+implementation
+function TFoo.Get(Index: Integer): Integer;
+begin
+end;
+procedure TDone.P;
+begin
+end;
+end.`)
+	check.Eq(t, err, nil)
+
+	completions := pas.CompleteClasses(f)
+	check.Eq(t, len(completions), 1)
+	c := completions[0]
+	check.Eq(t, c.Class.Name, "TFoo")
+	check.Eq(t, len(c.Fields), 1)
+	check.Eq(t, c.Fields[0], pas.Variable{Name: "FName", Type: "string"})
+	var names []string
+	for _, m := range c.Methods {
+		names = append(names, m.Name)
+	}
+	check.Eq(t, names, []string{"SetName", "SetText", "GetItem"})
+	names = nil
+	for _, r := range c.Routines {
+		names = append(names, r.ClassName+"."+r.Header.Name)
+	}
+	// The new private section goes in front of the public one.
+	check.Eq(t, names, []string{
+		"TFoo.SetName",
+		"TFoo.SetText",
+		"TFoo.GetItem",
+		"TFoo.Create",
+		"TFoo.Get",
+	})
+	check.Eq(t, c.Routines[0].Body, "begin\n  FName := Value;\nend;")
+	check.Eq(t, c.Routines[2].Body, "begin\n\nend;")
+	check.Eq(t, c.Routines[4].Header.Parameters[0].Names, []string{"Key"})
+}
+
+func TestSourceCompleteClasses(t *testing.T) {
+	src, err := pas.ParseLossless(`unit U;
+
+interface
+
+type
+  TFoo = class
+  private
+    FA: Integer;
+    procedure Old;
+  public
+    property A: Integer read FA write SetA;
+    property B: string read FB write FB;
+  end;
+
+implementation
+
+procedure TFoo.Old;
+begin
+end;
+
+end.
+`)
+	check.Eq(t, err, nil)
+	check.Eq(t, src.CompleteClasses(), nil)
+	check.Eq(t, src.String(), `unit U;
+
+interface
+
+type
+  TFoo = class
+  private
+    FA: Integer;
+    FB: string;
+    procedure Old;
+    procedure SetA(const Value: Integer);
+  public
+    property A: Integer read FA write SetA;
+    property B: string read FB write FB;
+  end;
+
+implementation
+
+procedure TFoo.Old;
+begin
+end;
+
+procedure TFoo.SetA(const Value: Integer);
+begin
+  FA := Value;
+end;
+
+end.
+`)
+}
+
+func TestSourceCompleteClassesInsertsAfterLineComments(t *testing.T) {
+	src, err := pas.ParseLossless(`unit U;
+interface
+type
+  TFoo = class
+  private
+    FA: Integer; // The A.
+    procedure Old; { Old. } // Still old.
+  public
+    property A: Integer read FA write SetA;
+    property B: string read FB write FB;
+  end;
+type
+  TBar = class
+    X: Integer; // The X.
+  public
+    property Y: Integer read GetY;
+  end;
+implementation
+procedure TFoo.Old;
+begin
+end;
+end.`)
+	check.Eq(t, err, nil)
+	check.Eq(t, src.CompleteClasses(), nil)
+	check.Eq(t, src.String(), `unit U;
+interface
+type
+  TFoo = class
+  private
+    FA: Integer; // The A.
+    FB: string;
+    procedure Old; { Old. } // Still old.
+    procedure SetA(const Value: Integer);
+  public
+    property A: Integer read FA write SetA;
+    property B: string read FB write FB;
+  end;
+type
+  TBar = class
+    X: Integer; // The X.
+  private
+    function GetY: Integer;
+  public
+    property Y: Integer read GetY;
+  end;
+implementation
+procedure TFoo.Old;
+begin
+end;
+
+procedure TFoo.SetA(const Value: Integer);
+begin
+  FA := Value;
+end;
+
+function TBar.GetY: Integer;
+begin
+
+end;
+end.`)
+}
+
+func TestSourceCompleteClassesAddsPrivateSection(t *testing.T) {
+	src, err := pas.ParseLossless("unit U;\r\n" +
+		"interface\r\n" +
+		"type\r\n" +
+		"  TForm1 = class(TForm)\r\n" +
+		"    Button1: TButton;\r\n" +
+		"  public\r\n" +
+		"    property Count: Integer read GetCount;\r\n" +
+		"  end;\r\n" +
+		"implementation\r\n" +
+		"end.")
+	check.Eq(t, err, nil)
+	check.Eq(t, src.CompleteClasses(), nil)
+	check.Eq(t, src.String(), "unit U;\r\n"+
+		"interface\r\n"+
+		"type\r\n"+
+		"  TForm1 = class(TForm)\r\n"+
+		"    Button1: TButton;\r\n"+
+		"  private\r\n"+
+		"    function GetCount: Integer;\r\n"+
+		"  public\r\n"+
+		"    property Count: Integer read GetCount;\r\n"+
+		"  end;\r\n"+
+		"implementation\r\n"+
+		"\r\n"+
+		"function TForm1.GetCount: Integer;\r\n"+
+		"begin\r\n"+
+		"\r\n"+
+		"end;\r\n"+
+		"end.")
+}
@@ -0,0 +1,336 @@
+package pas
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DependencyGraph is the graph of uses clauses between units.
+type DependencyGraph struct {
+	// Units are the names of all units, the parsed units first, in the order
+	// in which they were given, followed by the units that are used but were
+	// not parsed, e.g. RTL units.
+	Units []string
+	// Edges are all uses of all units, in the order of the units and their
+	// uses clauses.
+	Edges []Dependency
+}
+
+// Dependency is a unit in a uses clause of another unit.
+type Dependency struct {
+	From, To string
+	// Section is InterfaceSection or ImplementationSection.
+	Section FileSectionKind
+}
+
+// NewDependencyGraph creates the graph of the units' uses clauses. Unit names
+// are compared ignoring case. The names in uses clauses are used as they are,
+// use Project.DependencyGraph to resolve unit scope names like "Forms" to
+// "Vcl.Forms".
+func NewDependencyGraph(files []*File) *DependencyGraph {
+	return newDependencyGraph(files, func(name string) string { return name })
+}
+
+// DependencyGraph creates the graph of the project's units. Used units are
+// resolved, so "Forms" and "Vcl.Forms" are the same unit if "Vcl" is one of
+// the UnitScopeNames.
+func (p *Project) DependencyGraph() *DependencyGraph {
+	var files []*File
+	for _, u := range p.Units {
+		files = append(files, u.File)
+	}
+	return newDependencyGraph(files, func(name string) string {
+		if u := p.Unit(name); u != nil {
+			return u.Name
+		}
+		return name
+	})
+}
+
+func newDependencyGraph(files []*File, resolve func(string) string) *DependencyGraph {
+	g := &DependencyGraph{}
+	names := make(map[string]string) // Lower case to the first spelling.
+	unit := func(name string) string {
+		key := FoldIdentifier(name)
+		if first, ok := names[key]; ok {
+			return first
+		}
+		names[key] = name
+		g.Units = append(g.Units, name)
+		return name
+	}
+	for _, f := range files {
+		unit(f.Name)
+	}
+	for _, f := range files {
+		from := unit(f.Name)
+		for _, s := range f.Sections {
+			for _, u := range s.Uses {
+				g.Edges = append(g.Edges, Dependency{
+					From:    from,
+					To:      unit(resolve(u)),
+					Section: s.Kind,
+				})
+			}
+		}
+	}
+	return g
+}
+
+// Uses returns the units that the unit uses directly, in the given section.
+func (g *DependencyGraph) Uses(unit string, section FileSectionKind) []string {
+	var uses []string
+	for _, e := range g.Edges {
+		if SameIdentifier(e.From, unit) && e.Section == section {
+			uses = append(uses, e.To)
+		}
+	}
+	return uses
+}
+
+// InterfaceCycles returns the cycles of units that use each other in their
+// interface sections. These are compile errors in Delphi.
+//
+// For every group of units that use each other, one of the shortest cycles is
+// returned, e.g. [A B C A] for A using B, B using C and C using A. Units that
+// take part in several cycles are only reported once, in one of them.
+func (g *DependencyGraph) InterfaceCycles() [][]string {
+	return g.cycles(func(e Dependency) bool {
+		return e.Section == InterfaceSection
+	}, func(e Dependency) bool {
+		return true
+	})
+}
+
+// ImplementationCycles returns the cycles of units that use each other where at
+// least one of the uses is in an implementation section. These are legal in
+// Delphi. For every group of units that use each other, one of the shortest
+// cycles that goes through an implementation uses clause is returned, see
+// InterfaceCycles for the format.
+func (g *DependencyGraph) ImplementationCycles() [][]string {
+	return g.cycles(func(e Dependency) bool {
+		return true
+	}, func(e Dependency) bool {
+		return e.Section != InterfaceSection
+	})
+}
+
+// cycles finds the strongly connected components of the graph that only has the
+// edges for which follow is true. For each component that contains edges for
+// which report is true, a shortest cycle through one of these edges is
+// returned.
+func (g *DependencyGraph) cycles(follow, report func(Dependency) bool) [][]string {
+	index := make(map[string]int)
+	for i, u := range g.Units {
+		index[u] = i
+	}
+	next := make([][]int, len(g.Units))
+	for _, e := range g.Edges {
+		if follow(e) {
+			next[index[e.From]] = append(next[index[e.From]], index[e.To])
+		}
+	}
+	component := stronglyConnectedComponents(next)
+
+	// The edges to report are grouped by their component, in the order of the
+	// Edges. Edges between components are in no cycle.
+	reported := make(map[int][][2]int)
+	for _, e := range g.Edges {
+		from, to := index[e.From], index[e.To]
+		if follow(e) && report(e) && component[from] == component[to] {
+			c := component[from]
+			reported[c] = append(reported[c], [2]int{from, to})
+		}
+	}
+
+	// Components are numbered in the order in which Tarjan's algorithm
+	// finishes them, report them in the order of their first unit instead.
+	var cycles [][]string
+	for _, u := range g.Units {
+		c := component[index[u]]
+		edges := reported[c]
+		if len(edges) == 0 {
+			continue
+		}
+		delete(reported, c)
+		inComponent := func(i int) bool { return component[i] == c }
+		// Many edges can end in the same unit, the paths back from it are only
+		// searched once.
+		prevs := make(map[int][]int)
+		var shortest []int
+		for _, e := range edges {
+			from, to := e[0], e[1]
+			// The cycle is the edge followed by the path back to its start.
+			path := []int{from, to}
+			if from != to {
+				prev, ok := prevs[to]
+				if !ok {
+					prev = shortestPaths(next, to, inComponent)
+					prevs[to] = prev
+				}
+				path = append([]int{from}, pathTo(prev, to, from)...)
+			}
+			if shortest == nil || len(path) < len(shortest) {
+				shortest = path
+			}
+		}
+		var cycle []string
+		for _, i := range shortest {
+			cycle = append(cycle, g.Units[i])
+		}
+		cycles = append(cycles, cycle)
+	}
+	return cycles
+}
+
+// ShortestPath returns a shortest chain of uses from one unit to another, e.g.
+// [A B C] if A uses B and B uses C. Uses in both sections are followed. It
+// returns nil if there is no such chain.
+func (g *DependencyGraph) ShortestPath(from, to string) []string {
+	index := make(map[string]int)
+	for i, u := range g.Units {
+		index[FoldIdentifier(u)] = i
+	}
+	start, ok1 := index[FoldIdentifier(from)]
+	end, ok2 := index[FoldIdentifier(to)]
+	if !ok1 || !ok2 {
+		return nil
+	}
+	next := make([][]int, len(g.Units))
+	for _, e := range g.Edges {
+		f, t := index[FoldIdentifier(e.From)], index[FoldIdentifier(e.To)]
+		next[f] = append(next[f], t)
+	}
+	prev := shortestPaths(next, start, func(int) bool { return true })
+	var path []string
+	for _, i := range pathTo(prev, start, end) {
+		path = append(path, g.Units[i])
+	}
+	return path
+}
+
+// shortestPaths does a breadth-first search from start, only visiting nodes for
+// which allowed is true. It returns the previous node on a shortest path from
+// start for every node, -1 for the nodes that cannot be reached. The previous
+// node of start itself is the end of a shortest cycle, if there is one.
+func shortestPaths(next [][]int, start int, allowed func(int) bool) []int {
+	prev := make([]int, len(next))
+	for i := range prev {
+		prev[i] = -1
+	}
+	queue := []int{start}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, m := range next[n] {
+			if !allowed(m) || prev[m] != -1 {
+				continue
+			}
+			prev[m] = n
+			if m != start {
+				queue = append(queue, m)
+			}
+		}
+	}
+	return prev
+}
+
+// pathTo returns the path from start to end, given the result of shortestPaths
+// for start. If start and end are the same, it is a cycle. It returns nil if
+// there is no path.
+func pathTo(prev []int, start, end int) []int {
+	if prev[end] == -1 {
+		return nil
+	}
+	path := []int{end}
+	for n := prev[end]; n != start; n = prev[n] {
+		path = append(path, n)
+	}
+	path = append(path, start)
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// stronglyConnectedComponents uses Tarjan's algorithm to assign each node a
+// component number. A node that is not part of any cycle is in a component of
+// its own, which has no edges inside of it unless the node uses itself.
+func stronglyConnectedComponents(next [][]int) []int {
+	n := len(next)
+	index := make([]int, n)
+	low := make([]int, n)
+	onStack := make([]bool, n)
+	component := make([]int, n)
+	for i := range index {
+		index[i] = -1
+	}
+	var stack []int
+	count, components := 0, 0
+
+	var visit func(v int)
+	visit = func(v int) {
+		index[v] = count
+		low[v] = count
+		count++
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range next[v] {
+			if index[w] == -1 {
+				visit(w)
+				if low[w] < low[v] {
+					low[v] = low[w]
+				}
+			} else if onStack[w] && index[w] < low[v] {
+				low[v] = index[w]
+			}
+		}
+		if low[v] == index[v] {
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				component[w] = components
+				if w == v {
+					break
+				}
+			}
+			components++
+		}
+	}
+	for v := range next {
+		if index[v] == -1 {
+			visit(v)
+		}
+	}
+	return component
+}
+
+// WriteDOT writes the graph in the Graphviz DOT format. Implementation uses are
+// drawn as dashed lines.
+func (g *DependencyGraph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph units {\n")
+	for _, u := range g.Units {
+		fmt.Fprintf(&b, "\t%q;\n", u)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "\t%q -> %q", e.From, e.To)
+		if e.Section != InterfaceSection {
+			b.WriteString(" [style=dashed]")
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes the graph as JSON, e.g.
+//
+//     {"Units":["A","B"],"Edges":[{"From":"A","To":"B","Section":"interface"}]}
+func (g *DependencyGraph) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(g)
+}
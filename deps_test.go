@@ -0,0 +1,136 @@
+package pas_test
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/pas"
+)
+
+func parseUnits(t *testing.T, units ...string) []*pas.File {
+	var files []*pas.File
+	for _, code := range units {
+		f, err := pas.ParseString(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+func TestDependencyGraphSeparatesInterfaceAndImplementation(t *testing.T) {
+	g := pas.NewDependencyGraph(parseUnits(t,
+		"unit A; interface uses B, SysUtils; implementation uses c; end.",
+		"unit B; interface implementation end.",
+		"unit C; interface implementation end.",
+	))
+	check.Eq(t, g.Units, []string{"A", "B", "C", "SysUtils"})
+	check.Eq(t, g.Edges, []pas.Dependency{
+		{From: "A", To: "B", Section: pas.InterfaceSection},
+		{From: "A", To: "SysUtils", Section: pas.InterfaceSection},
+		{From: "A", To: "C", Section: pas.ImplementationSection},
+	})
+	check.Eq(t, g.Uses("a", pas.InterfaceSection), []string{"B", "SysUtils"})
+	check.Eq(t, g.Uses("A", pas.ImplementationSection), []string{"C"})
+	check.Eq(t, len(g.InterfaceCycles()), 0)
+	check.Eq(t, len(g.ImplementationCycles()), 0)
+}
+
+func TestDependencyGraphCycles(t *testing.T) {
+	g := pas.NewDependencyGraph(parseUnits(t,
+		"unit A; interface uses B; implementation uses D; end.",
+		"unit B; interface uses C; implementation end.",
+		"unit C; interface uses A; implementation end.",
+		"unit D; interface implementation uses A; end.",
+		"unit E; interface uses E; implementation end.",
+	))
+	check.Eq(t, g.InterfaceCycles(), [][]string{
+		{"A", "B", "C", "A"},
+		{"E", "E"},
+	})
+	check.Eq(t, g.ImplementationCycles(), [][]string{
+		{"A", "D", "A"},
+	})
+}
+
+func TestDependencyGraphCyclesThroughSharedUnits(t *testing.T) {
+	g := pas.NewDependencyGraph(parseUnits(t,
+		"unit A; interface uses B, C; implementation end.",
+		"unit B; interface uses D; implementation end.",
+		"unit C; interface uses D; implementation end.",
+		"unit D; interface uses A; implementation uses C; end.",
+	))
+	check.Eq(t, g.InterfaceCycles(), [][]string{{"A", "B", "D", "A"}})
+	check.Eq(t, g.ImplementationCycles(), [][]string{{"D", "C", "D"}})
+	check.Eq(t, g.ShortestPath("D", "D"), []string{"D", "C", "D"})
+}
+
+func TestDependencyGraphCyclesOfLargeGraphs(t *testing.T) {
+	// The cycles are found in time linear in the number of edges, so this
+	// graph with 80000 edges but only one cycle takes milliseconds, not
+	// seconds.
+	g := &pas.DependencyGraph{}
+	for i := 0; i < 8000; i++ {
+		g.Units = append(g.Units, fmt.Sprint("U", i))
+	}
+	for i := range g.Units {
+		for j := i + 1; j <= i+10 && j < len(g.Units); j++ {
+			g.Edges = append(g.Edges, pas.Dependency{
+				From: g.Units[i], To: g.Units[j], Section: pas.InterfaceSection,
+			})
+		}
+	}
+	g.Edges = append(g.Edges, pas.Dependency{
+		From: "U7999", To: "U7990", Section: pas.ImplementationSection,
+	})
+	check.Eq(t, len(g.InterfaceCycles()), 0)
+	check.Eq(t, g.ImplementationCycles(), [][]string{{"U7999", "U7990", "U7999"}})
+}
+
+func TestDependencyGraphShortestPath(t *testing.T) {
+	g := pas.NewDependencyGraph(parseUnits(t,
+		"unit A; interface uses B; implementation uses C; end.",
+		"unit B; interface uses C; implementation end.",
+		"unit C; interface implementation uses D; end.",
+	))
+	check.Eq(t, g.ShortestPath("A", "d"), []string{"A", "C", "D"})
+	check.Eq(t, g.ShortestPath("A", "B"), []string{"A", "B"})
+	check.Eq(t, g.ShortestPath("D", "A"), []string(nil))
+	check.Eq(t, g.ShortestPath("A", "X"), []string(nil))
+}
+
+func TestDependencyGraphOutput(t *testing.T) {
+	g := pas.NewDependencyGraph(parseUnits(t,
+		"unit A; interface uses B; implementation uses C; end.",
+	))
+
+	var dot bytes.Buffer
+	check.Eq(t, g.WriteDOT(&dot), nil)
+	check.Eq(t, dot.String(), `digraph units {
+	"A";
+	"B";
+	"C";
+	"A" -> "B";
+	"A" -> "C" [style=dashed];
+}
+`)
+
+	var json bytes.Buffer
+	check.Eq(t, g.WriteJSON(&json), nil)
+	check.Eq(t, json.String(), `{"Units":["A","B","C"],"Edges":[`+
+		`{"From":"A","To":"B","Section":"interface"},`+
+		`{"From":"A","To":"C","Section":"implementation"}]}`+"\n")
+}
+
+func TestProjectDependencyGraphResolvesUnitNames(t *testing.T) {
+	dir := writeTestProject(t)
+	p, _ := pas.LoadProject(filepath.Join(dir, "P.dproj"))
+	g := p.DependencyGraph()
+	check.Eq(t, g.Units, []string{"Main", "Helper", "Vcl.Tools", "Vcl.Dialogs"})
+	check.Eq(t, g.Uses("Main", pas.ImplementationSection),
+		[]string{"Vcl.Dialogs", "Vcl.Tools"})
+}
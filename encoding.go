@@ -0,0 +1,192 @@
+package pas
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// CodePage maps the bytes 0x80 to 0xFF of a single-byte code page to Unicode.
+// The bytes below 0x80 are ASCII in all Windows code pages. Delphi writes units
+// in the system's ANSI code page if they contain no characters outside of it.
+type CodePage [128]rune
+
+// Windows1252 is the Western European ANSI code page. Bytes that are undefined
+// in it are mapped to the control characters with the same value, like Windows
+// does.
+var Windows1252 = &CodePage{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+	0x00A0, 0x00A1, 0x00A2, 0x00A3, 0x00A4, 0x00A5, 0x00A6, 0x00A7,
+	0x00A8, 0x00A9, 0x00AA, 0x00AB, 0x00AC, 0x00AD, 0x00AE, 0x00AF,
+	0x00B0, 0x00B1, 0x00B2, 0x00B3, 0x00B4, 0x00B5, 0x00B6, 0x00B7,
+	0x00B8, 0x00B9, 0x00BA, 0x00BB, 0x00BC, 0x00BD, 0x00BE, 0x00BF,
+	0x00C0, 0x00C1, 0x00C2, 0x00C3, 0x00C4, 0x00C5, 0x00C6, 0x00C7,
+	0x00C8, 0x00C9, 0x00CA, 0x00CB, 0x00CC, 0x00CD, 0x00CE, 0x00CF,
+	0x00D0, 0x00D1, 0x00D2, 0x00D3, 0x00D4, 0x00D5, 0x00D6, 0x00D7,
+	0x00D8, 0x00D9, 0x00DA, 0x00DB, 0x00DC, 0x00DD, 0x00DE, 0x00DF,
+	0x00E0, 0x00E1, 0x00E2, 0x00E3, 0x00E4, 0x00E5, 0x00E6, 0x00E7,
+	0x00E8, 0x00E9, 0x00EA, 0x00EB, 0x00EC, 0x00ED, 0x00EE, 0x00EF,
+	0x00F0, 0x00F1, 0x00F2, 0x00F3, 0x00F4, 0x00F5, 0x00F6, 0x00F7,
+	0x00F8, 0x00F9, 0x00FA, 0x00FB, 0x00FC, 0x00FD, 0x00FE, 0x00FF,
+}
+
+// Decode converts the code page encoded bytes to a UTF-8 string.
+func (c *CodePage) Decode(b []byte) string {
+	var s strings.Builder
+	s.Grow(len(b))
+	for _, b := range b {
+		if b < 0x80 {
+			s.WriteByte(b)
+		} else {
+			s.WriteRune(c[b-0x80])
+		}
+	}
+	return s.String()
+}
+
+// Encode converts the UTF-8 string to the code page. It returns an error if
+// the string contains characters that are not in the code page.
+func (c *CodePage) Encode(s string) ([]byte, error) {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r < 0x80 {
+			b = append(b, byte(r))
+			continue
+		}
+		i := 0
+		for i < len(c) && c[i] != r {
+			i++
+		}
+		if i == len(c) {
+			return nil, fmt.Errorf("character %q is not in the code page", r)
+		}
+		b = append(b, byte(0x80+i))
+	}
+	return b, nil
+}
+
+// Encoding is the way a file's code is stored, see Options.Decode.
+type Encoding int
+
+const (
+	// UTF8 is UTF-8 without byte order mark.
+	UTF8 Encoding = 0
+	// UTF8BOM is UTF-8 with a byte order mark, which is what Delphi writes for
+	// units with characters that are not in the ANSI code page.
+	UTF8BOM Encoding = 1
+	// UTF16LE and UTF16BE are UTF-16 with a byte order mark. Without it,
+	// UTF-16 is not detected.
+	UTF16LE Encoding = 2
+	UTF16BE Encoding = 3
+	// ANSI is a single-byte code page without byte order mark, see
+	// Options.CodePage.
+	ANSI Encoding = 4
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case UTF8:
+		return "UTF-8"
+	case UTF8BOM:
+		return "UTF-8 with BOM"
+	case UTF16LE:
+		return "UTF-16 LE"
+	case UTF16BE:
+		return "UTF-16 BE"
+	case ANSI:
+		return "ANSI"
+	}
+	return "unknown Encoding"
+}
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// Decode converts the code to UTF-8 and returns the Encoding it was in. Code
+// with a byte order mark is decoded as UTF-8, UTF-16 LE or UTF-16 BE. Code
+// without byte order mark is decoded as UTF-8 if it is valid UTF-8 and with
+// o.CodePage otherwise. The byte order mark is not part of the returned code.
+//
+// Use Options.Encode with the returned Encoding to write the code back the way
+// it was.
+func (o Options) Decode(code []byte) (string, Encoding, error) {
+	switch {
+	case bytes.HasPrefix(code, bomUTF8):
+		return string(code[len(bomUTF8):]), UTF8BOM, nil
+	case bytes.HasPrefix(code, bomUTF16LE):
+		s, err := o.decodeUTF16(code[len(bomUTF16LE):], false)
+		return s, UTF16LE, err
+	case bytes.HasPrefix(code, bomUTF16BE):
+		s, err := o.decodeUTF16(code[len(bomUTF16BE):], true)
+		return s, UTF16BE, err
+	}
+	if !o.ForceCodePage && utf8.Valid(code) {
+		return string(code), UTF8, nil
+	}
+	return o.codePage().Decode(code), ANSI, nil
+}
+
+// Encode converts the UTF-8 code to the encoding, including its byte order
+// mark. ANSI code is encoded with o.CodePage. It is an error if the code
+// contains characters that are not in the code page.
+func (o Options) Encode(code string, e Encoding) ([]byte, error) {
+	switch e {
+	case UTF8:
+		return []byte(code), nil
+	case UTF8BOM:
+		return append(append([]byte{}, bomUTF8...), code...), nil
+	case UTF16LE, UTF16BE:
+		words := utf16.Encode([]rune("\uFEFF" + code))
+		b := make([]byte, 0, 2*len(words))
+		for _, w := range words {
+			if e == UTF16LE {
+				b = append(b, byte(w), byte(w>>8))
+			} else {
+				b = append(b, byte(w>>8), byte(w))
+			}
+		}
+		return b, nil
+	case ANSI:
+		b, err := o.codePage().Encode(code)
+		if err != nil && o.Filename != "" {
+			err = errors.New(o.Filename + ": " + err.Error())
+		}
+		return b, err
+	}
+	return nil, errors.New("unknown Encoding")
+}
+
+func (o Options) codePage() *CodePage {
+	if o.CodePage == nil {
+		return Windows1252
+	}
+	return o.CodePage
+}
+
+func (o Options) decodeUTF16(code []byte, bigEndian bool) (string, error) {
+	if len(code)%2 != 0 {
+		msg := "UTF-16 code has an odd number of bytes"
+		if o.Filename != "" {
+			msg = o.Filename + ": " + msg
+		}
+		return "", errors.New(msg)
+	}
+	words := make([]uint16, len(code)/2)
+	for i := range words {
+		lo, hi := code[2*i], code[2*i+1]
+		if bigEndian {
+			lo, hi = hi, lo
+		}
+		words[i] = uint16(hi)<<8 | uint16(lo)
+	}
+	return string(utf16.Decode(words)), nil
+}
@@ -0,0 +1,132 @@
+package pas_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/pas"
+)
+
+func TestParseBytesDetectsEncoding(t *testing.T) {
+	// The unit name is "Ä", encoded differently in each case.
+	tests := []struct {
+		name string
+		code []byte
+	}{
+		{"UTF-8", []byte("unit \xC3\x84;interface implementation end.")},
+		{"UTF-8 BOM", []byte("\xEF\xBB\xBFunit \xC3\x84;interface implementation end.")},
+		{"UTF-16 LE", utf16LE("\uFEFFunit Ä;interface implementation end.")},
+		{"UTF-16 BE", utf16BE("\uFEFFunit Ä;interface implementation end.")},
+		{"Windows-1252", []byte("unit \xC4;interface implementation end.")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := pas.ParseBytes(test.code)
+			check.Eq(t, err, nil)
+			check.Eq(t, f.Name, "Ä")
+		})
+	}
+}
+
+func TestDecodeAndEncodeRoundTrip(t *testing.T) {
+	tests := []struct {
+		code     []byte
+		encoding pas.Encoding
+	}{
+		{[]byte("x \xC3\x84"), pas.UTF8},
+		{[]byte("\xEF\xBB\xBFx \xC3\x84"), pas.UTF8BOM},
+		{utf16LE("\uFEFFx Ä"), pas.UTF16LE},
+		{utf16BE("\uFEFFx Ä"), pas.UTF16BE},
+		{[]byte("x \xC4 \x80"), pas.ANSI},
+	}
+	for _, test := range tests {
+		t.Run(test.encoding.String(), func(t *testing.T) {
+			code, encoding, err := pas.Options{}.Decode(test.code)
+			check.Eq(t, err, nil)
+			check.Eq(t, encoding, test.encoding)
+			check.Eq(t, strings.HasPrefix(code, "x Ä"), true)
+			back, err := pas.Options{}.Encode(code, encoding)
+			check.Eq(t, err, nil)
+			check.Eq(t, back, test.code)
+		})
+	}
+}
+
+func TestEncodingCharactersOutsideTheCodePageIsAnError(t *testing.T) {
+	_, err := pas.Options{Filename: "U.pas"}.Encode("Ä Ж", pas.ANSI)
+	check.Eq(t, err.Error(), `U.pas: character 'Ж' is not in the code page`)
+}
+
+func TestWindows1252MapsSpecialCharacters(t *testing.T) {
+	check.Eq(t, pas.Windows1252.Decode([]byte("a\x80\x9F\xA0\xFF")), "a€Ÿ ÿ")
+}
+
+func TestCodePageCanBeForcedForBOMlessCode(t *testing.T) {
+	// "\xC3\x84" is valid UTF-8 for "Ä" but in Windows-1252 it is "Ã„".
+	code := []byte("unit U;interface implementation{\xC3\x84}end.")
+	f, err := pas.Options{ForceCodePage: true}.ParseBytes(code)
+	check.Eq(t, err, nil)
+	check.Eq(t, f.Comments[0].List[0].Text, "{Ã„}")
+
+	var cyrillic pas.CodePage
+	for i := range cyrillic {
+		cyrillic[i] = '?'
+	}
+	cyrillic[0xC0-0x80] = 'А'
+	f, err = pas.Options{CodePage: &cyrillic}.ParseBytes(
+		[]byte("unit \xC0;interface implementation end."),
+	)
+	check.Eq(t, err, nil)
+	check.Eq(t, f.Name, "А")
+
+	// A byte order mark always wins over the code page.
+	f, err = pas.Options{ForceCodePage: true}.ParseBytes(
+		[]byte("\xEF\xBB\xBFunit \xC3\x84;interface implementation end."),
+	)
+	check.Eq(t, err, nil)
+	check.Eq(t, f.Name, "Ä")
+}
+
+func TestUTF16WithOddNumberOfBytesIsAnError(t *testing.T) {
+	_, err := pas.Options{Filename: "U.pas"}.ParseBytes([]byte{0xFF, 0xFE, 'u'})
+	check.Eq(t, err.Error(), "U.pas: UTF-16 code has an odd number of bytes")
+}
+
+func TestParseFileKeepsFileNameInPositions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "U.pas")
+	err := os.WriteFile(path, []byte("unit U;\r\ninterface\r\nend."), 0666)
+	check.Eq(t, err, nil)
+
+	f, err := pas.ParseFile(path)
+	check.Eq(t, err.Error(),
+		`keyword "implementation" expected but was word "end" at `+path+":3:1")
+	check.Eq(t, f.Pos.Filename, path)
+	check.Eq(t, f.Sections[0].Pos.String(), path+":2:1")
+}
+
+func TestParseReader(t *testing.T) {
+	f, err := pas.ParseReader(strings.NewReader("unit U;interface implementation end."))
+	check.Eq(t, err, nil)
+	check.Eq(t, f.Name, "U")
+	check.Eq(t, f.Pos.Filename, "")
+}
+
+func utf16LE(s string) []byte {
+	var b []byte
+	for _, w := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(w), byte(w>>8))
+	}
+	return b
+}
+
+func utf16BE(s string) []byte {
+	var b []byte
+	for _, w := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(w>>8), byte(w))
+	}
+	return b
+}
@@ -0,0 +1,46 @@
+package pas
+
+import "fmt"
+
+// ParseError is a syntax error. The parser expected one thing in the code but
+// found another.
+type ParseError struct {
+	Pos Position
+	// Expected describes what the parser was looking for, e.g. `token ";"` or
+	// `keyword "end"`.
+	Expected string
+	// Found describes the token that was in the code instead, e.g.
+	// `word "implementation"` or `end of file`.
+	Found string
+}
+
+func (e *ParseError) Error() string {
+	return e.Expected + " expected but was " + e.Found + " at " + e.Pos.String()
+}
+
+// ErrorList is the error returned by the Parse functions. It contains all
+// syntax errors in the order in which they occur in the code. The parser
+// recovers from an error at the next ';' or keyword so a single mistake does
+// not hide all the errors after it.
+type ErrorList []*ParseError
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	case 2:
+		return l[0].Error() + " (and 1 more error)"
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Err returns nil if the list is empty and the list itself otherwise. Use it
+// to avoid returning a non-nil error interface holding an empty list.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
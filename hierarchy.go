@@ -0,0 +1,268 @@
+package pas
+
+import "strings"
+
+// Hierarchy is the index of the classes and interfaces of a set of units and of
+// how they inherit from each other.
+type Hierarchy struct {
+	// Types are all classes and interfaces, in the order of Info.Scopes.
+	Types []*HierarchyType
+}
+
+// HierarchyType is a class or interface in a Hierarchy.
+type HierarchyType struct {
+	Symbol *Symbol
+	// ParentName is the parent class of a class or the parent interface of an
+	// interface as written in the code. It is empty if there is none, which
+	// means TObject for classes and IInterface for interfaces.
+	ParentName string
+	// Parent is the type that ParentName refers to, or nil if it is not among
+	// the parsed units.
+	Parent *HierarchyType
+	// InterfaceNames are the interfaces that a class implements, as written.
+	InterfaceNames []string
+	// Interfaces are the types that InterfaceNames refer to, leaving out the
+	// ones that are not among the parsed units.
+	Interfaces []*HierarchyType
+	// Children are the types whose Parent this is.
+	Children []*HierarchyType
+	// Implementers are the classes that implement this interface directly.
+	Implementers []*HierarchyType
+
+	// parentKnown is true if there is no ParentName or if it refers to a parsed
+	// or predeclared type, i.e. all ancestors are known.
+	parentKnown bool
+}
+
+// Name returns the name of the class or interface.
+func (t *HierarchyType) Name() string {
+	return t.Symbol.Name
+}
+
+// Class returns the declaration of the class or interface.
+func (t *HierarchyType) Class() Class {
+	return t.Symbol.Decl.(Class)
+}
+
+// predeclaredInterfaces are the predeclared types that are interfaces.
+var predeclaredInterfaces = map[string]bool{
+	"iinterface": true,
+	"iunknown":   true,
+}
+
+// NewHierarchy builds the class hierarchy of the resolved units. The super
+// classes of a class are split into its parent class and the interfaces that
+// it implements by resolving their names. If a name cannot be resolved, the
+// first one is taken to be the parent class.
+func NewHierarchy(info *Info) *Hierarchy {
+	h := &Hierarchy{}
+	types := make(map[*Symbol]*HierarchyType)
+	var scopes []*Scope
+	for _, s := range info.Scopes {
+		for _, sym := range s.Symbols {
+			if sym.Kind == ClassSymbol || sym.Kind == InterfaceSymbol {
+				t := &HierarchyType{Symbol: sym}
+				types[sym] = t
+				h.Types = append(h.Types, t)
+				scopes = append(scopes, s)
+			}
+		}
+	}
+	for i, t := range h.Types {
+		t.parentKnown = true
+		for j, super := range t.Class().SuperClasses {
+			sym := scopes[i].Lookup(super)
+			isInterface := sym != nil && (sym.Kind == InterfaceSymbol ||
+				sym.Kind == TypeSymbol && predeclaredInterfaces[FoldIdentifier(sym.Name)])
+			if j > 0 || t.Symbol.Kind == ClassSymbol && isInterface {
+				t.InterfaceNames = append(t.InterfaceNames, super)
+				if s := types[sym]; s != nil {
+					t.Interfaces = append(t.Interfaces, s)
+					s.Implementers = append(s.Implementers, t)
+				}
+				continue
+			}
+			t.ParentName = super
+			t.Parent = types[sym]
+			t.parentKnown = sym != nil
+			if t.Parent != nil {
+				t.Parent.Children = append(t.Parent.Children, t)
+			}
+		}
+	}
+	return h
+}
+
+// Find returns the class or interface of the given name, which can be
+// qualified with its unit name, or nil if there is none. Case is ignored.
+func (h *Hierarchy) Find(name string) *HierarchyType {
+	for _, t := range h.Types {
+		if SameIdentifier(t.Name(), name) ||
+			SameIdentifier(t.Symbol.File.Name+"."+t.Name(), name) {
+			return t
+		}
+	}
+	return nil
+}
+
+// Ancestors returns the parent, the parent's parent and so on, as far as they
+// are known.
+func (t *HierarchyType) Ancestors() []*HierarchyType {
+	var ancestors []*HierarchyType
+	seen := map[*HierarchyType]bool{t: true}
+	for p := t.Parent; p != nil && !seen[p]; p = p.Parent {
+		seen[p] = true
+		ancestors = append(ancestors, p)
+	}
+	return ancestors
+}
+
+// Descendants returns the children, their children and so on, depth first.
+func (t *HierarchyType) Descendants() []*HierarchyType {
+	var descendants []*HierarchyType
+	seen := map[*HierarchyType]bool{t: true}
+	var add func(*HierarchyType)
+	add = func(t *HierarchyType) {
+		for _, c := range t.Children {
+			if !seen[c] {
+				seen[c] = true
+				descendants = append(descendants, c)
+				add(c)
+			}
+		}
+	}
+	add(t)
+	return descendants
+}
+
+// allAncestorsKnown reports whether the chain of parents ends at a type
+// without a parent or with a predeclared parent.
+func (t *HierarchyType) allAncestorsKnown() bool {
+	last := t
+	if a := t.Ancestors(); len(a) > 0 {
+		last = a[len(a)-1]
+	}
+	return last.parentKnown && last.Parent == nil
+}
+
+// tobjectVirtuals are the virtual methods of TObject, which every class can
+// override.
+var tobjectVirtuals = map[string]bool{
+	"afterconstruction": true,
+	"beforedestruction": true,
+	"defaulthandler":    true,
+	"destroy":           true,
+	"dispatch":          true,
+	"equals":            true,
+	"freeinstance":      true,
+	"gethashcode":       true,
+	"newinstance":       true,
+	"safecallexception": true,
+	"tostring":          true,
+}
+
+// Check reports problems in the class hierarchy:
+//
+//     - classes that do not have a method for every method of the interfaces
+//       that they implement
+//     - abstract methods that no descendant overrides
+//     - override methods whose ancestors have no virtual method of that name
+//
+// Ancestors that are not among the parsed units are unknown, so classes that
+// have such ancestors are only checked as far as possible. Method resolution
+// clauses are not parsed yet, so methods are only matched by name.
+func (h *Hierarchy) Check() []*TypeError {
+	var errs []*TypeError
+	report := func(n Node, msg string) {
+		pos, _ := n.Span()
+		errs = append(errs, &TypeError{Pos: pos, Msg: msg})
+	}
+	for _, t := range h.Types {
+		if t.Symbol.Kind == ClassSymbol && t.allAncestorsKnown() {
+			for _, intf := range t.Interfaces {
+				for _, i := range append([]*HierarchyType{intf}, intf.Ancestors()...) {
+					for _, m := range methods(i.Class()) {
+						if findMethod(t, m.Name, nil) == nil {
+							report(t.Class(), `class "`+t.Name()+`" does not implement "`+
+								i.Name()+"."+m.Name+`"`)
+						}
+					}
+				}
+			}
+		}
+		for _, m := range methods(t.Class()) {
+			if hasDirective(m, "abstract") {
+				overridden := false
+				for _, d := range t.Descendants() {
+					if findMethod(d, m.Name, func(f Function) bool {
+						return hasDirective(f, "override")
+					}) != nil {
+						overridden = true
+						break
+					}
+				}
+				if !overridden {
+					report(m, `abstract method "`+t.Name()+"."+m.Name+`" is never overridden`)
+				}
+			}
+			if hasDirective(m, "override") && !t.overridesVirtual(m.Name) {
+				report(m, `method "`+t.Name()+"."+m.Name+`" overrides no virtual method`)
+			}
+		}
+	}
+	return errs
+}
+
+// overridesVirtual reports whether one of the ancestors has a virtual method of
+// the given name. If not all ancestors are known, it is assumed that one of the
+// unknown ones has it.
+func (t *HierarchyType) overridesVirtual(name string) bool {
+	if t.Parent != nil && findMethod(t.Parent, name, isVirtual) != nil {
+		return true
+	}
+	return !t.allAncestorsKnown() || tobjectVirtuals[FoldIdentifier(name)]
+}
+
+func isVirtual(f Function) bool {
+	return hasDirective(f, "virtual") || hasDirective(f, "dynamic") ||
+		hasDirective(f, "abstract") || hasDirective(f, "override")
+}
+
+// findMethod finds a method of the given name in t or its ancestors for which
+// match returns true. A nil match matches all methods.
+func findMethod(t *HierarchyType, name string, match func(Function) bool) *Function {
+	for _, c := range append([]*HierarchyType{t}, t.Ancestors()...) {
+		for _, m := range methods(c.Class()) {
+			if SameIdentifier(m.Name, name) && (match == nil || match(m)) {
+				return &m
+			}
+		}
+	}
+	return nil
+}
+
+func methods(c Class) []Function {
+	var list []Function
+	for _, s := range c.Sections {
+		for _, m := range s.Members {
+			if f, ok := m.(Function); ok {
+				list = append(list, f)
+			}
+		}
+	}
+	return list
+}
+
+// hasDirective reports whether the function has the directive, ignoring case
+// and directive arguments.
+func hasDirective(f Function, directive string) bool {
+	for _, d := range f.Directives {
+		if i := strings.IndexByte(d, ' '); i != -1 {
+			d = d[:i]
+		}
+		if strings.EqualFold(d, directive) {
+			return true
+		}
+	}
+	return false
+}
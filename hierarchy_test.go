@@ -0,0 +1,82 @@
+package pas_test
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/pas"
+)
+
+const hierarchyCode = `unit U;
+interface
+uses Forms;
+type IBase = interface
+  procedure Base;
+end;
+type IDerived = interface(IBase)
+  procedure Derived;
+end;
+type TAnimal = class(TInterfacedObject, IDerived)
+  procedure Base;
+  procedure Speak; virtual; abstract;
+  procedure Move; virtual; abstract;
+end;
+type TDog = class(TAnimal)
+  procedure Speak; override;
+  procedure Sit; override;
+  function ToString: string; override;
+end;
+type TPuppy = class(TDog, IBase)
+end;
+type TMyForm = class(TForm, IDerived)
+  procedure Show; override;
+end;
+implementation
+end.`
+
+func names(types []*pas.HierarchyType) []string {
+	var list []string
+	for _, t := range types {
+		list = append(list, t.Name())
+	}
+	return list
+}
+
+func TestHierarchySeparatesParentAndInterfaces(t *testing.T) {
+	h := pas.NewHierarchy(pas.ResolveNames(parseUnits(t, hierarchyCode)))
+	check.Eq(t, names(h.Types), []string{"IBase", "IDerived", "TAnimal", "TDog", "TPuppy", "TMyForm"})
+
+	animal := h.Find("tanimal")
+	check.Eq(t, animal.ParentName, "TInterfacedObject")
+	check.Eq(t, animal.Parent == nil, true)
+	check.Eq(t, animal.InterfaceNames, []string{"IDerived"})
+	check.Eq(t, names(animal.Interfaces), []string{"IDerived"})
+	check.Eq(t, names(animal.Descendants()), []string{"TDog", "TPuppy"})
+
+	puppy := h.Find("U.TPuppy")
+	check.Eq(t, puppy.ParentName, "TDog")
+	check.Eq(t, names(puppy.Ancestors()), []string{"TDog", "TAnimal"})
+
+	form := h.Find("TMyForm")
+	check.Eq(t, form.ParentName, "TForm")
+	check.Eq(t, form.Parent == nil, true)
+
+	check.Eq(t, names(h.Find("IDerived").Ancestors()), []string{"IBase"})
+	check.Eq(t, names(h.Find("IBase").Implementers), []string{"TPuppy"})
+	check.Eq(t, names(h.Find("IDerived").Implementers), []string{"TAnimal", "TMyForm"})
+}
+
+func TestHierarchyCheck(t *testing.T) {
+	h := pas.NewHierarchy(pas.ResolveNames(parseUnits(t, hierarchyCode)))
+	var errs []string
+	for _, err := range h.Check() {
+		errs = append(errs, err.Error())
+	}
+	// TPuppy inherits Base from TAnimal. TMyForm is not reported because
+	// TForm is unknown.
+	check.Eq(t, errs, []string{
+		`class "TAnimal" does not implement "IDerived.Derived" at 10:6`,
+		`abstract method "TAnimal.Move" is never overridden at 13:3`,
+		`method "TDog.Sit" overrides no virtual method at 17:3`,
+	})
+}
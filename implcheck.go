@@ -0,0 +1,226 @@
+package pas
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CheckImplementations matches the methods that are declared in the classes of
+// the unit with the method implementations in its implementation section. It
+// reports methods without implementation, implementations without declaration
+// and implementations whose header differs from the declaration in the
+// parameter names, types or qualifiers, the return type or the kind of method.
+//
+// Overloaded methods are matched by their parameter types. Like in Delphi, an
+// implementation of a method that is not overloaded can leave out the parameter
+// list and return type. Abstract methods and methods of interfaces need no
+// implementation.
+func CheckImplementations(f *File) []*TypeError {
+	var errs []*TypeError
+	report := func(n Node, format string, args ...interface{}) {
+		pos, _ := n.Span()
+		errs = append(errs, &TypeError{Pos: pos, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	decls := matchImplementations(f, report)
+	for _, m := range decls {
+		if m.impl == nil {
+			report(m.decl, `method "%s.%s" is not implemented`, m.class.Name, m.decl.Name)
+		}
+	}
+	sortTypeErrors(errs)
+	return errs
+}
+
+// methodImpl is a method declaration and its implementation, which is nil if
+// the method is not implemented.
+type methodImpl struct {
+	class Class
+	decl  Function
+	impl  *Routine
+}
+
+// matchImplementations returns the methods of the non-interface classes of f
+// that need an implementation, matched with the routines that implement them.
+// Routines that match no method or whose header differs from the declaration
+// are reported.
+func matchImplementations(f *File, report func(n Node, format string, args ...interface{})) []*methodImpl {
+	var classes []Class
+	var decls []*methodImpl
+	var routines []Routine
+	for _, s := range f.Sections {
+		for _, b := range s.Blocks {
+			switch b := b.(type) {
+			case TypeBlock:
+				for _, t := range b {
+					if c, ok := t.(Class); ok && !c.IsInterface {
+						classes = append(classes, c)
+						for _, m := range methods(c) {
+							if !hasDirective(m, "abstract") && !hasDirective(m, "external") {
+								decls = append(decls, &methodImpl{class: c, decl: m})
+							}
+						}
+					}
+				}
+			case Routine:
+				if b.ClassName != "" {
+					routines = append(routines, b)
+				}
+			}
+		}
+	}
+
+	for i := range routines {
+		r := &routines[i]
+		full := r.ClassName + "." + r.Header.Name
+		var candidates []*methodImpl
+		for _, m := range decls {
+			if SameIdentifier(m.class.Name, r.ClassName) &&
+				SameIdentifier(m.decl.Name, r.Header.Name) {
+				candidates = append(candidates, m)
+			}
+		}
+		if len(candidates) == 0 {
+			known := false
+			for _, c := range classes {
+				known = known || SameIdentifier(c.Name, r.ClassName)
+			}
+			if known {
+				report(r.Header, `method "%s" is implemented but not declared`, full)
+			} else {
+				report(r.Header, `class "%s" of method "%s" is not declared`, r.ClassName, full)
+			}
+			continue
+		}
+		if len(candidates) == 1 {
+			m := candidates[0]
+			if m.impl != nil {
+				report(r.Header, `method "%s" is implemented twice`, full)
+			} else if diff := headerDiff(m.decl, r.Header); diff != "" {
+				report(r.Header, `implementation of "%s" differs from its declaration: %s`, full, diff)
+			}
+			m.impl = r
+			continue
+		}
+		var match *methodImpl
+		for _, m := range candidates {
+			if m.impl == nil && sameParameterTypes(m.decl, r.Header) {
+				match = m
+				break
+			}
+		}
+		if match == nil {
+			report(r.Header, `implementation of "%s" matches none of its overloads`, full)
+			continue
+		}
+		if diff := headerDiff(match.decl, r.Header); diff != "" {
+			report(r.Header, `implementation of "%s" differs from its declaration: %s`, full, diff)
+		}
+		match.impl = r
+	}
+	return decls
+}
+
+// parameter is a single parameter of a function, where parameters with multiple
+// names are split up.
+type parameter struct {
+	name, typ string
+	qualifier Qualifier
+}
+
+func parametersOf(f Function) []parameter {
+	var list []parameter
+	for _, p := range f.Parameters {
+		q := p.Qualifier
+		if q == RefConst {
+			q = ConstRef // These mean the same.
+		}
+		for _, name := range p.Names {
+			list = append(list, parameter{name: name, typ: p.Type, qualifier: q})
+		}
+	}
+	return list
+}
+
+func sameParameterTypes(a, b Function) bool {
+	pa, pb := parametersOf(a), parametersOf(b)
+	if len(pa) != len(pb) {
+		return false
+	}
+	for i := range pa {
+		if !sameTypeName(pa[i].typ, pb[i].typ) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameTypeName compares type names, ignoring a unit qualification that only
+// one of them has, e.g. "Integer" and "System.Integer" are the same.
+func sameTypeName(a, b string) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	return SameIdentifier(a, b) ||
+		len(b) > len(a) && b[len(b)-len(a)-1] == '.' &&
+			SameIdentifier(a, b[len(b)-len(a):])
+}
+
+// headerDiff describes the first difference between a method declaration and
+// the header of its implementation, or returns "" if they match.
+func headerDiff(decl, impl Function) string {
+	if decl.FunctionKind != impl.FunctionKind {
+		return "it is declared as " + functionKeyword(decl)
+	}
+	if decl.IsClassMethod != impl.IsClassMethod {
+		if decl.IsClassMethod {
+			return "it is declared as class method"
+		}
+		return "it is not declared as class method"
+	}
+	if len(impl.Parameters) == 0 && impl.Returns == "" {
+		// Delphi allows leaving out the parameters and return type.
+		return ""
+	}
+	pd, pi := parametersOf(decl), parametersOf(impl)
+	if len(pd) != len(pi) {
+		return fmt.Sprintf("it has %d parameters instead of %d", len(pi), len(pd))
+	}
+	for i := range pd {
+		d, m := pd[i], pi[i]
+		if !SameIdentifier(d.name, m.name) {
+			return fmt.Sprintf(`parameter %d is named "%s" instead of "%s"`, i+1, m.name, d.name)
+		}
+		if !sameTypeName(d.typ, m.typ) {
+			return fmt.Sprintf(`parameter "%s" has type "%s" instead of "%s"`, m.name, m.typ, d.typ)
+		}
+		if d.qualifier != m.qualifier {
+			return fmt.Sprintf(`parameter "%s" has qualifier "%s" instead of "%s"`,
+				m.name, m.qualifier, d.qualifier)
+		}
+	}
+	if !sameTypeName(decl.Returns, impl.Returns) {
+		if decl.Returns == "" {
+			return "it is declared as procedure"
+		}
+		return fmt.Sprintf(`it returns "%s" instead of "%s"`, impl.Returns, decl.Returns)
+	}
+	return ""
+}
+
+func functionKeyword(f Function) string {
+	if f.FunctionKind != PlainFunction {
+		return f.FunctionKind.String()
+	}
+	if f.Returns == "" {
+		return "procedure"
+	}
+	return "function"
+}
+
+// sortTypeErrors sorts errors of the same file by position.
+func sortTypeErrors(errs []*TypeError) {
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Pos.Offset < errs[j].Pos.Offset
+	})
+}
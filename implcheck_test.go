@@ -0,0 +1,103 @@
+package pas_test
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/pas"
+)
+
+func TestParseRoutines(t *testing.T) {
+	f, err := pas.ParseString(`unit U;
+interface
+implementation
+class function TFoo.Bar.Make(A: Integer): TFoo;
+  procedure Nested;
+  begin
+  end;
+var
+  I: Integer;
+begin
+  case A of
+    1: try Nested; finally end;
+  end;
+end;
+procedure Later; forward;
+procedure Beep; external 'kernel32.dll' name 'Beep';
+end.`)
+	check.Eq(t, err, nil)
+	blocks := f.Sections[1].Blocks
+	check.Eq(t, len(blocks), 3)
+
+	routine := blocks[0].(pas.Routine)
+	check.Eq(t, routine.ClassName, "TFoo.Bar")
+	check.Eq(t, routine.Header.Name, "Make")
+	check.Eq(t, routine.Header.IsClassMethod, true)
+	check.Eq(t, routine.Header.Returns, "TFoo")
+	check.Eq(t, routine.BodyPos.String(), "5:3")
+	check.Eq(t, routine.End.String(), "14:5")
+	check.Eq(t, routine.Body, `procedure Nested;
+  begin
+  end;
+var
+  I: Integer;
+begin
+  case A of
+    1: try Nested; finally end;
+  end;
+end;`)
+
+	later := blocks[1].(pas.Routine)
+	check.Eq(t, later.Header.Directives, []string{"forward"})
+	check.Eq(t, later.Body, "")
+	beep := blocks[2].(pas.Routine)
+	check.Eq(t, beep.Header.Directives, []string{"external 'kernel32.dll' name 'Beep'"})
+}
+
+func TestCheckImplementations(t *testing.T) {
+	f, err := pas.ParseString(`unit U;
+interface
+type TFoo = class
+  constructor Create;
+  procedure Missing;
+  procedure Abstract; virtual; abstract;
+  procedure NoParams(A: Integer; const B: string);
+  procedure Renamed(A: Integer);
+  procedure Retyped(A: Integer);
+  procedure Requalified(var A: Integer);
+  function Returns: Integer;
+  procedure Over(A: Integer); overload;
+  procedure Over(A: string); overload;
+  class procedure ClassProc;
+end;
+implementation
+procedure TFoo.Create; begin end;
+procedure TFoo.NoParams; begin end;
+procedure TFoo.Renamed(B: Integer); begin end;
+procedure TFoo.Retyped(A: System.Integer); begin end;
+procedure TFoo.Requalified(out A: Integer); begin end;
+function TFoo.Returns: string; begin end;
+procedure TFoo.Over(A: string); begin end;
+procedure TFoo.Over(A: Boolean); begin end;
+procedure TFoo.ClassProc; begin end;
+procedure TFoo.Undeclared; begin end;
+procedure TBar.Method; begin end;
+end.`)
+	check.Eq(t, err, nil)
+	var errs []string
+	for _, err := range pas.CheckImplementations(f) {
+		errs = append(errs, err.Error())
+	}
+	check.Eq(t, errs, []string{
+		`method "TFoo.Missing" is not implemented at 5:3`,
+		`method "TFoo.Over" is not implemented at 12:3`,
+		`implementation of "TFoo.Create" differs from its declaration: it is declared as constructor at 17:1`,
+		`implementation of "TFoo.Renamed" differs from its declaration: parameter 1 is named "B" instead of "A" at 19:1`,
+		`implementation of "TFoo.Requalified" differs from its declaration: parameter "A" has qualifier "out" instead of "var" at 21:1`,
+		`implementation of "TFoo.Returns" differs from its declaration: it returns "string" instead of "Integer" at 22:1`,
+		`implementation of "TFoo.Over" matches none of its overloads at 24:1`,
+		`implementation of "TFoo.ClassProc" differs from its declaration: it is declared as class method at 25:1`,
+		`method "TFoo.Undeclared" is implemented but not declared at 26:1`,
+		`class "TBar" of method "TBar.Method" is not declared at 27:1`,
+	})
+}
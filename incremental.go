@@ -0,0 +1,557 @@
+package pas
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ParseIncremental parses the code for later edits, see
+// Options.ParseIncremental.
+func ParseIncremental(code string) (*Incremental, error) {
+	return Options{}.ParseIncremental(code)
+}
+
+// ParseIncremental parses the code like ParseString and keeps it, so that
+// edits can be applied with Incremental.Edit. The returned Incremental is never
+// nil, like with ParseString the error is the list of syntax errors.
+func (o Options) ParseIncremental(code string) (*Incremental, error) {
+	inc := &Incremental{filename: o.Filename}
+	err := inc.parse(code)
+	return inc, err
+}
+
+// Incremental is a parsed file that is kept up to date while its code is being
+// edited, e.g. in an editor. Each edit only reparses the top-level declarations
+// that it touches. All other declarations are reused, with their positions
+// moved if they come after the edit.
+//
+// The File after an edit is always the same as if the new code was parsed from
+// scratch. If that cannot be guaranteed, e.g. because the code has syntax
+// errors or the edit changes the unit header or a section keyword, the whole
+// code is parsed again.
+type Incremental struct {
+	// File is the syntax tree of the current code. Edits create a new File,
+	// previous Files are not changed.
+	File     *File
+	filename string
+	code     string
+	err      error
+}
+
+// TextEdit replaces the code between the byte offsets Pos and End with Text.
+// For an insertion, Pos and End are the same.
+type TextEdit struct {
+	Pos, End int
+	Text     string
+}
+
+// Code returns the current code.
+func (inc *Incremental) Code() string {
+	return inc.code
+}
+
+// Err returns the syntax errors of the current code, which is the error of the
+// last parse or edit.
+func (inc *Incremental) Err() error {
+	return inc.err
+}
+
+// Edit applies the edit to the code and updates File. It returns the syntax
+// errors of the new code, like ParseString.
+//
+// If the edit is outside of the code, an error is returned and nothing is
+// changed.
+func (inc *Incremental) Edit(edit TextEdit) error {
+	if edit.Pos < 0 || edit.End < edit.Pos || edit.End > len(inc.code) {
+		return errors.New("edit range is outside of the code")
+	}
+	code := inc.code[:edit.Pos] + edit.Text + inc.code[edit.End:]
+	if inc.err == nil {
+		if f, ok := inc.reparse(code, edit); ok {
+			inc.File = f
+			inc.code = code
+			return nil
+		}
+	}
+	return inc.parse(code)
+}
+
+func (inc *Incremental) parse(code string) error {
+	p := newParser([]rune(code))
+	p.tokens.filename = inc.filename
+	inc.File, inc.err = p.parseFile()
+	inc.code = code
+	return inc.err
+}
+
+// reparse creates the File for the new code by parsing only the declarations
+// around the edit. It returns false if the whole code must be parsed instead.
+//
+// The code that is parsed again is called the region. It starts right after
+// the last declaration in front of the edit, or at the section keyword if
+// there is none, and ends at the start of the first declaration after the
+// edit, or at the next section keyword if there is none. This way, the white
+// space and comments around the edit are part of the region as well.
+func (inc *Incremental) reparse(code string, edit TextEdit) (*File, bool) {
+	f := inc.File
+	for i := range f.Sections {
+		sectionEnd, ok := inc.sectionEnd(i)
+		if !ok {
+			return nil, false
+		}
+		s := f.Sections[i]
+		if s.Pos.Offset <= edit.Pos && edit.End < sectionEnd {
+			// The statements of initialization and finalization sections
+			// are parsed with the whole code.
+			if s.Kind != InterfaceSection && s.Kind != ImplementationSection {
+				return nil, false
+			}
+			return inc.reparseSection(code, edit, i, sectionEnd)
+		}
+	}
+	return nil, false
+}
+
+// sectionEnd returns the offset of the keyword after section i, which is the
+// next section keyword or the final "end" of the unit.
+func (inc *Incremental) sectionEnd(i int) (int, bool) {
+	f := inc.File
+	if i+1 < len(f.Sections) {
+		return f.Sections[i+1].Pos.Offset, true
+	}
+	// The unit ends in "end." and we look for the "end" in front of the dot.
+	// Comments between them are possible but so rare that we rather parse the
+	// whole code than tokenize it backwards.
+	i = f.End.Offset - 1
+	if i < 0 || i >= len(inc.code) || inc.code[i] != '.' {
+		return 0, false
+	}
+	i = len(strings.TrimRight(inc.code[:i], " \t\r\n")) - len("end")
+	if i < 1 || !strings.EqualFold(inc.code[i:i+len("end")], "end") {
+		return 0, false
+	}
+	if r, _ := utf8.DecodeLastRuneInString(inc.code[:i]); r == '&' || r == '_' ||
+		unicode.IsLetter(r) || unicode.IsDigit(r) {
+		return 0, false
+	}
+	return i, true
+}
+
+func (inc *Incremental) reparseSection(code string, edit TextEdit, index, sectionEnd int) (*File, bool) {
+	f := inc.File
+	section := f.Sections[index]
+	blocks := section.Blocks
+
+	// Block k owns the code from the end of block k-1 to its own end, the
+	// last part from the end of the last block up to the section end is
+	// called the tail and has index len(blocks). Edits that touch the border
+	// of two parts affect both of them.
+	start := func(k int) int {
+		if k == 0 {
+			return section.Pos.Offset
+		}
+		_, end := blocks[k-1].Span()
+		return end.Offset
+	}
+	end := func(k int) int {
+		if k == len(blocks) {
+			return sectionEnd
+		}
+		_, end := blocks[k].Span()
+		return end.Offset
+	}
+	for _, b := range blocks {
+		// Empty var and const blocks have no position.
+		if pos, _ := b.Span(); pos.Line == 0 {
+			return nil, false
+		}
+	}
+	first, last := -1, -1
+	for k := 0; k <= len(blocks); k++ {
+		if start(k) <= edit.End && edit.Pos <= end(k) {
+			if first == -1 {
+				first = k
+			}
+			last = k
+		}
+	}
+	if first == -1 {
+		return nil, false
+	}
+
+	if last == len(blocks)-1 {
+		// The tail has the trailing comment of the last block.
+		last = len(blocks)
+	}
+	regionStart := start(first)
+	regionEnd := sectionEnd
+	if last < len(blocks) {
+		next, _ := blocks[last+1].Span()
+		regionEnd = inc.tokenStart(end(last), next.Offset)
+	}
+	delta := len(edit.Text) - (edit.End - edit.Pos)
+	newRegionEnd := regionEnd + delta
+
+	// Parse the region as if the parser had just parsed the block in front of
+	// it, or the code in front of the section.
+	p := newParser([]rune(code[regionStart:newRegionEnd]))
+	p.tokens.filename = inc.filename
+	startPos := positionAt(code, regionStart, inc.filename)
+	p.tokens.offset = startPos.Offset
+	p.tokens.line = startPos.Line
+	p.tokens.col = startPos.Col
+	var (
+		newSection FileSection
+		newBlocks  []FileSectionBlock
+		trailing   *CommentGroup
+	)
+	if first == 0 {
+		p.eatWord(section.Kind.String())
+		p.parseFileSection(section.Kind)
+		if len(p.file.Sections) == 1 {
+			newSection = p.file.Sections[0]
+			newBlocks = newSection.Blocks
+		}
+	} else {
+		_, prevEnd := blocks[first-1].Span()
+		p.prevLine = prevEnd.Line
+		trailing = p.trailingComment()
+		newBlocks = p.parseSectionBlocks(section.Kind)
+	}
+	if p.err != nil || len(p.errs) != 0 || !p.sees(tokenEOF) ||
+		endsInOpenComment(code, p.file.Comments, newRegionEnd) {
+		return nil, false
+	}
+
+	oldEnd := positionAt(inc.code, regionEnd, inc.filename)
+	newEnd := positionAt(code, newRegionEnd, inc.filename)
+	s := shifter{
+		line:      oldEnd.Line,
+		delta:     delta,
+		lineDelta: newEnd.Line - oldEnd.Line,
+		colDelta:  newEnd.Col - oldEnd.Col,
+		moved:     make(map[*CommentGroup]*CommentGroup),
+		region:    make(map[int]*CommentGroup),
+	}
+
+	newFile := *f
+	newFile.Comments = nil
+	firstInRegion := sort.Search(len(f.Comments), func(i int) bool {
+		return f.Comments[i].List[0].Pos.Offset >= regionStart
+	})
+	firstAfterRegion := sort.Search(len(f.Comments), func(i int) bool {
+		return f.Comments[i].List[0].Pos.Offset >= regionEnd
+	})
+	newFile.Comments = append(newFile.Comments, f.Comments[:firstInRegion]...)
+	for _, g := range p.file.Comments {
+		newFile.Comments = append(newFile.Comments, g)
+		s.region[g.List[0].Pos.Offset] = g
+	}
+	for _, g := range f.Comments[firstAfterRegion:] {
+		moved := &CommentGroup{List: make([]Comment, len(g.List))}
+		for i, c := range g.List {
+			moved.List[i] = Comment{Text: c.Text, Pos: s.pos(c.Pos), End: s.pos(c.End)}
+		}
+		s.moved[g] = moved
+		newFile.Comments = append(newFile.Comments, moved)
+	}
+
+	var sectionBlocks []FileSectionBlock
+	sectionBlocks = append(sectionBlocks, blocks[:first]...)
+	if first > 0 {
+		sectionBlocks[first-1] = withTrailingComment(sectionBlocks[first-1], trailing)
+	}
+	sectionBlocks = append(sectionBlocks, newBlocks...)
+	for k := last + 1; k < len(blocks); k++ {
+		sectionBlocks = append(sectionBlocks, s.block(blocks[k]))
+	}
+
+	newFile.Sections = make([]FileSection, len(f.Sections))
+	copy(newFile.Sections, f.Sections)
+	changed := &newFile.Sections[index]
+	if first == 0 {
+		changed.Uses = newSection.Uses
+		changed.Pos = newSection.Pos
+	}
+	changed.Blocks = sectionBlocks
+	if last == len(blocks) {
+		// The section ends after its last declaration or after the uses
+		// clause or keyword if it has no declarations.
+		if len(newBlocks) > 0 {
+			_, changed.End = newBlocks[len(newBlocks)-1].Span()
+		} else if first == 0 {
+			changed.End = newSection.End
+		} else {
+			_, changed.End = blocks[first-1].Span()
+		}
+	} else {
+		changed.End = s.pos(changed.End)
+	}
+	for i := index + 1; i < len(newFile.Sections); i++ {
+		later := &newFile.Sections[i]
+		later.Pos = s.pos(later.Pos)
+		later.End = s.pos(later.End)
+		later.CodePos = s.pos(later.CodePos)
+		var moved []FileSectionBlock
+		for _, b := range later.Blocks {
+			moved = append(moved, s.block(b))
+		}
+		later.Blocks = moved
+	}
+	newFile.End = s.pos(f.End)
+	return &newFile, true
+}
+
+// tokenStart returns the offset of the first token between from and to that is
+// not white space or a comment, or to if there is none. This is the keyword in
+// front of a declaration, e.g. "type".
+func (inc *Incremental) tokenStart(from, to int) int {
+	t := newTokenizer([]rune(inc.code[from:to]))
+	for {
+		tok := t.next()
+		if tok.tokenType == tokenEOF {
+			return to
+		}
+		if tok.tokenType != tokenWhiteSpace && tok.tokenType != tokenComment {
+			return from + tok.offset
+		}
+	}
+}
+
+// endsInOpenComment reports whether the last comment reaches the end offset
+// without being closed. In the whole code, it would continue after the end.
+func endsInOpenComment(code string, comments []*CommentGroup, end int) bool {
+	if len(comments) == 0 {
+		return false
+	}
+	g := comments[len(comments)-1]
+	c := g.List[len(g.List)-1]
+	// The End of comments does not include the line break of line comments.
+	rest := code[c.End.Offset:end]
+	if strings.TrimRight(rest, "\r\n") != "" {
+		return false
+	}
+	text := code[c.Pos.Offset:c.End.Offset]
+	if strings.HasPrefix(text, "//") {
+		return !strings.Contains(rest, "\n")
+	}
+	if strings.HasPrefix(text, "(*") {
+		return len(text) < 4 || !strings.HasSuffix(text, "*)")
+	}
+	return !strings.HasSuffix(text, "}")
+}
+
+// positionAt returns the position of the byte offset in the code.
+func positionAt(code string, offset int, filename string) Position {
+	before := code[:offset]
+	lineStart := strings.LastIndexByte(before, '\n') + 1
+	return Position{
+		Filename: filename,
+		Offset:   offset,
+		Line:     1 + strings.Count(before, "\n"),
+		Col:      1 + utf8.RuneCountInString(before[lineStart:]),
+	}
+}
+
+// withTrailingComment returns the block with the comment on the line of its
+// end. Only classes, variables and constants keep this comment.
+func withTrailingComment(b FileSectionBlock, comment *CommentGroup) FileSectionBlock {
+	switch b := b.(type) {
+	case TypeBlock:
+		types := make(TypeBlock, len(b))
+		copy(types, b)
+		if class, ok := types[len(types)-1].(Class); ok {
+			class.Comment = comment
+			types[len(types)-1] = class
+		}
+		return types
+	case VarBlock:
+		vars := make(VarBlock, len(b))
+		copy(vars, b)
+		vars[len(vars)-1].Comment = comment
+		return vars
+	case ConstBlock:
+		consts := make(ConstBlock, len(b))
+		copy(consts, b)
+		consts[len(consts)-1].Comment = comment
+		return consts
+	}
+	return b
+}
+
+// shifter moves the nodes after a reparsed region to their positions in the new
+// code. It creates copies of the nodes, the old nodes are not changed.
+type shifter struct {
+	// line is the line of the region end in the old code. Columns only change
+	// for positions on that line.
+	line                       int
+	delta, lineDelta, colDelta int
+	// moved maps the comment groups after the region to their moved copies.
+	moved map[*CommentGroup]*CommentGroup
+	// region has the comment groups of the reparsed region by their new
+	// offsets. The doc comments of the first declaration after the region are
+	// in the region.
+	region map[int]*CommentGroup
+}
+
+func (s *shifter) pos(p Position) Position {
+	if p.Line == 0 {
+		// Unset positions, e.g. the BodyPos of forward declarations, stay
+		// unset.
+		return p
+	}
+	if p.Line == s.line {
+		p.Col += s.colDelta
+	}
+	p.Line += s.lineDelta
+	p.Offset += s.delta
+	return p
+}
+
+func (s *shifter) comment(g *CommentGroup) *CommentGroup {
+	if g == nil {
+		return nil
+	}
+	if moved, ok := s.moved[g]; ok {
+		return moved
+	}
+	return s.region[g.List[0].Pos.Offset+s.delta]
+}
+
+func (s *shifter) block(b FileSectionBlock) FileSectionBlock {
+	switch b := b.(type) {
+	case TypeBlock:
+		types := make(TypeBlock, len(b))
+		for i, t := range b {
+			if class, ok := t.(Class); ok {
+				t = s.class(class)
+			}
+			types[i] = t
+		}
+		return types
+	case VarBlock:
+		vars := make(VarBlock, len(b))
+		for i, v := range b {
+			vars[i] = s.variable(v)
+		}
+		return vars
+	case ConstBlock:
+		consts := make(ConstBlock, len(b))
+		for i, c := range b {
+			consts[i] = s.constant(c)
+		}
+		return consts
+	case Routine:
+		b.Header = s.function(b.Header)
+		b.Pos, b.BodyPos, b.End = s.pos(b.Pos), s.pos(b.BodyPos), s.pos(b.End)
+		return b
+	}
+	return b
+}
+
+func (s *shifter) class(c Class) Class {
+	if c.Sections != nil {
+		sections := make([]ClassSection, len(c.Sections))
+		for i, section := range c.Sections {
+			if section.Members != nil {
+				members := make([]ClassMember, len(section.Members))
+				for j, m := range section.Members {
+					members[j] = s.member(m)
+				}
+				section.Members = members
+			}
+			section.Pos, section.End = s.pos(section.Pos), s.pos(section.End)
+			sections[i] = section
+		}
+		c.Sections = sections
+	}
+	c.Attributes = s.attributes(c.Attributes)
+	c.Doc, c.Comment = s.comment(c.Doc), s.comment(c.Comment)
+	c.Pos, c.End, c.NamePos = s.pos(c.Pos), s.pos(c.End), s.pos(c.NamePos)
+	c.SuperClassPos, c.SuperClassEnd = s.positions(c.SuperClassPos), s.positions(c.SuperClassEnd)
+	return c
+}
+
+func (s *shifter) positions(list []Position) []Position {
+	if list == nil {
+		return nil
+	}
+	moved := make([]Position, len(list))
+	for i, p := range list {
+		moved[i] = s.pos(p)
+	}
+	return moved
+}
+
+func (s *shifter) member(m ClassMember) ClassMember {
+	switch m := m.(type) {
+	case Variable:
+		return s.variable(m)
+	case Function:
+		return s.function(m)
+	case Property:
+		m.Parameters = s.parameters(m.Parameters)
+		m.Attributes = s.attributes(m.Attributes)
+		m.Doc, m.Comment = s.comment(m.Doc), s.comment(m.Comment)
+		m.Pos, m.End, m.NamePos = s.pos(m.Pos), s.pos(m.End), s.pos(m.NamePos)
+		m.TypePos, m.TypeEnd = s.pos(m.TypePos), s.pos(m.TypeEnd)
+		m.ReadPos, m.ReadEnd = s.pos(m.ReadPos), s.pos(m.ReadEnd)
+		m.WritePos, m.WriteEnd = s.pos(m.WritePos), s.pos(m.WriteEnd)
+		return m
+	}
+	return m
+}
+
+func (s *shifter) variable(v Variable) Variable {
+	v.Attributes = s.attributes(v.Attributes)
+	v.Doc, v.Comment = s.comment(v.Doc), s.comment(v.Comment)
+	v.Pos, v.End, v.NamePos = s.pos(v.Pos), s.pos(v.End), s.pos(v.NamePos)
+	v.TypePos, v.TypeEnd = s.pos(v.TypePos), s.pos(v.TypeEnd)
+	return v
+}
+
+func (s *shifter) constant(c Constant) Constant {
+	c.Attributes = s.attributes(c.Attributes)
+	c.Doc, c.Comment = s.comment(c.Doc), s.comment(c.Comment)
+	c.Pos, c.End, c.NamePos = s.pos(c.Pos), s.pos(c.End), s.pos(c.NamePos)
+	c.TypePos, c.TypeEnd = s.pos(c.TypePos), s.pos(c.TypeEnd)
+	return c
+}
+
+func (s *shifter) function(f Function) Function {
+	f.Parameters = s.parameters(f.Parameters)
+	f.Attributes = s.attributes(f.Attributes)
+	f.Doc, f.Comment = s.comment(f.Doc), s.comment(f.Comment)
+	f.Pos, f.End, f.NamePos = s.pos(f.Pos), s.pos(f.End), s.pos(f.NamePos)
+	f.ReturnsPos, f.ReturnsEnd = s.pos(f.ReturnsPos), s.pos(f.ReturnsEnd)
+	return f
+}
+
+func (s *shifter) parameters(params []Parameter) []Parameter {
+	if params == nil {
+		return nil
+	}
+	moved := make([]Parameter, len(params))
+	for i, p := range params {
+		p.Attributes = s.attributes(p.Attributes)
+		p.Pos, p.End = s.pos(p.Pos), s.pos(p.End)
+		p.TypePos, p.TypeEnd = s.pos(p.TypePos), s.pos(p.TypeEnd)
+		moved[i] = p
+	}
+	return moved
+}
+
+func (s *shifter) attributes(attributes []Attribute) []Attribute {
+	if attributes == nil {
+		return nil
+	}
+	moved := make([]Attribute, len(attributes))
+	for i, a := range attributes {
+		a.Pos, a.End, a.NameEnd = s.pos(a.Pos), s.pos(a.End), s.pos(a.NameEnd)
+		moved[i] = a
+	}
+	return moved
+}
@@ -0,0 +1,151 @@
+package pas_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/pas"
+)
+
+const incrementalCode = `unit Shapes;
+
+interface
+
+uses
+  Classes;
+
+type
+  /// The base class.
+  TShape = class
+  private
+    FName: string; // trailing
+    function GetName: string;
+  public
+    [Stored]
+    property Name: string read GetName write FName;
+    procedure Draw(Canvas: TObject; const Scale: Double); virtual;
+  end; // after the class
+
+type
+  { TCircle is round. }
+  TCircle = class(TShape)
+    Radius: Integer;
+  end;
+
+var
+  Count: Integer;
+  Größe: Double; // ü
+
+const
+  Max = 2 * (Count + 1); // two
+  Origin: TPoint = (X: 0; Y: 0);
+
+implementation
+
+procedure Helper; forward;
+
+function TShape.GetName: string;
+begin
+  Result := FName; // body
+end;
+
+(* Draws nothing. *)
+procedure TShape.Draw(Canvas: TObject; const Scale: Double);
+begin
+end;
+
+procedure Helper;
+begin
+  Inc(Count);
+end;
+
+end.
+`
+
+// checkIncremental compares the incremental File to parsing the code from
+// scratch.
+func checkIncremental(t *testing.T, inc *pas.Incremental, err error, edit pas.TextEdit) {
+	t.Helper()
+	want, wantErr := pas.ParseString(inc.Code())
+	if !reflect.DeepEqual(inc.File, want) || !reflect.DeepEqual(err, wantErr) {
+		t.Fatalf("edit %#v gives a different result than parsing\n%s", edit, inc.Code())
+	}
+}
+
+func TestIncrementalEditsGiveTheSameTreeAsParsing(t *testing.T) {
+	insertions := []string{"x", " ", ";", "\n", "{", "}", "//", "'", "ä", "end;"}
+	for pos := 0; pos < len(incrementalCode); pos++ {
+		if !utf8.RuneStart(incrementalCode[pos]) {
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(incrementalCode[pos:])
+		edits := []pas.TextEdit{{Pos: pos, End: pos + size}}
+		for _, text := range insertions {
+			edits = append(edits, pas.TextEdit{Pos: pos, End: pos, Text: text})
+		}
+		for _, edit := range edits {
+			inc, err := pas.ParseIncremental(incrementalCode)
+			check.Eq(t, err, nil)
+			err = inc.Edit(edit)
+			checkIncremental(t, inc, err, edit)
+		}
+	}
+}
+
+func TestIncrementalTyping(t *testing.T) {
+	// Type a new method into TShape and its implementation, one character at
+	// a time, which goes through many states with syntax errors.
+	inc, err := pas.ParseIncremental(incrementalCode)
+	check.Eq(t, err, nil)
+	typeText := func(at, text string) {
+		pos := strings.Index(inc.Code(), at)
+		for _, r := range text {
+			edit := pas.TextEdit{Pos: pos, End: pos, Text: string(r)}
+			err := inc.Edit(edit)
+			checkIncremental(t, inc, err, edit)
+			pos += utf8.RuneLen(r)
+		}
+	}
+	typeText("  end; // after", "    procedure Hide;\n")
+	typeText("procedure Helper;\nbegin", "procedure TShape.Hide;\nbegin\nend;\n\n")
+	check.Eq(t, strings.Contains(inc.Code(), "TShape.Hide"), true)
+	check.Eq(t, len(inc.File.Sections[1].Blocks), 5)
+}
+
+func TestIncrementalEditReusesUnchangedDeclarations(t *testing.T) {
+	inc, err := pas.ParseIncremental(incrementalCode)
+	check.Eq(t, err, nil)
+	old := inc.File
+	pos := strings.Index(incrementalCode, "Inc(Count)")
+	check.Eq(t, inc.Edit(pas.TextEdit{Pos: pos, End: pos + 3, Text: "Dec"}), nil)
+
+	// The interface section was not parsed again.
+	oldClass := old.Sections[0].Blocks[0].(pas.TypeBlock)
+	newClass := inc.File.Sections[0].Blocks[0].(pas.TypeBlock)
+	check.Eq(t, &oldClass[0] == &newClass[0], true)
+	check.Eq(t, inc.File.Sections[1].Blocks[3].(pas.Routine).Body, "begin\n  Dec(Count);\nend;")
+
+	// The previous File is not changed.
+	want, _ := pas.ParseString(incrementalCode)
+	check.Eq(t, old, want)
+}
+
+func TestIncrementalEditMovesLaterDeclarations(t *testing.T) {
+	inc, err := pas.ParseIncremental(incrementalCode)
+	check.Eq(t, err, nil)
+	pos := strings.Index(incrementalCode, "  Count")
+	check.Eq(t, inc.Edit(pas.TextEdit{Pos: pos, End: pos, Text: "  Total: Int64;\n"}), nil)
+	helper := inc.File.Sections[1].Blocks[3].(pas.Routine)
+	check.Eq(t, helper.Header.Name, "Helper")
+	check.Eq(t, helper.Pos.Line, 49)
+	check.Eq(t, helper.Pos.Offset, strings.LastIndex(inc.Code(), "procedure Helper"))
+}
+
+func TestIncrementalEditOutsideOfTheCode(t *testing.T) {
+	inc, _ := pas.ParseIncremental("unit U; interface implementation end.")
+	check.Neq(t, inc.Edit(pas.TextEdit{Pos: 5, End: 100}), nil)
+	check.Eq(t, inc.Code(), "unit U; interface implementation end.")
+}
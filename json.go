@@ -0,0 +1,442 @@
+package pas
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// The nodes that are stored in the interface types FileSectionBlock,
+// TypeDeclaration and ClassMember are written to JSON as objects with an
+// additional "kind" field, e.g.
+//
+//     {"kind": "Variable", "Name": "A", "Type": "Integer", ...}
+//
+// TypeBlock, VarBlock and ConstBlock are slices, they are written as objects as
+// well:
+//
+//     {"kind": "TypeBlock", "Types": [...]}
+//     {"kind": "VarBlock", "Variables": [...]}
+//     {"kind": "ConstBlock", "Constants": [...]}
+//
+// The "kind" is used to create the right node type when reading the JSON.
+
+// UnmarshalJSON reads a File. Doc and Comment fields are written to JSON as
+// copies of the comment groups in File.Comments, after reading they point into
+// File.Comments again.
+func (f *File) UnmarshalJSON(data []byte) error {
+	type file File
+	if err := json.Unmarshal(data, (*file)(f)); err != nil {
+		return err
+	}
+	f.linkComments()
+	return nil
+}
+
+// linkComments makes the Doc and Comment fields of all nodes point to the
+// comment groups in f.Comments that start at the same position.
+func (f *File) linkComments() {
+	groups := make(map[int]*CommentGroup)
+	for _, g := range f.Comments {
+		if len(g.List) > 0 {
+			groups[g.List[0].Pos.Offset] = g
+		}
+	}
+	link := func(g **CommentGroup) {
+		if *g != nil && len((*g).List) > 0 {
+			if linked, ok := groups[(*g).List[0].Pos.Offset]; ok {
+				*g = linked
+			}
+		}
+	}
+	linkVar := func(v *Variable) {
+		link(&v.Doc)
+		link(&v.Comment)
+	}
+
+	for _, s := range f.Sections {
+		for j, b := range s.Blocks {
+			switch b := b.(type) {
+			case TypeBlock:
+				for i := range b {
+					c, ok := b[i].(Class)
+					if !ok {
+						continue
+					}
+					link(&c.Doc)
+					link(&c.Comment)
+					for _, s := range c.Sections {
+						for j, m := range s.Members {
+							switch m := m.(type) {
+							case Variable:
+								linkVar(&m)
+								s.Members[j] = m
+							case Function:
+								link(&m.Doc)
+								link(&m.Comment)
+								s.Members[j] = m
+							case Property:
+								link(&m.Doc)
+								link(&m.Comment)
+								s.Members[j] = m
+							}
+						}
+					}
+					b[i] = c
+				}
+			case VarBlock:
+				for i := range b {
+					linkVar(&b[i])
+				}
+			case ConstBlock:
+				for i := range b {
+					link(&b[i].Doc)
+					link(&b[i].Comment)
+				}
+			case Routine:
+				link(&b.Header.Doc)
+				link(&b.Header.Comment)
+				s.Blocks[j] = b
+			}
+		}
+	}
+}
+
+func (b TypeBlock) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Kind  string `json:"kind"`
+		Types []TypeDeclaration
+	}{"TypeBlock", b})
+}
+
+func (b *TypeBlock) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Types []json.RawMessage
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*b = nil
+	for _, data := range v.Types {
+		n, err := unmarshalNode(data)
+		if err != nil {
+			return err
+		}
+		d, ok := n.(TypeDeclaration)
+		if !ok {
+			return errors.New("pas: " + nodeKind(n) + " is not a type declaration")
+		}
+		*b = append(*b, d)
+	}
+	return nil
+}
+
+func (b VarBlock) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Kind      string `json:"kind"`
+		Variables []Variable
+	}{"VarBlock", b})
+}
+
+func (b *VarBlock) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Variables []Variable
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*b = v.Variables
+	return nil
+}
+
+func (b ConstBlock) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Kind      string `json:"kind"`
+		Constants []Constant
+	}{"ConstBlock", b})
+}
+
+func (b *ConstBlock) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Constants []Constant
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*b = v.Constants
+	return nil
+}
+
+func (c Class) MarshalJSON() ([]byte, error) {
+	type class Class // class has no MarshalJSON, this avoids endless recursion.
+	return json.Marshal(struct {
+		Kind string `json:"kind"`
+		class
+	}{"Class", class(c)})
+}
+
+func (v Variable) MarshalJSON() ([]byte, error) {
+	type variable Variable
+	return json.Marshal(struct {
+		Kind string `json:"kind"`
+		variable
+	}{"Variable", variable(v)})
+}
+
+func (f Function) MarshalJSON() ([]byte, error) {
+	type function Function
+	return json.Marshal(struct {
+		Kind string `json:"kind"`
+		function
+	}{"Function", function(f)})
+}
+
+func (p Property) MarshalJSON() ([]byte, error) {
+	type property Property
+	return json.Marshal(struct {
+		Kind string `json:"kind"`
+		property
+	}{"Property", property(p)})
+}
+
+func (r Routine) MarshalJSON() ([]byte, error) {
+	type routine Routine
+	return json.Marshal(struct {
+		Kind string `json:"kind"`
+		routine
+	}{"Routine", routine(r)})
+}
+
+func (s *FileSection) UnmarshalJSON(data []byte) error {
+	type section FileSection
+	var v struct {
+		section
+		Blocks []json.RawMessage
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*s = FileSection(v.section)
+	for _, data := range v.Blocks {
+		n, err := unmarshalNode(data)
+		if err != nil {
+			return err
+		}
+		b, ok := n.(FileSectionBlock)
+		if !ok {
+			return errors.New("pas: " + nodeKind(n) + " is not a file section block")
+		}
+		s.Blocks = append(s.Blocks, b)
+	}
+	return nil
+}
+
+func (s *ClassSection) UnmarshalJSON(data []byte) error {
+	type section ClassSection
+	var v struct {
+		section
+		Members []json.RawMessage
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*s = ClassSection(v.section)
+	for _, data := range v.Members {
+		n, err := unmarshalNode(data)
+		if err != nil {
+			return err
+		}
+		m, ok := n.(ClassMember)
+		if !ok {
+			return errors.New("pas: " + nodeKind(n) + " is not a class member")
+		}
+		s.Members = append(s.Members, m)
+	}
+	return nil
+}
+
+// unmarshalNode reads a node that has a "kind" field.
+func unmarshalNode(data []byte) (Node, error) {
+	var v struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	switch v.Kind {
+	case "TypeBlock":
+		var b TypeBlock
+		err := json.Unmarshal(data, &b)
+		return b, err
+	case "VarBlock":
+		var b VarBlock
+		err := json.Unmarshal(data, &b)
+		return b, err
+	case "ConstBlock":
+		var b ConstBlock
+		err := json.Unmarshal(data, &b)
+		return b, err
+	case "Class":
+		var c Class
+		err := json.Unmarshal(data, &c)
+		return c, err
+	case "Variable":
+		var v Variable
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "Function":
+		var f Function
+		err := json.Unmarshal(data, &f)
+		return f, err
+	case "Property":
+		var p Property
+		err := json.Unmarshal(data, &p)
+		return p, err
+	case "Routine":
+		var r Routine
+		err := json.Unmarshal(data, &r)
+		return r, err
+	}
+	return nil, errors.New(`pas: unknown node kind "` + v.Kind + `"`)
+}
+
+func nodeKind(n Node) string {
+	switch n.(type) {
+	case TypeBlock:
+		return "TypeBlock"
+	case VarBlock:
+		return "VarBlock"
+	case ConstBlock:
+		return "ConstBlock"
+	case Class:
+		return "Class"
+	case Variable:
+		return "Variable"
+	case Function:
+		return "Function"
+	case Property:
+		return "Property"
+	case Routine:
+		return "Routine"
+	}
+	return "unknown node"
+}
+
+// The enum types are written to JSON as their keywords, e.g. "unit" or
+// "private", instead of as numbers.
+
+func (k FileKind) MarshalText() ([]byte, error) {
+	return marshalEnum(k.String(), "unknown FileKind")
+}
+
+func (k *FileKind) UnmarshalText(text []byte) error {
+	for _, v := range []FileKind{Program, Unit, Library, Package} {
+		if v.String() == string(text) {
+			*k = v
+			return nil
+		}
+	}
+	return unknownValue(text)
+}
+
+func (k FileSectionKind) MarshalText() ([]byte, error) {
+	return marshalEnum(k.String(), "unknown FileSectionKind")
+}
+
+func (k *FileSectionKind) UnmarshalText(text []byte) error {
+	for _, v := range []FileSectionKind{
+		InterfaceSection,
+		ImplementationSection,
+		InitializationSection,
+		FinalizationSection,
+	} {
+		if v.String() == string(text) {
+			*k = v
+			return nil
+		}
+	}
+	return unknownValue(text)
+}
+
+func (v Visibility) MarshalText() ([]byte, error) {
+	return marshalEnum(v.String(), "unknown Visibility")
+}
+
+func (v *Visibility) UnmarshalText(text []byte) error {
+	for _, vis := range []Visibility{
+		DefaultPublished,
+		Published,
+		Public,
+		Protected,
+		Private,
+	} {
+		if vis.String() == string(text) {
+			*v = vis
+			return nil
+		}
+	}
+	return unknownValue(text)
+}
+
+func (q Qualifier) MarshalText() ([]byte, error) {
+	return marshalEnum(q.String(), "unknown Qualifier")
+}
+
+func (q *Qualifier) UnmarshalText(text []byte) error {
+	for _, v := range []Qualifier{NoQualifier, Var, Const, ConstRef, RefConst, Out} {
+		if v.String() == string(text) {
+			*q = v
+			return nil
+		}
+	}
+	return unknownValue(text)
+}
+
+func (k FunctionKind) MarshalText() ([]byte, error) {
+	return marshalEnum(k.String(), "unknown FunctionKind")
+}
+
+func (k *FunctionKind) UnmarshalText(text []byte) error {
+	for _, v := range []FunctionKind{PlainFunction, Constructor, Destructor} {
+		if v.String() == string(text) {
+			*k = v
+			return nil
+		}
+	}
+	return unknownValue(text)
+}
+
+func (k TagKind) MarshalText() ([]byte, error) {
+	return marshalEnum(k.String(), "unknown TagKind")
+}
+
+func (k *TagKind) UnmarshalText(text []byte) error {
+	for _, v := range []TagKind{
+		UnitTag,
+		ClassTag,
+		InterfaceTag,
+		MethodTag,
+		PropertyTag,
+		FieldTag,
+		RoutineTag,
+		VariableTag,
+		ConstantTag,
+		RecordTag,
+	} {
+		if v.String() == string(text) {
+			*k = v
+			return nil
+		}
+	}
+	return unknownValue(text)
+}
+
+func marshalEnum(s, unknown string) ([]byte, error) {
+	if s == unknown {
+		return nil, errors.New("pas: cannot marshal " + unknown)
+	}
+	return []byte(s), nil
+}
+
+func unknownValue(text []byte) error {
+	return errors.New(`pas: unknown value "` + string(text) + `"`)
+}
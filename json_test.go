@@ -0,0 +1,99 @@
+package pas_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/pas"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	f, err := pas.ParseString(`unit U;
+interface
+uses A.B;
+type
+  /// Doc.
+  [Entity('x')]
+  C = class(TObject)
+    F: Integer; // Comment.
+  private
+    procedure P([Ref] const X: Integer; var Y);
+    function G: string;
+    constructor Create; virtual;
+    /// Doc of X.
+    property X[I: Integer]: string read G write P; default;
+  end;
+var V: Integer;
+const
+  /// Doc of Max.
+  Max = 10; // Comment of Max.
+implementation
+/// Doc of Create.
+constructor C.Create;
+begin
+end;
+end.`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(f)
+	check.Eq(t, err, nil)
+	var g pas.File
+	check.Eq(t, json.Unmarshal(data, &g), nil)
+	check.Eq(t, &g, f)
+
+	// Doc comments point into the Comments, like after parsing.
+	class := g.Sections[0].Blocks[0].(pas.TypeBlock)[0].(pas.Class)
+	check.Eq(t, class.Doc == g.Comments[0], true)
+	field := class.Sections[0].Members[0].(pas.Variable)
+	check.Eq(t, field.Comment == g.Comments[1], true)
+	max := g.Sections[0].Blocks[2].(pas.ConstBlock)[0]
+	check.Eq(t, max.Doc == g.Comments[3], true)
+	check.Eq(t, max.Comment == g.Comments[4], true)
+}
+
+func TestJSONHasKindDiscriminators(t *testing.T) {
+	f, err := pas.ParseString(`unit U;
+interface
+type C = class
+  procedure P(out X: Integer);
+end;
+var V: Integer;
+implementation
+end.`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(f)
+	check.Eq(t, err, nil)
+	js := string(data)
+	for _, want := range []string{
+		`"Kind":"unit"`,
+		`"Kind":"interface"`,
+		`"kind":"TypeBlock"`,
+		`"kind":"Class"`,
+		`"kind":"Function"`,
+		`"Qualifier":"out"`,
+		`"kind":"VarBlock"`,
+		`"kind":"Variable"`,
+		`"Visibility":""`,
+	} {
+		if !strings.Contains(js, want) {
+			t.Errorf("%s not found in JSON:\n%s", want, js)
+		}
+	}
+}
+
+func TestUnmarshalUnknownKindFails(t *testing.T) {
+	var s pas.FileSection
+	err := json.Unmarshal([]byte(`{"Blocks":[{"kind":"LabelBlock"}]}`), &s)
+	check.Eq(t, err.Error(), `pas: unknown node kind "LabelBlock"`)
+
+	err = json.Unmarshal([]byte(`{"Blocks":[{"kind":"Variable"}]}`), &s)
+	check.Eq(t, err.Error(), `pas: Variable is not a file section block`)
+
+	err = json.Unmarshal([]byte(`{"Kind":"header"}`), &s)
+	check.Eq(t, err.Error(), `pas: unknown value "header"`)
+}
@@ -0,0 +1,223 @@
+package pas
+
+import (
+	"strings"
+	"unicode"
+)
+
+// IsReserved reports whether the word is one of Delphi's reserved words, which
+// can never be used as identifiers unless they are escaped with a '&', like
+// &Type. Case does not matter.
+func IsReserved(word string) bool {
+	return reservedWords[FoldIdentifier(word)]
+}
+
+// IsDirective reports whether the word is a directive. Directives like "read",
+// "override" or "name" only have a special meaning in certain places, anywhere
+// else they are normal identifiers. Case does not matter.
+func IsDirective(word string) bool {
+	return directives[FoldIdentifier(word)]
+}
+
+// SameIdentifier reports whether a and b are the same identifier. Delphi
+// identifiers are case-insensitive under Unicode simple case folding, so
+// "Größe" and "GRÖßE" are the same, but "Größe" and "GRÖSSE" are not.
+func SameIdentifier(a, b string) bool {
+	return FoldIdentifier(a) == FoldIdentifier(b)
+}
+
+// FoldIdentifier returns the identifier in a canonical case, which is the same
+// for all identifiers that are the same by SameIdentifier. Use it for the keys
+// of maps of identifiers. ASCII identifiers are folded to lower case, so
+// FoldIdentifier("TFoo") is "tfoo".
+//
+// Unlike strings.ToLower, it maps all characters that fold to each other to
+// the same character, e.g. the Kelvin sign "K" and "K" both become "k", and
+// the long s "ſ" becomes "s".
+func FoldIdentifier(name string) string {
+	ascii := true
+	for i := 0; i < len(name); i++ {
+		if name[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return strings.ToLower(name)
+	}
+	var b strings.Builder
+	b.Grow(len(name))
+	for _, r := range name {
+		// All characters that fold to each other form a cycle under
+		// unicode.SimpleFold. The smallest of them represents the cycle, in
+		// lower case like ASCII identifiers if its lower case is in the cycle.
+		// It is not for U+0130 'İ', which is alone in its cycle, although
+		// unicode.ToLower maps it to 'i'.
+		min := r
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			if f < min {
+				min = f
+			}
+		}
+		lower := unicode.ToLower(min)
+		for f := unicode.SimpleFold(min); f != min && lower != min; f = unicode.SimpleFold(f) {
+			if f == lower {
+				min = lower
+			}
+		}
+		b.WriteRune(min)
+	}
+	return b.String()
+}
+
+// isMethodDirective reports whether the word can follow a method declaration,
+// like "virtual" in "procedure P; virtual;".
+func isMethodDirective(word string) bool {
+	switch FoldIdentifier(word) {
+	case "abstract", "assembler", "cdecl", "deprecated", "dispid", "dynamic",
+		"experimental", "export", "external", "far", "final", "forward",
+		"inline", "local", "message", "near", "overload", "override", "pascal",
+		"platform", "register", "reintroduce", "safecall", "static", "stdcall",
+		"unsafe", "varargs", "virtual", "winapi":
+		return true
+	}
+	return false
+}
+
+// isPropertySpecifier reports whether the word can follow the type of a
+// property, like "read" in "property Name: string read FName;".
+func isPropertySpecifier(word string) bool {
+	switch FoldIdentifier(word) {
+	case "read", "write", "stored", "default", "nodefault", "index",
+		"implements", "readonly", "writeonly", "dispid":
+		return true
+	}
+	return false
+}
+
+var reservedWords = map[string]bool{
+	"and":            true,
+	"array":          true,
+	"as":             true,
+	"asm":            true,
+	"begin":          true,
+	"case":           true,
+	"class":          true,
+	"const":          true,
+	"constructor":    true,
+	"destructor":     true,
+	"dispinterface":  true,
+	"div":            true,
+	"do":             true,
+	"downto":         true,
+	"else":           true,
+	"end":            true,
+	"except":         true,
+	"exports":        true,
+	"file":           true,
+	"finalization":   true,
+	"finally":        true,
+	"for":            true,
+	"function":       true,
+	"goto":           true,
+	"if":             true,
+	"implementation": true,
+	"in":             true,
+	"inherited":      true,
+	"initialization": true,
+	"inline":         true,
+	"interface":      true,
+	"is":             true,
+	"label":          true,
+	"library":        true,
+	"mod":            true,
+	"nil":            true,
+	"not":            true,
+	"object":         true,
+	"of":             true,
+	"or":             true,
+	"packed":         true,
+	"procedure":      true,
+	"program":        true,
+	"property":       true,
+	"raise":          true,
+	"record":         true,
+	"repeat":         true,
+	"resourcestring": true,
+	"set":            true,
+	"shl":            true,
+	"shr":            true,
+	"string":         true,
+	"then":           true,
+	"threadvar":      true,
+	"to":             true,
+	"try":            true,
+	"type":           true,
+	"unit":           true,
+	"until":          true,
+	"uses":           true,
+	"var":            true,
+	"while":          true,
+	"with":           true,
+	"xor":            true,
+}
+
+var directives = map[string]bool{
+	"absolute":     true,
+	"abstract":     true,
+	"assembler":    true,
+	"at":           true,
+	"automated":    true,
+	"cdecl":        true,
+	"contains":     true,
+	"default":      true,
+	"delayed":      true,
+	"deprecated":   true,
+	"dispid":       true,
+	"dynamic":      true,
+	"experimental": true,
+	"export":       true,
+	"external":     true,
+	"far":          true,
+	"final":        true,
+	"forward":      true,
+	"helper":       true,
+	"implements":   true,
+	"index":        true,
+	"local":        true,
+	"message":      true,
+	"name":         true,
+	"near":         true,
+	"nodefault":    true,
+	"on":           true,
+	"operator":     true,
+	"out":          true,
+	"overload":     true,
+	"override":     true,
+	"package":      true,
+	"pascal":       true,
+	"platform":     true,
+	"private":      true,
+	"protected":    true,
+	"public":       true,
+	"published":    true,
+	"read":         true,
+	"readonly":     true,
+	"reference":    true,
+	"register":     true,
+	"reintroduce":  true,
+	"requires":     true,
+	"resident":     true,
+	"safecall":     true,
+	"sealed":       true,
+	"static":       true,
+	"stdcall":      true,
+	"stored":       true,
+	"strict":       true,
+	"unsafe":       true,
+	"varargs":      true,
+	"virtual":      true,
+	"winapi":       true,
+	"write":        true,
+	"writeonly":    true,
+}
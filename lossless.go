@@ -0,0 +1,188 @@
+package pas
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ParseLossless parses the code like ParseString. In addition to the syntax
+// tree, the returned Source keeps every token of the code, including white
+// space and comments, so Source.String returns the code byte for byte. The code
+// must be valid UTF-8.
+//
+// If there are syntax errors, the Source is still returned, together with an
+// ErrorList, and it still contains all of the code.
+func ParseLossless(code string) (*Source, error) {
+	p := newParser([]rune(code))
+	p.keepTokens = true
+	f, err := p.parseFile()
+	p.readRemainingTokens()
+	src := &Source{File: f}
+	for _, t := range p.allTokens {
+		src.tokens = append(src.tokens, sourceToken{token: t, current: t.text})
+	}
+	return src, err
+}
+
+// Source is a parsed file that can be edited and written back without changing
+// the formatting of the code that was not edited.
+//
+// All edit functions take positions in the original code, as found in File.
+// These stay valid after edits, so multiple edits can be made with the same
+// tree. File is not updated by the edits, to get a tree of the edited code,
+// parse Source.String again.
+type Source struct {
+	File   *File
+	tokens []sourceToken
+}
+
+// sourceToken is a token of the original code. Edits change its current text
+// and insert code before it.
+type sourceToken struct {
+	token
+	current string
+	before  string
+}
+
+// String returns the code, including all edits.
+func (s *Source) String() string {
+	var b strings.Builder
+	for _, t := range s.tokens {
+		b.WriteString(t.before)
+		b.WriteString(t.current)
+	}
+	return b.String()
+}
+
+// Replace replaces the text of the token that starts at pos.
+func (s *Source) Replace(pos Position, text string) error {
+	i, err := s.tokenAt(pos)
+	if err != nil {
+		return err
+	}
+	s.tokens[i].current = text
+	return nil
+}
+
+// Rename replaces the identifier that starts at pos, e.g. to rename a field:
+//
+//     src.Rename(field.NamePos, "NewName")
+//
+// The Pos of a declaration is not the start of its name if there are
+// attributes in front of it.
+func (s *Source) Rename(pos Position, name string) error {
+	i, err := s.tokenAt(pos)
+	if err != nil {
+		return err
+	}
+	if s.tokens[i].tokenType != tokenWord {
+		return errors.New("no identifier at " + pos.String())
+	}
+	s.tokens[i].current = name
+	return nil
+}
+
+// InsertBefore inserts text in front of the token that starts at pos.
+func (s *Source) InsertBefore(pos Position, text string) error {
+	i, err := s.tokenAt(pos)
+	if err != nil {
+		return err
+	}
+	s.tokens[i].before += text
+	return nil
+}
+
+// Delete removes the code from pos up to end, e.g. to delete a node:
+//
+//     src.Delete(field.Pos, field.End)
+//
+// Both pos and end must be at the start or end of a token. Text inserted in
+// front of the deleted tokens is deleted as well.
+func (s *Source) Delete(pos, end Position) error {
+	first, err := s.tokenAt(pos)
+	if err != nil {
+		return err
+	}
+	last, err := s.tokenAt(end)
+	if err != nil {
+		return err
+	}
+	for i := first; i < last; i++ {
+		s.tokens[i].before = ""
+		s.tokens[i].current = ""
+	}
+	return nil
+}
+
+// AddUses adds the unit to the end of the uses clause of the given section. If
+// the section has no uses clause yet, a new one is inserted right after the
+// section keyword. If the unit is already used in the file, AddUses does
+// nothing.
+func (s *Source) AddUses(kind FileSectionKind, unit string) error {
+	var section *FileSection
+	for i := range s.File.Sections {
+		for _, u := range s.File.Sections[i].Uses {
+			if SameIdentifier(u, unit) {
+				return nil
+			}
+		}
+		if s.File.Sections[i].Kind == kind {
+			section = &s.File.Sections[i]
+		}
+	}
+	if section == nil {
+		return errors.New("the file has no " + kind.String() + " section")
+	}
+
+	keyword, err := s.tokenAt(section.Pos)
+	if err != nil {
+		return err
+	}
+
+	if len(section.Uses) == 0 {
+		br := s.lineBreak()
+		s.tokens[keyword].current += br + br + "uses" + br + "  " + unit + ";"
+		return nil
+	}
+
+	// Find the ';' that ends the uses clause, it is the first ';' after the
+	// section keyword. The new unit goes right after the last unit name, which
+	// is the last token in front of the ';' that is not a comment or space.
+	for i := keyword + 1; i < len(s.tokens); i++ {
+		if s.tokens[i].tokenType == ';' {
+			last := i - 1
+			for s.tokens[last].tokenType == tokenWhiteSpace ||
+				s.tokens[last].tokenType == tokenComment {
+				last--
+			}
+			s.tokens[last].current += ", " + unit
+			return nil
+		}
+	}
+	return errors.New("uses clause has no end")
+}
+
+// lineBreak returns the line break style of the code, "\r\n" or "\n".
+func (s *Source) lineBreak() string {
+	for _, t := range s.tokens {
+		if i := strings.Index(t.text, "\n"); i != -1 {
+			if i > 0 && t.text[i-1] == '\r' {
+				return "\r\n"
+			}
+			return "\n"
+		}
+	}
+	return "\r\n"
+}
+
+// tokenAt returns the index of the token which starts at pos.
+func (s *Source) tokenAt(pos Position) (int, error) {
+	i := sort.Search(len(s.tokens), func(i int) bool {
+		return s.tokens[i].offset >= pos.Offset
+	})
+	if i == len(s.tokens) || s.tokens[i].offset != pos.Offset {
+		return 0, errors.New("no token starts at " + pos.String())
+	}
+	return i, nil
+}
@@ -0,0 +1,153 @@
+package pas_test
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/pas"
+)
+
+const losslessCode = "  unit   U ;\r\n" +
+	"{ comment }\r\n" +
+	"INTERFACE\r\n" +
+	"\r\n" +
+	"uses  A,\r\n" +
+	"      B ;\r\n" +
+	"\r\n" +
+	"type C=class\r\n" +
+	"\t\tField :Integer; // trailing\r\n" +
+	"\tend;\r\n" +
+	"implementation\r\n" +
+	"end.\r\n" +
+	"text after the end is ignored by Delphi %$ }\r\n"
+
+func TestLosslessParsingKeepsAllTheCode(t *testing.T) {
+	src, err := pas.ParseLossless(losslessCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check.Eq(t, src.String(), losslessCode)
+	check.Eq(t, src.File.Name, "U")
+}
+
+func TestLosslessParsingKeepsCodeWithErrors(t *testing.T) {
+	code := "unit U; interface type C = class A Integer; end; implementation end."
+	src, err := pas.ParseLossless(code)
+	if err == nil {
+		t.Fatal("error expected")
+	}
+	check.Eq(t, src.String(), code)
+}
+
+func TestLosslessParsingKeepsNULCharacters(t *testing.T) {
+	for _, code := range []string{
+		"\x00",
+		"unit U; interface\x00 implementation end.",
+		"unit U; interface implementation end.\x00 after the NUL { \x00 }",
+		"unit U; { \x00 } // \x00\ninterface const S = '\x00'; implementation end.",
+	} {
+		src, _ := pas.ParseLossless(code)
+		check.Eq(t, src.String(), code)
+	}
+}
+
+func TestLosslessEdits(t *testing.T) {
+	src, err := pas.ParseLossless(losslessCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	class := src.File.Sections[0].Blocks[0].(pas.TypeBlock)[0].(pas.Class)
+	field := class.Sections[0].Members[0].(pas.Variable)
+
+	check.Eq(t, src.Rename(field.NamePos, "Renamed"), nil)
+	check.Eq(t, src.AddUses(pas.InterfaceSection, "System.Math"), nil)
+	check.Eq(t, src.AddUses(pas.ImplementationSection, "Windows"), nil)
+	// A unit that is already in use is not added again.
+	check.Eq(t, src.AddUses(pas.ImplementationSection, "a"), nil)
+	check.Eq(t, src.InsertBefore(class.Pos, "{ new comment }"), nil)
+
+	check.Eq(t, src.String(), "  unit   U ;\r\n"+
+		"{ comment }\r\n"+
+		"INTERFACE\r\n"+
+		"\r\n"+
+		"uses  A,\r\n"+
+		"      B, System.Math ;\r\n"+
+		"\r\n"+
+		"type { new comment }C=class\r\n"+
+		"\t\tRenamed :Integer; // trailing\r\n"+
+		"\tend;\r\n"+
+		"implementation\r\n"+
+		"\r\n"+
+		"uses\r\n"+
+		"  Windows;\r\n"+
+		"end.\r\n"+
+		"text after the end is ignored by Delphi %$ }\r\n")
+
+	// Positions stay valid after edits.
+	check.Eq(t, src.Delete(field.Pos, field.End), nil)
+	check.Eq(t, src.String(), "  unit   U ;\r\n"+
+		"{ comment }\r\n"+
+		"INTERFACE\r\n"+
+		"\r\n"+
+		"uses  A,\r\n"+
+		"      B, System.Math ;\r\n"+
+		"\r\n"+
+		"type { new comment }C=class\r\n"+
+		"\t\t // trailing\r\n"+
+		"\tend;\r\n"+
+		"implementation\r\n"+
+		"\r\n"+
+		"uses\r\n"+
+		"  Windows;\r\n"+
+		"end.\r\n"+
+		"text after the end is ignored by Delphi %$ }\r\n")
+}
+
+func TestRenameAttributedDeclarations(t *testing.T) {
+	src, err := pas.ParseLossless(`unit U;
+interface
+type
+  [Entity] C = class
+    [Required] F: Integer;
+    [Hint] procedure P;
+  end;
+implementation
+procedure C.P;
+begin
+end;
+end.`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	class := src.File.Sections[0].Blocks[0].(pas.TypeBlock)[0].(pas.Class)
+	field := class.Sections[0].Members[0].(pas.Variable)
+	method := class.Sections[0].Members[1].(pas.Function)
+	impl := src.File.Sections[1].Blocks[0].(pas.Routine)
+	check.Eq(t, src.Rename(class.NamePos, "D"), nil)
+	check.Eq(t, src.Rename(field.NamePos, "G"), nil)
+	check.Eq(t, src.Rename(method.NamePos, "Q"), nil)
+	check.Eq(t, src.Rename(impl.Header.NamePos, "Q"), nil)
+	check.Eq(t, src.String(), `unit U;
+interface
+type
+  [Entity] D = class
+    [Required] G: Integer;
+    [Hint] procedure Q;
+  end;
+implementation
+procedure C.Q;
+begin
+end;
+end.`)
+}
+
+func TestLosslessEditsNeedTokenPositions(t *testing.T) {
+	src, err := pas.ParseLossless("unit U; interface implementation end.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = src.Rename(pas.Position{Offset: 1, Line: 1, Col: 2}, "X")
+	check.Eq(t, err.Error(), "no token starts at 1:2")
+	err = src.Rename(pas.Position{Offset: 6, Line: 1, Col: 7}, "X")
+	check.Eq(t, err.Error(), "no identifier at 1:7")
+}
@@ -0,0 +1,288 @@
+package pas
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"os"
+	"strings"
+)
+
+// msbuildElement is an element of an MSBuild project file like a .dproj.
+type msbuildElement struct {
+	XMLName   xml.Name
+	Condition string           `xml:"Condition,attr"`
+	Include   string           `xml:"Include,attr"`
+	Text      string           `xml:",chardata"`
+	Children  []msbuildElement `xml:",any"`
+}
+
+// msbuildProject holds the evaluated properties and items of an MSBuild
+// project. Only the parts that Delphi uses in .dproj files are supported:
+// property groups and items with conditions that compare strings.
+type msbuildProject struct {
+	// props are the properties by lower case name.
+	props map[string]string
+	// references are the Include paths of the DCCReference items, these are
+	// the units that are part of the project.
+	references []string
+}
+
+// evaluateMSBuild evaluates the project with the given initial properties, e.g.
+// Config and Platform. Properties that the project does not define are looked
+// up in the environment, like MSBuild does.
+//
+// Delphi's .dproj files have groups whose conditions depend on properties that
+// are only set by later groups, e.g. the "Base" group is enabled by the group
+// of the selected configuration further down. This is why the groups are
+// evaluated repeatedly until no more groups get enabled. A group is enabled if
+// its condition is true for the properties at that point or for the properties
+// at the end of the previous pass. Once enabled, it stays enabled.
+func evaluateMSBuild(data []byte, props map[string]string) (*msbuildProject, error) {
+	var root msbuildElement
+	data = bytes.TrimPrefix(data, bomUTF8)
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	enabled := make(map[int]bool)
+	var prev *msbuildProject
+	for {
+		p, changed, err := evaluateMSBuildOnce(root, props, enabled, prev)
+		if err != nil || !changed {
+			return p, err
+		}
+		prev = p
+	}
+}
+
+func evaluateMSBuildOnce(
+	root msbuildElement,
+	props map[string]string,
+	enabled map[int]bool,
+	prev *msbuildProject,
+) (p *msbuildProject, changed bool, err error) {
+	p = &msbuildProject{props: make(map[string]string)}
+	for name, value := range props {
+		if value != "" {
+			p.props[strings.ToLower(name)] = value
+		}
+	}
+	for i, group := range root.Children {
+		// Other elements, like Import or ProjectExtensions, do not change
+		// anything that we are interested in.
+		if group.XMLName.Local != "PropertyGroup" &&
+			group.XMLName.Local != "ItemGroup" {
+			continue
+		}
+		if !enabled[i] {
+			ok, err := p.condition(group.Condition)
+			if err != nil {
+				return nil, false, err
+			}
+			if !ok && prev != nil {
+				ok, _ = prev.condition(group.Condition)
+			}
+			if !ok {
+				continue
+			}
+			enabled[i] = true
+			changed = true
+		}
+		for _, e := range group.Children {
+			ok, err := p.condition(e.Condition)
+			if err != nil {
+				return nil, false, err
+			}
+			if !ok {
+				continue
+			}
+			if group.XMLName.Local == "PropertyGroup" {
+				name := strings.ToLower(e.XMLName.Local)
+				p.props[name] = p.expand(strings.TrimSpace(e.Text))
+			} else if e.XMLName.Local == "DCCReference" {
+				p.references = append(p.references, p.expand(e.Include))
+			}
+		}
+	}
+	return p, changed, nil
+}
+
+// property returns the value of the property, or the environment variable of
+// that name if the project does not define it.
+func (p *msbuildProject) property(name string) string {
+	if v, ok := p.props[strings.ToLower(name)]; ok {
+		return v
+	}
+	return os.Getenv(name)
+}
+
+// list splits a property value like "A;B;$(Inherited)" at the ';'s, leaving out
+// empty entries.
+func (p *msbuildProject) list(name string) []string {
+	var list []string
+	for _, s := range strings.Split(p.property(name), ";") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// expand replaces all $(Name) in s with the property values.
+func (p *msbuildProject) expand(s string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "$(")
+		if start == -1 {
+			break
+		}
+		end := strings.Index(s[start:], ")")
+		if end == -1 {
+			break
+		}
+		b.WriteString(s[:start])
+		b.WriteString(p.property(s[start+2 : start+end]))
+		s = s[start+end+1:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// condition evaluates an MSBuild condition like
+//
+//     ('$(Platform)'=='Win32' and '$(Base)'=='true') or '$(Base_Win32)'!=''
+//
+// An empty condition is true. String comparisons ignore case.
+func (p *msbuildProject) condition(c string) (bool, error) {
+	if strings.TrimSpace(c) == "" {
+		return true, nil
+	}
+	e := conditionParser{project: p, code: c}
+	e.next()
+	result := e.or()
+	if e.err == nil && e.tok != "" {
+		e.fail()
+	}
+	if e.err != nil {
+		return false, errors.New(`pas: unsupported MSBuild condition "` + c + `"`)
+	}
+	return result, nil
+}
+
+type conditionParser struct {
+	project *msbuildProject
+	code    string
+	// tok is the current token, it is "" at the end of the code. String
+	// tokens start with a '\'' and are already expanded.
+	tok string
+	err error
+}
+
+func (e *conditionParser) next() {
+	e.code = strings.TrimLeft(e.code, " \t\r\n")
+	switch {
+	case e.code == "":
+		e.tok = ""
+	case e.code[0] == '\'':
+		end := strings.IndexByte(e.code[1:], '\'')
+		if end == -1 {
+			e.tok = ""
+			e.fail()
+			return
+		}
+		e.tok = "'" + e.project.expand(e.code[1:end+1])
+		e.code = e.code[end+2:]
+	case strings.HasPrefix(e.code, "==") || strings.HasPrefix(e.code, "!="):
+		e.tok, e.code = e.code[:2], e.code[2:]
+	case e.code[0] == '(' || e.code[0] == ')':
+		e.tok, e.code = e.code[:1], e.code[1:]
+	default:
+		// A word like "and", or an unquoted value like $(Base).
+		end := 0
+		for end < len(e.code) && !strings.ContainsRune(" \t\r\n()=!'", rune(e.code[end])) {
+			if strings.HasPrefix(e.code[end:], "$(") {
+				if n := strings.IndexByte(e.code[end:], ')'); n != -1 {
+					end += n
+				}
+			}
+			end++
+		}
+		if end == 0 {
+			e.tok = ""
+			e.fail()
+			return
+		}
+		e.tok, e.code = e.code[:end], e.code[end:]
+		if !strings.EqualFold(e.tok, "and") && !strings.EqualFold(e.tok, "or") {
+			e.tok = "'" + e.project.expand(e.tok)
+		}
+	}
+}
+
+func (e *conditionParser) or() bool {
+	result := e.and()
+	for e.err == nil && strings.EqualFold(e.tok, "or") {
+		e.next()
+		// Evaluate both sides so all syntax errors are found.
+		right := e.and()
+		result = result || right
+	}
+	return result
+}
+
+func (e *conditionParser) and() bool {
+	result := e.comparison()
+	for e.err == nil && strings.EqualFold(e.tok, "and") {
+		e.next()
+		right := e.comparison()
+		result = result && right
+	}
+	return result
+}
+
+func (e *conditionParser) comparison() bool {
+	if e.err != nil {
+		return false
+	}
+	if e.tok == "(" {
+		e.next()
+		result := e.or()
+		if e.tok != ")" {
+			e.fail()
+			return false
+		}
+		e.next()
+		return result
+	}
+	left, ok := e.value()
+	if !ok {
+		return false
+	}
+	op := e.tok
+	if op != "==" && op != "!=" {
+		e.fail()
+		return false
+	}
+	e.next()
+	right, ok := e.value()
+	if !ok {
+		return false
+	}
+	return strings.EqualFold(left, right) == (op == "==")
+}
+
+func (e *conditionParser) value() (string, bool) {
+	if !strings.HasPrefix(e.tok, "'") {
+		e.fail()
+		return "", false
+	}
+	v := e.tok[1:]
+	e.next()
+	return v, e.err == nil
+}
+
+func (e *conditionParser) fail() {
+	if e.err == nil {
+		e.err = errors.New("syntax error")
+	}
+}
@@ -0,0 +1,44 @@
+package pas
+
+import "testing"
+
+func TestMSBuildConditions(t *testing.T) {
+	p := &msbuildProject{props: map[string]string{
+		"config":   "Debug",
+		"platform": "Win32",
+		"base":     "true",
+	}}
+	tests := []struct {
+		condition string
+		want      bool
+	}{
+		{"", true},
+		{"'$(Config)'=='Debug'", true},
+		{"'$(Config)'=='debug'", true},
+		{"'$(Config)'!='Debug'", false},
+		{"'$(Config)'=='Base' or '$(Base)'!=''", true},
+		{"'$(Config)'=='Base' or '$(Cfg_1)'!=''", false},
+		{"('$(Platform)'=='Win64' and '$(Base)'=='true') or '$(Base_Win64)'!=''", false},
+		{"('$(Platform)'=='Win32' and '$(Base)'=='true') or '$(Base_Win32)'!=''", true},
+		{"$(Base)==true", true},
+	}
+	for _, test := range tests {
+		have, err := p.condition(test.condition)
+		if err != nil {
+			t.Errorf("%s: %v", test.condition, err)
+		} else if have != test.want {
+			t.Errorf("%s: want %v but have %v", test.condition, test.want, have)
+		}
+	}
+
+	for _, c := range []string{
+		"Exists('file')",
+		"'a'=='b' and",
+		"('a'=='a'",
+		"'open",
+	} {
+		if _, err := p.condition(c); err == nil {
+			t.Errorf("%s: error expected", c)
+		}
+	}
+}
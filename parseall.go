@@ -0,0 +1,144 @@
+package pas
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// ParseAllOptions control ParseAll. The zero value is valid.
+type ParseAllOptions struct {
+	// Parse are the options for parsing each file. Their Filename is ignored,
+	// each file's path is used instead.
+	Parse Options
+	// Workers is the maximum number of files that are parsed at the same
+	// time. If it is 0, runtime.GOMAXPROCS(0) is used.
+	Workers int
+	// Cache stores the parsed files, if it is not nil. Files are looked up by
+	// the hash of their path, their content and the Parse options, so changed
+	// files are parsed again. Only files without syntax errors are cached.
+	Cache Cache
+}
+
+// ParseAll parses the files in parallel, see ParseAllOptions.ParseAll.
+func ParseAll(paths []string) ([]*File, error) {
+	return ParseAllOptions{}.ParseAll(paths)
+}
+
+// ParseAll parses the files in parallel. The returned files are in the same
+// order as the paths.
+//
+// The syntax errors of all files are returned in one ErrorList, in the order of
+// the paths, so the result does not depend on which files were parsed first.
+// If a file cannot be read, its File is nil and the error is a *ParseAllError
+// with the read errors and the syntax errors of the other files.
+func (o ParseAllOptions) ParseAll(paths []string) ([]*File, error) {
+	workers := o.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	files := make([]*File, len(paths))
+	errs := make([]error, len(paths))
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				files[i], errs[i] = o.parse(paths[i])
+			}
+		}()
+	}
+	for i := range paths {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	var list ErrorList
+	var readErrs []error
+	for _, err := range errs {
+		if l, ok := err.(ErrorList); ok {
+			list = append(list, l...)
+		} else if err != nil {
+			readErrs = append(readErrs, err)
+		}
+	}
+	if len(readErrs) > 0 {
+		return files, &ParseAllError{ReadErrors: readErrs, SyntaxErrors: list}
+	}
+	return files, list.Err()
+}
+
+// ParseAllError is the error of ParseAll if files cannot be read.
+type ParseAllError struct {
+	// ReadErrors are the errors of the files that cannot be read, in the order
+	// of their paths. There is at least one.
+	ReadErrors []error
+	// SyntaxErrors are the syntax errors of the other files.
+	SyntaxErrors ErrorList
+}
+
+func (e *ParseAllError) Error() string {
+	n := len(e.ReadErrors) + len(e.SyntaxErrors) - 1
+	switch n {
+	case 0:
+		return e.ReadErrors[0].Error()
+	case 1:
+		return e.ReadErrors[0].Error() + " (and 1 more error)"
+	}
+	return fmt.Sprintf("%s (and %d more errors)", e.ReadErrors[0], n)
+}
+
+// Unwrap returns the first read error, so errors.Is(err, fs.ErrNotExist) tells
+// whether a file is missing.
+func (e *ParseAllError) Unwrap() error {
+	return e.ReadErrors[0]
+}
+
+func (o ParseAllOptions) parse(path string) (*File, error) {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	opts := o.Parse
+	opts.Filename = path
+	if o.Cache == nil {
+		return opts.ParseBytes(code)
+	}
+	key := cacheKey(opts, code)
+	if f, ok := o.Cache.Get(key); ok {
+		return f, nil
+	}
+	f, err := opts.ParseBytes(code)
+	if err == nil {
+		o.Cache.Put(key, f)
+	}
+	return f, err
+}
+
+// cacheVersion is part of every cache key. Increment it when the syntax tree
+// changes, so files in a disk cache that were parsed with an older version of
+// this package are not used. TestCacheVersionChangesWithTheSyntaxTree fails
+// when the tree changes without a new version.
+const cacheVersion = 12
+
+func cacheKey(o Options, code []byte) string {
+	h := sha256.New()
+	h.Write([]byte(strconv.Itoa(cacheVersion) + "\x00" + o.Filename + "\x00"))
+	if o.CodePage != nil {
+		h.Write([]byte(string(o.CodePage[:])))
+	}
+	if o.ForceCodePage {
+		h.Write([]byte{1})
+	}
+	h.Write([]byte{0})
+	h.Write(code)
+	return hex.EncodeToString(h.Sum(nil))
+}
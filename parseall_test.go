@@ -0,0 +1,115 @@
+package pas_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/pas"
+)
+
+func writeUnits(t *testing.T, dir string, n int) []string {
+	var paths []string
+	for i := 0; i < n; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("U%d.pas", i))
+		code := fmt.Sprintf("unit U%d; interface implementation end.", i)
+		if i%10 == 3 {
+			code = fmt.Sprintf("unit U%d interface implementation end.", i)
+		}
+		if err := os.WriteFile(path, []byte(code), 0666); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestParseAllKeepsOrderOfFilesAndErrors(t *testing.T) {
+	paths := writeUnits(t, t.TempDir(), 50)
+	files, err := pas.ParseAllOptions{Workers: 4}.ParseAll(paths)
+
+	check.Eq(t, len(files), 50)
+	for i, f := range files {
+		check.Eq(t, f.Name, fmt.Sprintf("U%d", i))
+	}
+
+	list := err.(pas.ErrorList)
+	check.Eq(t, len(list), 5)
+	for i, e := range list {
+		check.Eq(t, e.Pos.Filename, paths[10*i+3])
+	}
+}
+
+func TestParseAllReturnsReadErrors(t *testing.T) {
+	dir := t.TempDir()
+	paths := writeUnits(t, dir, 3)
+	paths = append(paths, filepath.Join(dir, "missing.pas"))
+	files, err := pas.ParseAll(paths)
+	check.Eq(t, errors.Is(err, os.ErrNotExist), true)
+	check.Eq(t, files[0].Name, "U0")
+	check.Eq(t, files[3] == nil, true)
+}
+
+func TestParseAllReturnsReadAndSyntaxErrors(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.pas")
+	if err := os.WriteFile(bad, []byte("unit Bad; interface var; implementation end."), 0666); err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{bad, filepath.Join(dir, "missing1.pas"), filepath.Join(dir, "missing2.pas")}
+	files, err := pas.ParseAll(paths)
+	check.Eq(t, files[0] != nil, true)
+	e, ok := err.(*pas.ParseAllError)
+	if !ok {
+		t.Fatalf("ParseAllError expected but was %T", err)
+	}
+	check.Eq(t, len(e.ReadErrors), 2)
+	check.Eq(t, os.IsNotExist(e.ReadErrors[0]), true)
+	check.Eq(t, os.IsNotExist(e.ReadErrors[1]), true)
+	check.Eq(t, len(e.SyntaxErrors), 1)
+	check.Eq(t, e.SyntaxErrors[0].Pos.Filename, bad)
+	check.Eq(t, strings.HasSuffix(err.Error(), " (and 2 more errors)"), true)
+}
+
+type countingCache struct {
+	pas.Cache
+	hits int
+}
+
+func (c *countingCache) Get(key string) (*pas.File, bool) {
+	f, ok := c.Cache.Get(key)
+	if ok {
+		c.hits++
+	}
+	return f, ok
+}
+
+func TestParseAllUsesCache(t *testing.T) {
+	for _, cache := range []pas.Cache{
+		pas.NewMemoryCache(),
+		pas.NewDiskCache(filepath.Join(t.TempDir(), "cache")),
+	} {
+		paths := writeUnits(t, t.TempDir(), 10)
+		c := &countingCache{Cache: cache}
+		opts := pas.ParseAllOptions{Workers: 1, Cache: c}
+
+		first, _ := opts.ParseAll(paths)
+		check.Eq(t, c.hits, 0)
+
+		// The file with the syntax error is not cached.
+		second, _ := opts.ParseAll(paths)
+		check.Eq(t, c.hits, 9)
+		check.Eq(t, second, first)
+
+		// A changed file is parsed again.
+		err := os.WriteFile(paths[0], []byte("unit Changed; interface implementation end."), 0666)
+		check.Eq(t, err, nil)
+		third, _ := opts.ParseAll(paths)
+		check.Eq(t, c.hits, 17)
+		check.Eq(t, third[0].Name, "Changed")
+	}
+}
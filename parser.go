@@ -1,9 +1,6 @@
 package pas
 
-import (
-	"errors"
-	"strings"
-)
+import "strings"
 
 func newParser(code []rune) *parser {
 	return &parser{tokens: newTokenizer(code)}
@@ -16,38 +13,127 @@ type parser struct {
 	// token here. See parser.nextToken and parser.peekToken.
 	isPeeking bool
 	peekingAt token
+	// lastStart and lastEnd are the positions of the first character of and
+	// right after the last token that was consumed with parser.nextToken. They
+	// are used to find the start and end of the node that we just parsed.
+	lastStart Position
+	lastEnd   Position
+	// leadComment and lineComment are the comment groups that were found in
+	// front of the last scanned token. The leadComment ends on the line before
+	// the token, the lineComment starts on the line of the previous token. See
+	// parser.docComment and parser.trailingComment.
+	leadComment *CommentGroup
+	lineComment *CommentGroup
+	// prevLine is the line on which the last scanned token ends.
+	prevLine int
+	// If keepTokens is set, all tokens that are read from the tokenizer are
+	// appended to allTokens, including white space and comments. This is used
+	// for lossless parsing, see ParseLossless.
+	keepTokens bool
+	allTokens  []token
+	// While capturing is set, the text of all tokens that are read from the
+	// tokenizer is appended to captured. This is used to keep the code of
+	// routine bodies, see parser.routineBody.
+	capturing bool
+	captured  strings.Builder
 	file      File
-	err       error
+	// err is the last syntax error. While it is set, the parser does not read
+	// any more tokens, all the parsing functions return early. Parsing only
+	// continues after parser.recover was called at a point where the parser
+	// can safely synchronize with the code again.
+	err  *ParseError
+	errs ErrorList
 }
 
 func (p *parser) parseFile() (*File, error) {
 	// For now only parse units until we have tests for other kinds.
+	p.file.Pos = p.pos()
 	p.eatWord("unit")
 	p.file.Kind = Unit
 	p.file.Name = p.qualifiedIdentifier("unit name")
 	p.eat(';')
+	p.recover()
 
 	p.eatWord("interface")
 	p.parseFileSection(InterfaceSection)
 
-	p.eatWord("implementation")
+	p.eatSectionStart("implementation")
 	p.parseFileSection(ImplementationSection)
 
+	if p.seesWordAndEat("initialization") {
+		p.parseStatementSection(InitializationSection)
+		if p.seesWordAndEat("finalization") {
+			p.parseStatementSection(FinalizationSection)
+		}
+	} else if p.seesWordAndEat("begin") {
+		p.parseStatementSection(InitializationSection)
+	}
+
 	p.eatWord("end")
 	p.eat('.')
-	return &p.file, p.err
+	p.file.End = p.lastEnd
+	return &p.file, p.errs.Err()
 }
 
+// parseFileSection is called right after the section keyword was eaten.
 func (p *parser) parseFileSection(kind FileSectionKind) {
+	pos := p.lastStart
 	uses := p.parseUses()
-	blocks := p.parseSectionBlocks()
+	blocks := p.parseSectionBlocks(kind)
 	p.file.Sections = append(p.file.Sections, FileSection{
 		Kind:   kind,
 		Uses:   uses,
 		Blocks: blocks,
+		Pos:    pos,
+		End:    p.lastEnd,
 	})
 }
 
+// parseStatementSection is called right after the keyword of an initialization
+// or finalization section was eaten. It skips the statements and keeps their
+// code, up to the "end" of the unit or the "finalization" keyword.
+func (p *parser) parseStatementSection(kind FileSectionKind) {
+	s := FileSection{Kind: kind, Pos: p.lastStart, End: p.lastEnd}
+	codePos := p.pos()
+	p.captured.Reset()
+	p.captured.WriteString(p.peekToken().text)
+	p.capturing = true
+	// The code ends after the last token in front of the keyword that ends
+	// the section, the captured white space in front of that keyword is not
+	// part of it.
+	codeLen := 0
+	depth := 0
+	for p.err == nil {
+		t := p.peekToken()
+		if t.tokenType == tokenEOF {
+			p.tokenError(t, `keyword "end"`)
+			break
+		}
+		if t.tokenType == tokenWord {
+			word := FoldIdentifier(t.text)
+			if depth == 0 && (word == "end" ||
+				word == "finalization" && kind == InitializationSection) {
+				break
+			}
+			switch word {
+			case "begin", "asm", "case", "try", "record":
+				depth++
+			case "end":
+				depth--
+			}
+		}
+		p.nextToken()
+		codeLen = p.captured.Len()
+	}
+	p.capturing = false
+	if codeLen > 0 {
+		s.Code = p.captured.String()[:codeLen]
+		s.CodePos = codePos
+		s.End = p.lastEnd
+	}
+	p.file.Sections = append(p.file.Sections, s)
+}
+
 func (p *parser) parseUses() []string {
 	var uses []string
 	if p.seesWordAndEat("uses") {
@@ -56,17 +142,30 @@ func (p *parser) parseUses() []string {
 			uses = append(uses, p.qualifiedIdentifier("uses clause"))
 		}
 		p.eat(';')
+		p.recover()
 	}
 	return uses
 }
 
-func (p *parser) parseSectionBlocks() []FileSectionBlock {
+// parseSectionBlocks parses the declarations of a section. Routines with bodies
+// are only allowed outside of the interface section, in the interface section
+// routines are only declared.
+func (p *parser) parseSectionBlocks(kind FileSectionKind) []FileSectionBlock {
 	var blocks []FileSectionBlock
 	for {
 		if p.seesWord("type") {
 			blocks = append(blocks, p.parseTypeBlock())
 		} else if p.seesWord("var") {
 			blocks = append(blocks, p.parseVarBlock())
+		} else if p.seesWord("const") {
+			blocks = append(blocks, p.parseConstBlock())
+		} else if p.seesRoutineStart() {
+			if kind == InterfaceSection {
+				blocks = append(blocks, p.parseRoutineHeader(p.parseDeclarationStart()))
+			} else {
+				blocks = append(blocks, p.parseRoutine(p.parseDeclarationStart()))
+			}
+			p.recover()
 		} else {
 			break
 		}
@@ -75,131 +174,775 @@ func (p *parser) parseSectionBlocks() []FileSectionBlock {
 }
 
 func (p *parser) parseTypeBlock() FileSectionBlock {
+	// The class documentation might be written above the "type" keyword or
+	// above the class name.
+	doc := p.docComment()
 	p.eatWord("type")
 	var class Class
+	class.Doc = doc
+	if doc := p.docComment(); doc != nil {
+		class.Doc = doc
+	}
+	class.Pos = p.pos()
+	class.Attributes = p.parseAttributes()
+	if doc := p.docComment(); doc != nil {
+		class.Doc = doc
+	}
+	class.NamePos = p.pos()
 	class.Name = p.identifier("type name")
 	p.eat('=')
-	p.eatWord("class")
-	if p.seesAndEat('(') {
-		class.SuperClasses = append(
-			class.SuperClasses,
-			p.qualifiedIdentifier("parent class name"),
-		)
-		for p.seesAndEat(',') {
+	parent := "parent class name"
+	if p.seesWordAndEat("interface") {
+		class.IsInterface = true
+		parent = "parent interface name"
+	} else if p.seesWordAndEat("packed") {
+		class.IsRecord = true
+		class.IsPacked = true
+		p.eatWord("record")
+	} else if p.seesWordAndEat("record") {
+		class.IsRecord = true
+	} else {
+		p.eatWord("class")
+	}
+	if !class.IsRecord && p.seesAndEat('(') {
+		for {
+			class.SuperClassPos = append(class.SuperClassPos, p.pos())
 			class.SuperClasses = append(
 				class.SuperClasses,
-				p.qualifiedIdentifier("parent interface name"),
+				p.qualifiedIdentifier(parent),
 			)
+			class.SuperClassEnd = append(class.SuperClassEnd, p.lastEnd)
+			if !p.seesAndEat(',') {
+				break
+			}
+			parent = "parent interface name"
 		}
 		p.eat(')')
 	}
-	for !(p.seesWord("end") || p.err != nil) {
+	if class.IsInterface && p.seesAndEat('[') {
+		if t := p.peekToken(); t.tokenType == tokenString {
+			p.nextToken()
+			class.GUID = strings.Trim(t.text, "'")
+		} else {
+			p.tokenError(t, "interface GUID")
+		}
+		p.eat(']')
+	}
+	p.recover()
+	for !(p.seesWord("end") || p.seesBlockEnd()) {
+		pos := p.pos()
 		if p.seesWordAndEat("published") {
-			class.newSection(Published)
+			class.newSection(Published, pos, p.lastEnd)
 		} else if p.seesWordAndEat("public") {
-			class.newSection(Public)
+			class.newSection(Public, pos, p.lastEnd)
 		} else if p.seesWordAndEat("protected") {
-			class.newSection(Protected)
+			class.newSection(Protected, pos, p.lastEnd)
 		} else if p.seesWordAndEat("private") {
-			class.newSection(Private)
-		} else if p.seesWordAndEat("procedure") || p.seesWordAndEat("function") {
-			class.appendMemberToCurrentSection(p.parseFunctionDeclaration())
+			class.newSection(Private, pos, p.lastEnd)
 		} else {
-			class.appendMemberToCurrentSection(p.parseVariableDeclaration())
+			start := p.parseDeclarationStart()
+			var member ClassMember
+			if p.seesWord("property") {
+				member = p.parsePropertyDeclaration(start)
+			} else if p.seesRoutineStart() {
+				member = p.parseFunctionDeclaration(start)
+			} else {
+				member = p.parseVariableDeclaration(start)
+			}
+			class.appendMemberToCurrentSection(member, pos, p.lastEnd)
 		}
+		p.recover()
 	}
 	p.eatWord("end")
 	p.eat(';')
+	class.End = p.lastEnd
+	class.Comment = p.trailingComment()
+	p.recover()
 	return TypeBlock{class}
 }
 
 func (p *parser) parseVarBlock() FileSectionBlock {
 	p.eatWord("var")
 	var vars VarBlock
-	for p.sees(tokenWord) && !p.seesKeyword() {
-		vars = append(vars, p.parseVariableDeclaration())
+	// Everything up to the next reserved word belongs to the block, so that
+	// broken declarations like "1: Integer;" are reported where they are and
+	// do not end the section.
+	for p.sees(tokenWord) && !p.seesReservedWord() ||
+		!p.sees(tokenWord) && !p.sees(tokenEOF) {
+		vars = append(vars, p.parseVariableDeclaration(p.parseDeclarationStart()))
+		p.recover()
 	}
 	return vars
 }
 
-func (p *parser) parseFunctionDeclaration() ClassMember {
-	var f Function
+func (p *parser) parseConstBlock() FileSectionBlock {
+	p.eatWord("const")
+	var consts ConstBlock
+	// Like in a var block, everything up to the next reserved word belongs to
+	// the block.
+	for p.sees(tokenWord) && !p.seesReservedWord() ||
+		!p.sees(tokenWord) && !p.sees(tokenEOF) {
+		consts = append(consts, p.parseConstantDeclaration(p.parseDeclarationStart()))
+		p.recover()
+	}
+	return consts
+}
+
+// declarationStart is what comes in front of a function or variable
+// declaration.
+type declarationStart struct {
+	pos        Position
+	doc        *CommentGroup
+	attributes []Attribute
+}
+
+// parseDeclarationStart parses the attributes in front of a declaration. The
+// doc comment of the declaration can be written above or below them.
+func (p *parser) parseDeclarationStart() declarationStart {
+	var start declarationStart
+	start.pos = p.pos()
+	start.doc = p.docComment()
+	start.attributes = p.parseAttributes()
+	if doc := p.docComment(); doc != nil {
+		start.doc = doc
+	}
+	return start
+}
+
+func (p *parser) parseFunctionDeclaration(start declarationStart) Function {
+	f := p.parseFunctionStart(start)
+	f.NamePos = p.pos()
 	f.Name = p.identifier("function name")
+	p.parseSignature(&f)
+	return f
+}
+
+// parseRoutine parses a routine with its body, like
+//
+//     procedure TFoo.Bar(X: Integer);
+//     var
+//       Y: Integer;
+//     begin
+//     end;
+func (p *parser) parseRoutine(start declarationStart) Routine {
+	r := p.parseRoutineHeader(start)
+	if !hasDirective(r.Header, "forward") && !hasDirective(r.Header, "external") {
+		r.BodyPos = p.pos()
+		r.Body = p.routineBody()
+	}
+	r.End = p.lastEnd
+	return r
+}
+
+// parseLocals parses the local declarations at the start of the routine's Body,
+// up to its block. These are var and const blocks and nested routines. Local
+// variables can be declared in lists like "I, J: Integer", the VarBlocks have
+// one Variable per name. Other local declarations, e.g. types and labels, end
+// the declarations that are returned.
+//
+// block is the position of the "begin" or "asm" of the block. If not all local
+// declarations could be parsed, its Line is 0.
+func parseLocals(r Routine) (blocks []FileSectionBlock, block Position) {
+	p := newParser([]rune(r.Body))
+	p.tokens.filename = r.BodyPos.Filename
+	p.tokens.offset = r.BodyPos.Offset
+	p.tokens.line = r.BodyPos.Line
+	p.tokens.col = r.BodyPos.Col
+	for p.err == nil {
+		if p.seesWord("var") {
+			blocks = append(blocks, p.parseLocalVarBlock())
+		} else if p.seesWord("const") {
+			blocks = append(blocks, p.parseConstBlock())
+		} else if p.seesRoutineStart() {
+			blocks = append(blocks, p.parseRoutine(p.parseDeclarationStart()))
+		} else {
+			break
+		}
+	}
+	if len(p.errs) == 0 && (p.seesWord("begin") || p.seesWord("asm")) {
+		block = p.pos()
+	}
+	return blocks, block
+}
+
+func (p *parser) parseLocalVarBlock() FileSectionBlock {
+	p.eatWord("var")
+	var vars VarBlock
+	for p.sees(tokenWord) && !p.seesReservedWord() ||
+		!p.sees(tokenWord) && !p.sees(tokenEOF) {
+		vars = append(vars, p.parseVariableList(p.parseDeclarationStart())...)
+		p.recover()
+	}
+	return vars
+}
+
+// parseVariableList parses a declaration of one or more variables of the same
+// type, like "I, J: Integer;".
+func (p *parser) parseVariableList(start declarationStart) []Variable {
+	var vars []Variable
+	for {
+		v := Variable{Pos: start.pos, Doc: start.doc, Attributes: start.attributes}
+		v.NamePos = p.pos()
+		v.Name = p.identifier("variable name")
+		vars = append(vars, v)
+		if !p.seesAndEat(',') {
+			break
+		}
+	}
+	p.eat(':')
+	typePos := p.pos()
+	typ := p.typeName("type name")
+	typeEnd := p.lastEnd
+	p.eat(';')
+	end := p.lastEnd
+	comment := p.trailingComment()
+	for i := range vars {
+		vars[i].Type = typ
+		vars[i].TypePos, vars[i].TypeEnd = typePos, typeEnd
+		vars[i].End = end
+		vars[i].Comment = comment
+	}
+	return vars
+}
+
+// parseRoutineHeader parses a routine up to its body, this is all there is of
+// the routines that are declared in the interface section.
+func (p *parser) parseRoutineHeader(start declarationStart) Routine {
+	var r Routine
+	r.Pos = start.pos
+	f := p.parseFunctionStart(start)
+	name := p.qualifiedIdentifier("function name")
+	f.NamePos = p.lastStart
+	if i := strings.LastIndexByte(name, '.'); i != -1 {
+		r.ClassName, name = name[:i], name[i+1:]
+	}
+	f.Name = name
+	p.parseSignature(&f)
+	r.Header = f
+	r.End = p.lastEnd
+	return r
+}
+
+// parseFunctionStart parses the keywords up to the function name, e.g.
+// "class procedure" or "constructor".
+func (p *parser) parseFunctionStart(start declarationStart) Function {
+	var f Function
+	f.Pos = start.pos
+	f.Doc = start.doc
+	f.Attributes = start.attributes
+	if p.seesWordAndEat("class") {
+		f.IsClassMethod = true
+	}
+	if p.seesWordAndEat("constructor") {
+		f.FunctionKind = Constructor
+	} else if p.seesWordAndEat("destructor") {
+		f.FunctionKind = Destructor
+	} else if !p.seesWordAndEat("procedure") {
+		p.eatWord("function")
+	}
+	return f
+}
+
+// parseSignature parses the parameters, return type and directives that follow
+// the function name.
+func (p *parser) parseSignature(f *Function) {
 	if p.seesAndEat('(') {
-		for p.sees(tokenWord) || p.sees('[') {
-			var param Parameter
-
-			if p.seesWordAndEat("var") {
-				param.Qualifier = Var
-			} else if p.seesWordAndEat("const") {
-				param.Qualifier = Const
-				if p.seesAndEat('[') {
-					p.eatWord("ref")
-					p.eat(']')
-					param.Qualifier = ConstRef
-				}
-			} else if p.seesWordAndEat("out") {
-				param.Qualifier = Out
-			} else if p.seesAndEat('[') {
+		f.Parameters = p.parseParameters()
+		p.eat(')')
+	}
+	if p.seesAndEat(':') {
+		f.ReturnsPos = p.pos()
+		f.Returns = p.typeName("return type")
+		f.ReturnsEnd = p.lastEnd
+	}
+	p.eat(';')
+	// Fields must come before methods in Delphi, so a directive is never the
+	// name of a field that follows.
+	for p.sees(tokenWord) && isMethodDirective(p.peekToken().text) {
+		d := p.nextToken().text
+		switch FoldIdentifier(d) {
+		case "message", "dispid":
+			d += " " + p.expression(d+" argument")
+		case "deprecated":
+			if t := p.peekToken(); t.tokenType == tokenString {
+				p.nextToken()
+				d += " " + t.text
+			}
+		case "external":
+			// E.g. external 'user32.dll' name 'MessageBoxW'.
+			if !p.sees(';') {
+				d += " " + p.expression("external library")
+			}
+		}
+		f.Directives = append(f.Directives, d)
+		p.eat(';')
+	}
+	f.End = p.lastEnd
+	f.Comment = p.trailingComment()
+}
+
+// parseParameters parses the parameters of a function or the indices of an array
+// property, without the enclosing parentheses or brackets.
+func (p *parser) parseParameters() []Parameter {
+	var params []Parameter
+	for p.sees(tokenWord) || p.sees('[') {
+		var param Parameter
+		param.Pos = p.pos()
+		param.Attributes = p.parseAttributes()
+
+		if n := len(param.Attributes); n > 0 &&
+			isRef(param.Attributes[n-1]) && p.seesWordAndEat("const") {
+			param.Attributes = param.Attributes[:n-1]
+			if n == 1 {
+				param.Attributes = nil
+			}
+			param.Qualifier = RefConst
+		} else if p.seesWordAndEat("var") {
+			param.Qualifier = Var
+		} else if p.seesWordAndEat("const") {
+			param.Qualifier = Const
+			if p.seesAndEat('[') {
 				p.eatWord("ref")
 				p.eat(']')
-				p.eatWord("const")
-				param.Qualifier = RefConst
+				param.Qualifier = ConstRef
 			}
+		} else if p.seesWordAndEat("out") {
+			param.Qualifier = Out
+		}
 
+		param.Names = append(param.Names, p.identifier("parameter name"))
+		for p.seesAndEat(',') {
 			param.Names = append(param.Names, p.identifier("parameter name"))
-			for p.seesAndEat(',') {
-				param.Names = append(param.Names, p.identifier("parameter name"))
-			}
-			if p.seesAndEat(':') {
-				param.Type = p.qualifiedIdentifier("parameter type")
-			}
-			f.Parameters = append(f.Parameters, param)
-			if !p.seesAndEat(';') {
-				break // The last parameter is not followed by a ';'.
-			}
 		}
-		p.eat(')')
+		if p.seesAndEat(':') {
+			param.TypePos = p.pos()
+			param.Type = p.typeName("parameter type")
+			param.TypeEnd = p.lastEnd
+		}
+		if p.seesAndEat('=') {
+			param.Default = p.expression("default value")
+		}
+		param.End = p.lastEnd
+		params = append(params, param)
+		if !p.seesAndEat(';') {
+			break // The last parameter is not followed by a ';'.
+		}
+	}
+	return params
+}
+
+// parsePropertyDeclaration parses a property like
+//
+//     property Name: string read FName write SetName;
+func (p *parser) parsePropertyDeclaration(start declarationStart) Property {
+	var prop Property
+	prop.Pos = start.pos
+	prop.Doc = start.doc
+	prop.Attributes = start.attributes
+	p.eatWord("property")
+	prop.NamePos = p.pos()
+	prop.Name = p.identifier("property name")
+	if p.seesAndEat('[') {
+		prop.Parameters = p.parseParameters()
+		p.eat(']')
 	}
 	if p.seesAndEat(':') {
-		f.Returns = p.qualifiedIdentifier("return type")
+		prop.TypePos = p.pos()
+		prop.Type = p.typeName("property type")
+		prop.TypeEnd = p.lastEnd
+	}
+	for p.sees(tokenWord) && isPropertySpecifier(p.peekToken().text) {
+		s := p.nextToken().text
+		switch FoldIdentifier(s) {
+		case "read":
+			prop.ReadPos = p.pos()
+			prop.Read = p.qualifiedIdentifier("read accessor")
+			prop.ReadEnd = p.lastEnd
+		case "write":
+			prop.WritePos = p.pos()
+			prop.Write = p.qualifiedIdentifier("write accessor")
+			prop.WriteEnd = p.lastEnd
+		case "nodefault", "readonly", "writeonly":
+			prop.Specifiers = append(prop.Specifiers, s)
+		default:
+			arg := p.propertyArgument(s + " argument")
+			prop.Specifiers = append(prop.Specifiers, s+" "+arg)
+		}
 	}
 	p.eat(';')
-	return f
+	// Like method directives, "default" cannot be the name of a field that
+	// follows since fields must come before properties.
+	if p.seesWordAndEat("default") {
+		prop.IsDefault = true
+		p.eat(';')
+	}
+	prop.End = p.lastEnd
+	prop.Comment = p.trailingComment()
+	return prop
+}
+
+// propertyArgument returns the code of the argument of a property specifier
+// like "default" or "stored", which ends at the next ';' or specifier.
+func (p *parser) propertyArgument(description string) string {
+	if p.err != nil {
+		return ""
+	}
+	var code strings.Builder
+	depth := 0
+	for {
+		t := p.peekToken()
+		if t.tokenType == tokenEOF || t.tokenType == ';' ||
+			depth == 0 && t.tokenType == tokenWord && isPropertySpecifier(t.text) {
+			break
+		}
+		if t.tokenType == '(' || t.tokenType == '[' {
+			depth++
+		} else if t.tokenType == ')' || t.tokenType == ']' {
+			depth--
+		}
+		if code.Len() > 0 && t.offset > p.lastEnd.Offset {
+			code.WriteByte(' ')
+		}
+		p.nextToken()
+		code.WriteString(t.text)
+	}
+	if code.Len() == 0 {
+		p.tokenError(p.peekToken(), description)
+	}
+	return code.String()
+}
+
+// seesRoutineStart reports whether the next word starts a procedure, function,
+// constructor or destructor, or a class method.
+func (p *parser) seesRoutineStart() bool {
+	return p.seesWord("procedure") || p.seesWord("function") ||
+		p.seesWord("constructor") || p.seesWord("destructor") ||
+		p.seesWord("class")
 }
 
-func (p *parser) parseVariableDeclaration() Variable {
+// routineBody skips the local declarations and the block of a routine and
+// returns their code, up to and including the final ';'. Nested routines are
+// part of the body.
+func (p *parser) routineBody() string {
+	if p.err != nil {
+		return ""
+	}
+	if p.capturing {
+		// This is a nested routine, its code is part of the outer body.
+		p.skipRoutineBody()
+		return ""
+	}
+	p.captured.Reset()
+	p.captured.WriteString(p.peekToken().text)
+	p.capturing = true
+	p.skipRoutineBody()
+	p.capturing = false
+	return p.captured.String()
+}
+
+func (p *parser) skipRoutineBody() {
+	afterSemicolon := true
+	for p.err == nil {
+		t := p.peekToken()
+		if t.tokenType == tokenEOF {
+			p.tokenError(t, `keyword "begin"`)
+			return
+		}
+		if p.seesWord("begin") || p.seesWord("asm") {
+			p.skipBlock()
+			p.eat(';')
+			return
+		}
+		if afterSemicolon && p.seesRoutineStart() {
+			p.parseRoutine(p.parseDeclarationStart())
+			continue
+		}
+		p.nextToken()
+		afterSemicolon = t.tokenType == ';'
+	}
+}
+
+// skipBlock skips a begin or asm block up to its matching end. Statements that
+// end with "end" are nested blocks.
+func (p *parser) skipBlock() {
+	depth := 0
+	for p.err == nil {
+		t := p.peekToken()
+		if t.tokenType == tokenEOF {
+			p.tokenError(t, `keyword "end"`)
+			return
+		}
+		p.nextToken()
+		if t.tokenType != tokenWord {
+			continue
+		}
+		switch FoldIdentifier(t.text) {
+		case "begin", "asm", "case", "try", "record":
+			depth++
+		case "end":
+			depth--
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+func (p *parser) parseVariableDeclaration(start declarationStart) Variable {
 	var v Variable
+	v.Pos = start.pos
+	v.Doc = start.doc
+	v.Attributes = start.attributes
+	v.NamePos = p.pos()
 	v.Name = p.identifier("field name")
 	p.eat(':')
-	v.Type = p.qualifiedIdentifier("type name")
+	v.TypePos = p.pos()
+	v.Type = p.typeName("type name")
+	v.TypeEnd = p.lastEnd
 	p.eat(';')
+	v.End = p.lastEnd
+	v.Comment = p.trailingComment()
 	return v
 }
 
-func (p *parser) nextToken() token {
-	if p.isPeeking {
-		// Remove the queued token from our peek queue.
-		p.isPeeking = false
-		return p.peekingAt
+// parseConstantDeclaration parses a true or a typed constant like
+//
+//     Max = 100;
+//     Origin: TPoint = (X: 0; Y: 0);
+func (p *parser) parseConstantDeclaration(start declarationStart) Constant {
+	var c Constant
+	c.Pos = start.pos
+	c.Doc = start.doc
+	c.Attributes = start.attributes
+	c.NamePos = p.pos()
+	c.Name = p.identifier("constant name")
+	if p.seesAndEat(':') {
+		c.TypePos = p.pos()
+		c.Type = p.typeName("constant type")
+		c.TypeEnd = p.lastEnd
 	}
+	p.eat('=')
+	c.Value = p.constantValue()
+	p.eat(';')
+	c.End = p.lastEnd
+	c.Comment = p.trailingComment()
+	return c
+}
 
-	// Find the next token which is not a white-space.
-	t := p.tokens.next()
-	for t.tokenType == tokenWhiteSpace || t.tokenType == tokenComment {
-		t = p.tokens.next()
+// parseAttributes parses any number of attribute lists like
+//
+//     [Required, MaxLength(50)] [JsonName('name')]
+func (p *parser) parseAttributes() []Attribute {
+	var attributes []Attribute
+	for p.seesAndEat('[') {
+		attributes = append(attributes, p.parseAttribute())
+		for p.seesAndEat(',') {
+			attributes = append(attributes, p.parseAttribute())
+		}
+		p.eat(']')
 	}
+	return attributes
+}
+
+func (p *parser) parseAttribute() Attribute {
+	var a Attribute
+	a.Pos = p.pos()
+	a.Name = p.qualifiedIdentifier("attribute name")
+	a.NameEnd = p.lastEnd
+	if p.seesAndEat('(') {
+		if !p.sees(')') {
+			a.Arguments = append(a.Arguments, p.expression("attribute argument"))
+			for p.seesAndEat(',') {
+				a.Arguments = append(a.Arguments, p.expression("attribute argument"))
+			}
+		}
+		p.eat(')')
+	}
+	a.End = p.lastEnd
+	return a
+}
+
+// isRef reports whether a is the [Ref] of a "[Ref] const" parameter.
+func isRef(a Attribute) bool {
+	return FoldIdentifier(a.Name) == "ref" && len(a.Arguments) == 0
+}
+
+// expression returns the code of the expression that ends at the next ',', ')'
+// or ']' that is not nested in parentheses or brackets. We do not parse
+// expressions yet, we only keep their text.
+func (p *parser) expression(description string) string {
+	if p.err != nil {
+		return ""
+	}
+	var code strings.Builder
+	depth := 0
+	for {
+		t := p.peekToken()
+		if t.tokenType == tokenEOF || t.tokenType == ';' {
+			break
+		}
+		if depth == 0 && (t.tokenType == ',' ||
+			t.tokenType == ')' || t.tokenType == ']') {
+			break
+		}
+		if t.tokenType == '(' || t.tokenType == '[' {
+			depth++
+		} else if t.tokenType == ')' || t.tokenType == ']' {
+			depth--
+		}
+		// Separate tokens that have white space or comments between them.
+		if code.Len() > 0 && t.offset > p.lastEnd.Offset {
+			code.WriteByte(' ')
+		}
+		p.nextToken()
+		code.WriteString(t.text)
+	}
+	if code.Len() == 0 {
+		p.tokenError(p.peekToken(), description)
+	}
+	return code.String()
+}
+
+// constantValue returns the code of a constant's value, which ends at the next
+// ';' that is not nested in parentheses, like the ones in "(X: 0; Y: 0)". A
+// keyword that starts a declaration ends the value as well, so a missing ';' or
+// ')' is reported where it is missing.
+func (p *parser) constantValue() string {
+	if p.err != nil {
+		return ""
+	}
+	var code strings.Builder
+	depth := 0
+	for {
+		t := p.peekToken()
+		if t.tokenType == tokenEOF || depth == 0 && t.tokenType == ';' ||
+			t.tokenType == tokenWord && isRecoveryPoint(FoldIdentifier(t.text)) {
+			break
+		}
+		if t.tokenType == '(' || t.tokenType == '[' {
+			depth++
+		} else if t.tokenType == ')' || t.tokenType == ']' {
+			depth--
+		}
+		if code.Len() > 0 && t.offset > p.lastEnd.Offset {
+			code.WriteByte(' ')
+		}
+		p.nextToken()
+		code.WriteString(t.text)
+	}
+	if code.Len() == 0 {
+		p.tokenError(p.peekToken(), "constant value")
+	}
+	return code.String()
+}
+
+func (p *parser) nextToken() token {
+	t := p.peekToken()
+	// Remove the queued token from our peek queue.
+	p.isPeeking = false
+	p.lastStart = t.pos()
+	p.lastEnd = t.end()
 	return t
 }
 
 func (p *parser) peekToken() token {
 	if !p.isPeeking {
-		p.peekingAt = p.nextToken()
+		p.peekingAt = p.scanToken()
 		p.isPeeking = true
 	}
 	return p.peekingAt
 }
 
+// scanToken reads the next token from the tokenizer, skipping white space and
+// comments. The comments are collected in p.file.Comments and the groups right
+// before the token are remembered in p.leadComment and p.lineComment.
+func (p *parser) scanToken() token {
+	p.leadComment = nil
+	p.lineComment = nil
+	var group *CommentGroup
+	groupEndLine := 0
+
+	t := p.nextRawToken()
+	for t.tokenType == tokenWhiteSpace || t.tokenType == tokenComment {
+		if t.tokenType == tokenComment {
+			c := newComment(t)
+			// A comment on the same line as the previous token only groups with
+			// other comments on that line, so a doc comment right below it is
+			// not mistaken for part of the line comment.
+			sameLineAsPrev := group != nil && group == p.lineComment
+			if group == nil ||
+				c.Pos.Line > groupEndLine+1 ||
+				sameLineAsPrev && c.Pos.Line > groupEndLine {
+				group = &CommentGroup{}
+				p.file.Comments = append(p.file.Comments, group)
+				if p.prevLine > 0 && c.Pos.Line == p.prevLine &&
+					p.lineComment == nil && p.leadComment == nil {
+					p.lineComment = group
+				}
+				p.leadComment = group
+			}
+			group.List = append(group.List, c)
+			groupEndLine = c.End.Line
+		}
+		t = p.nextRawToken()
+	}
+
+	if p.leadComment == p.lineComment || groupEndLine < t.line-1 {
+		p.leadComment = nil
+	}
+	p.prevLine = t.end().Line
+	return t
+}
+
+func (p *parser) nextRawToken() token {
+	t := p.tokens.next()
+	if p.capturing {
+		p.captured.WriteString(t.text)
+	}
+	if p.keepTokens && !p.sawEOF() {
+		p.allTokens = append(p.allTokens, t)
+	}
+	return t
+}
+
+func (p *parser) sawEOF() bool {
+	n := len(p.allTokens)
+	return n > 0 && p.allTokens[n-1].tokenType == tokenEOF
+}
+
+// readRemainingTokens reads all tokens up to the end of the file. The parser
+// stops at the end of the unit or when it cannot recover from an error, this
+// makes sure that allTokens covers all of the code.
+func (p *parser) readRemainingTokens() {
+	for !p.sawEOF() {
+		p.nextRawToken()
+	}
+}
+
+func newComment(t token) Comment {
+	// Line comments include the line break, we remove it from the Comment.
+	t.text = strings.TrimRight(t.text, "\r\n")
+	return Comment{Text: t.text, Pos: t.pos(), End: t.end()}
+}
+
+// docComment returns the comment group that ends right before the next token.
+func (p *parser) docComment() *CommentGroup {
+	p.peekToken()
+	return p.leadComment
+}
+
+// trailingComment returns the comment group which is on the same line as the
+// last token that was eaten.
+func (p *parser) trailingComment() *CommentGroup {
+	p.peekToken()
+	return p.lineComment
+}
+
+// pos returns the position of the next token.
+func (p *parser) pos() Position {
+	return p.peekToken().pos()
+}
+
 func (p *parser) sees(typ tokenType) bool {
 	if p.err != nil {
 		return false
@@ -225,7 +968,7 @@ func (p *parser) seesWord(text string) bool {
 		return false
 	}
 	t := p.peekToken()
-	return t.tokenType == tokenWord && strings.ToLower(t.text) == text
+	return t.tokenType == tokenWord && FoldIdentifier(t.text) == text
 }
 
 func (p *parser) seesWordAndEat(text string) bool {
@@ -233,44 +976,121 @@ func (p *parser) seesWordAndEat(text string) bool {
 		return false
 	}
 	t := p.peekToken()
-	if t.tokenType == tokenWord && strings.ToLower(t.text) == text {
+	if t.tokenType == tokenWord && FoldIdentifier(t.text) == text {
 		p.nextToken()
 		return true
 	}
 	return false
 }
 
-func (p *parser) seesKeyword() bool {
+func (p *parser) seesReservedWord() bool {
 	if p.err != nil {
 		return false
 	}
 	t := p.peekToken()
-	return t.tokenType == tokenWord && isKeyword(strings.ToLower(t.text))
+	return t.tokenType == tokenWord && IsReserved(t.text)
 }
 
-func isKeyword(s string) bool {
-	// TODO Complete the list of keywords, these end blocks (var, type, ...).
-	return s == "implementation" || s == "var"
+// seesBlockEnd reports whether the next token ends the current list of
+// declarations, i.e. it is the end of the file or a keyword that starts a new
+// section or block.
+func (p *parser) seesBlockEnd() bool {
+	if p.err != nil {
+		return true
+	}
+	t := p.peekToken()
+	return t.tokenType == tokenEOF ||
+		t.tokenType == tokenWord && isBlockStart(FoldIdentifier(t.text))
 }
 
+func isBlockStart(s string) bool {
+	switch s {
+	case "interface", "implementation", "initialization", "finalization",
+		"type", "var", "const":
+		return true
+	}
+	return false
+}
+
+// recover synchronizes the parser with the code after a syntax error. It skips
+// tokens up to and including the next ';', or up to but excluding the next
+// keyword that starts a declaration, a section or ends a block. After that,
+// parsing continues normally. If there was no error, recover does nothing.
+func (p *parser) recover() {
+	if p.err == nil {
+		return
+	}
+	p.err = nil
+	for {
+		t := p.peekToken()
+		if t.tokenType == tokenEOF {
+			return
+		}
+		if t.tokenType == tokenWord && isRecoveryPoint(FoldIdentifier(t.text)) {
+			return
+		}
+		p.nextToken()
+		if t.tokenType == ';' {
+			return
+		}
+	}
+}
+
+// eatSectionStart eats the keyword that starts the next section of the file.
+// If it is not there, e.g. because the previous section contains declarations
+// that cannot be parsed, the error is reported and the code up to the keyword
+// is skipped, so the next section is still parsed and its errors reported.
+func (p *parser) eatSectionStart(keyword string) {
+	p.eatWord(keyword)
+	if p.err == nil {
+		return
+	}
+	err := p.err
+	p.err = nil
+	for !p.seesWordAndEat(keyword) {
+		if p.sees(tokenEOF) {
+			p.err = err
+			return
+		}
+		p.nextToken()
+	}
+}
+
+func isRecoveryPoint(s string) bool {
+	switch s {
+	case "end", "procedure", "function", "constructor", "destructor",
+		"property", "published", "public", "protected", "private":
+		return true
+	}
+	return isBlockStart(s)
+}
+
+// eat, eatWord and identifier only consume the next token if it is what they
+// expect. This way, parser.recover can stop at the token that caused an error
+// if it is a keyword.
+
 func (p *parser) eat(typ tokenType) {
 	if p.err != nil {
 		return
 	}
-	t := p.nextToken()
+	t := p.peekToken()
 	if t.tokenType != typ {
 		p.tokenError(t, typ.String())
+		return
 	}
+	p.nextToken()
 }
 
 func (p *parser) eatWord(text string) {
 	if p.err != nil {
 		return
 	}
-	t := p.nextToken()
-	if !(t.tokenType == tokenWord && strings.ToLower(t.text) == text) {
+	t := p.peekToken()
+	if !(t.tokenType == tokenWord && FoldIdentifier(t.text) == text) {
 		p.tokenError(t, `keyword "`+text+`"`)
+		return
 	}
+	p.nextToken()
 }
 
 // qualifiedIdentifier parses identifiers with dots in them, e.g.
@@ -286,18 +1106,49 @@ func (p *parser) qualifiedIdentifier(description string) string {
 	return s
 }
 
+// typeName parses a qualified identifier or "string", which is a reserved word
+// but also a type.
+func (p *parser) typeName(description string) string {
+	if p.err != nil {
+		return ""
+	}
+	if t := p.peekToken(); p.seesWordAndEat("string") {
+		return t.text
+	}
+	return p.qualifiedIdentifier(description)
+}
+
+// identifier parses a word that is not a reserved word. Reserved words can be
+// used as identifiers if they are escaped with a '&', e.g. &Type. The returned
+// identifier does not contain the '&'.
 func (p *parser) identifier(description string) string {
 	if p.err != nil {
 		return ""
 	}
-	t := p.nextToken()
+	t := p.peekToken()
+	if t.tokenType == tokenWord && IsReserved(t.text) {
+		p.tokenError(t, description)
+		p.err.Found = `reserved word "` + t.text + `"`
+		return ""
+	}
 	if t.tokenType == tokenWord {
-		return t.text
+		p.nextToken()
+		return strings.TrimPrefix(t.text, "&")
 	}
 	p.tokenError(t, description)
 	return ""
 }
 
 func (p *parser) tokenError(t token, expected string) {
-	p.err = errors.New(expected + " expected but was " + t.String())
+	p.err = &ParseError{
+		Pos:      t.pos(),
+		Expected: expected,
+		Found:    t.description(),
+	}
+	// After recovering, we might run into the same token again, e.g. at the
+	// end of the file. Only report the first error at each position.
+	if n := len(p.errs); n > 0 && p.errs[n-1].Pos == p.err.Pos {
+		return
+	}
+	p.errs = append(p.errs, p.err)
 }
@@ -69,7 +69,26 @@ func TestIncompleteUses(t *testing.T) {
 	)
 	parseError(t,
 		"unit U;interface uses implementation end.",
-		`token ";" expected but was word "end" at 1:38`,
+		`uses clause expected but was reserved word "implementation" at 1:23`,
+	)
+}
+
+func TestIncompleteConstBlock(t *testing.T) {
+	parseError(t,
+		"unit U;interface const C=1 implementation end.",
+		`token ";" expected but was word "implementation" at 1:28`,
+	)
+	parseError(t,
+		"unit U;interface const C=; implementation end.",
+		`constant value expected but was token ";" at 1:26`,
+	)
+	parseError(t,
+		"unit U;interface const C:=1; implementation end.",
+		`constant type expected but was token "=" at 1:26`,
+	)
+	parseError(t,
+		"unit U;interface const C=(1; implementation end.",
+		`token ";" expected but was word "implementation" at 1:30`,
 	)
 }
 
@@ -83,7 +102,7 @@ func TestIncompleteTypeBlock(t *testing.T) {
 	)
 	parseError(t,
 		"unit U;interface type C=class(A,B) ; implementation end.",
-		`field name expected but was token ";" at 1:36`,
+		`field name expected but was token ";" at 1:36 (and 1 more error)`,
 	)
 	parseError(t,
 		"unit U;interface type C=class(A,B end; implementation end.",
@@ -137,25 +156,138 @@ func TestIncompleteVarInClass(t *testing.T) {
 	)
 }
 
+func TestReservedWordsAreNoIdentifiers(t *testing.T) {
+	parseError(t,
+		"unit U;interface type C=class begin: Integer; end; implementation end.",
+		`field name expected but was reserved word "begin" at 1:31`,
+	)
+	parseError(t,
+		"unit U;interface var I: Integer; do: Integer; implementation end.",
+		`keyword "implementation" expected but was word "do" at 1:34`,
+	)
+	parseError(t,
+		"unit U;interface type C=class procedure P(for: Integer); end; implementation end.",
+		`parameter name expected but was reserved word "for" at 1:43`,
+	)
+}
+
+func TestIncompleteAttributes(t *testing.T) {
+	parseError(t,
+		"unit U;interface type C=class [A F: Integer; end; implementation end.",
+		`token "]" expected but was word "F" at 1:34`,
+	)
+	parseError(t,
+		"unit U;interface type C=class [A(] F: Integer; end; implementation end.",
+		`attribute argument expected but was token "]" at 1:34`,
+	)
+	parseError(t,
+		"unit U;interface type C=class [] F: Integer; end; implementation end.",
+		`attribute name expected but was token "]" at 1:32`,
+	)
+}
+
 func TestIncompleteClassFunctions(t *testing.T) {
 	parseError(t,
 		"unit U;interface type C=class procedure( end; implementation end.",
 		`function name expected but was token "(" at 1:40`,
 	)
-	// The following test parses a procedure named "end" and expectes the class
-	// field "implementation" to be followed by a type, e.g. ": Integer".
-	// TODO This is not a good message for this error.
 	parseError(t,
 		"unit U;interface type C=class procedure end; implementation end.",
-		`token ":" expected but was word "end" at 1:61`,
+		`function name expected but was reserved word "end" at 1:41`,
 	)
-	// The same as above happens here.
 	parseError(t,
 		"unit U;interface type C=class function A: end; implementation end.",
-		`token ":" expected but was word "end" at 1:63`,
+		`return type expected but was reserved word "end" at 1:43`,
 	)
 }
 
+func TestParserRecoversFromErrors(t *testing.T) {
+	f, err := pas.ParseString(`unit U;
+interface
+type C = class
+  A Integer;
+  B: Integer;
+  procedure P(;
+  C: ;
+end;
+var
+  D: Integer;
+  : Integer;
+implementation
+end.`)
+	list, ok := err.(pas.ErrorList)
+	if !ok {
+		t.Fatalf("ErrorList expected but have %T", err)
+	}
+	check.Eq(t, len(list), 4)
+	check.Eq(t, *list[0], pas.ParseError{
+		Pos:      pas.Position{Offset: 37, Line: 4, Col: 5},
+		Expected: `token ":"`,
+		Found:    `word "Integer"`,
+	})
+	check.Eq(t, list[1].Error(),
+		`token ")" expected but was token ";" at 6:15`)
+	check.Eq(t, list[2].Error(),
+		`type name expected but was token ";" at 7:6`)
+	check.Eq(t, list[3].Error(),
+		`field name expected but was token ":" at 11:3`)
+	check.Eq(t, err.Error(),
+		`token ":" expected but was word "Integer" at 4:5 (and 3 more errors)`)
+
+	// The parts that could be parsed are still returned.
+	check.Eq(t, f.Name, "U")
+	check.Eq(t, len(f.Sections), 2)
+	class := f.Sections[0].Blocks[0].(pas.TypeBlock)[0].(pas.Class)
+	members := class.Sections[0].Members
+	check.Eq(t, len(members), 4)
+	check.Eq(t, members[1].(pas.Variable).Name, "B")
+	check.Eq(t, members[1].(pas.Variable).Type, "Integer")
+	check.Eq(t, members[2].(pas.Function).Name, "P")
+	vars := f.Sections[0].Blocks[1].(pas.VarBlock)
+	check.Eq(t, vars[0].Name, "D")
+}
+
+func TestErrorsInAllSectionsAreReported(t *testing.T) {
+	_, err := pas.ParseString(`unit U;
+interface
+var
+  1: Integer;
+  A: Integer;
+implementation
+var
+  B: ;
+end.`)
+	list, ok := err.(pas.ErrorList)
+	if !ok {
+		t.Fatalf("ErrorList expected but have %T", err)
+	}
+	check.Eq(t, len(list), 2)
+	check.Eq(t, list[0].Error(), `field name expected but was number "1" at 4:3`)
+	check.Eq(t, list[1].Error(), `type name expected but was token ";" at 8:6`)
+
+	// Code that is not understood at all is skipped up to the next section.
+	_, err = pas.ParseString(`unit U;
+interface
+label L;
+implementation
+var
+  B: ;
+end.`)
+	list, ok = err.(pas.ErrorList)
+	if !ok {
+		t.Fatalf("ErrorList expected but have %T", err)
+	}
+	check.Eq(t, len(list), 2)
+	check.Eq(t, list[0].Error(), `keyword "implementation" expected but was word "label" at 3:1`)
+	check.Eq(t, list[1].Error(), `type name expected but was token ";" at 6:6`)
+}
+
+func TestOnlyOneErrorIsReportedAtTheEndOfFile(t *testing.T) {
+	_, err := pas.ParseString("unit U; interface type C = class")
+	check.Eq(t, err.Error(),
+		`keyword "end" expected but was end of file at 1:33`)
+}
+
 func parseError(t *testing.T, code, wantMessage string) {
 	t.Helper()
 	code = strings.Replace(code, "\n", "\r\n", -1)
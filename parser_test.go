@@ -1,6 +1,7 @@
 package pas_test
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 
@@ -161,6 +162,191 @@ func TestParseClassFields(t *testing.T) {
 	)
 }
 
+func TestReservedWordsCanBeEscaped(t *testing.T) {
+	parseFile(t, `
+  unit U;
+  interface
+  type C = class
+    &Type: &begin.&End;
+    Name: string;
+    Index: Integer;
+    procedure &Set(&var, Read: Integer);
+  end;
+  var &Unit: Integer;
+  implementation
+  end.`,
+		&pas.File{
+			Kind: pas.Unit,
+			Name: "U",
+			Sections: []pas.FileSection{
+				{
+					Kind: pas.InterfaceSection,
+					Blocks: []pas.FileSectionBlock{
+						pas.TypeBlock{
+							pas.Class{
+								Name: "C",
+								Sections: []pas.ClassSection{
+									{Members: []pas.ClassMember{
+										pas.Variable{Name: "Type", Type: "begin.End"},
+										pas.Variable{Name: "Name", Type: "string"},
+										pas.Variable{Name: "Index", Type: "Integer"},
+										pas.Function{
+											Name: "Set",
+											Parameters: []pas.Parameter{
+												{
+													Names: []string{"var", "Read"},
+													Type:  "Integer",
+												},
+											},
+										},
+									}},
+								},
+							},
+						},
+						pas.VarBlock{
+							pas.Variable{Name: "Unit", Type: "Integer"},
+						},
+					},
+				},
+				{Kind: pas.ImplementationSection},
+			},
+		},
+	)
+}
+
+func TestParseAttributes(t *testing.T) {
+	parseFile(t, `
+  unit U;
+  interface
+  type
+    [Entity]
+    C = class
+      [Required, MaxLength(2 * (Max + 1), 'a,b')]
+      [JsonName('name')]
+      Name: string;
+      [weak] procedure P([FromBody] X: Integer; [A] const [Ref] Y; [B] [Ref] const Z);
+    end;
+  var
+    [ThreadSafe] V: Integer;
+  implementation
+  end.`,
+		&pas.File{
+			Kind: pas.Unit,
+			Name: "U",
+			Sections: []pas.FileSection{
+				{
+					Kind: pas.InterfaceSection,
+					Blocks: []pas.FileSectionBlock{
+						pas.TypeBlock{
+							pas.Class{
+								Name:       "C",
+								Attributes: []pas.Attribute{{Name: "Entity"}},
+								Sections: []pas.ClassSection{
+									{Members: []pas.ClassMember{
+										pas.Variable{
+											Name: "Name",
+											Type: "string",
+											Attributes: []pas.Attribute{
+												{Name: "Required"},
+												{
+													Name:      "MaxLength",
+													Arguments: []string{"2 * (Max + 1)", "'a,b'"},
+												},
+												{
+													Name:      "JsonName",
+													Arguments: []string{"'name'"},
+												},
+											},
+										},
+										pas.Function{
+											Name:       "P",
+											Attributes: []pas.Attribute{{Name: "weak"}},
+											Parameters: []pas.Parameter{
+												{
+													Names:      []string{"X"},
+													Type:       "Integer",
+													Attributes: []pas.Attribute{{Name: "FromBody"}},
+												},
+												{
+													Names:      []string{"Y"},
+													Qualifier:  pas.ConstRef,
+													Attributes: []pas.Attribute{{Name: "A"}},
+												},
+												{
+													Names:      []string{"Z"},
+													Qualifier:  pas.RefConst,
+													Attributes: []pas.Attribute{{Name: "B"}},
+												},
+											},
+										},
+									}},
+								},
+							},
+						},
+						pas.VarBlock{
+							pas.Variable{
+								Name:       "V",
+								Type:       "Integer",
+								Attributes: []pas.Attribute{{Name: "ThreadSafe"}},
+							},
+						},
+					},
+				},
+				{Kind: pas.ImplementationSection},
+			},
+		},
+	)
+}
+
+func TestAttributesArePartOfTheDeclaration(t *testing.T) {
+	f, err := pas.ParseString(`unit U;
+interface
+type C = class
+  /// Doc.
+  [A(1)] F: Integer;
+end;
+implementation
+end.`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	field := f.Sections[0].Blocks[0].(pas.TypeBlock)[0].(pas.Class).
+		Sections[0].Members[0].(pas.Variable)
+	check.Eq(t, field.Doc.Text(), "Doc.")
+	check.Eq(t, field.Pos, pas.Position{Offset: 46, Line: 5, Col: 3})
+	check.Eq(t, field.Attributes[0].Pos, pas.Position{Offset: 47, Line: 5, Col: 4})
+	check.Eq(t, field.Attributes[0].End, pas.Position{Offset: 51, Line: 5, Col: 8})
+}
+
+func TestReservedWordsAndDirectives(t *testing.T) {
+	check.Eq(t, pas.IsReserved("begin"), true)
+	check.Eq(t, pas.IsReserved("BEGIN"), true)
+	check.Eq(t, pas.IsReserved("read"), false)
+	check.Eq(t, pas.IsReserved("Name"), false)
+	check.Eq(t, pas.IsDirective("read"), true)
+	check.Eq(t, pas.IsDirective("Override"), true)
+	check.Eq(t, pas.IsDirective("begin"), false)
+}
+
+func TestSameIdentifier(t *testing.T) {
+	check.Eq(t, pas.SameIdentifier("Fläche", "FLÄCHE"), true)
+	check.Eq(t, pas.SameIdentifier("Größe", "gröSSe"), false)
+	check.Eq(t, pas.SameIdentifier("Größe", "GRÖßE"), true)
+	check.Eq(t, pas.SameIdentifier("ǅ", "ǆ"), true)
+	check.Eq(t, pas.SameIdentifier("A", "B"), false)
+	check.Eq(t, pas.SameIdentifier("\u212Aelvin", "kelvin"), true)
+	check.Eq(t, pas.SameIdentifier("Maſs", "MASS"), true)
+	check.Eq(t, pas.FoldIdentifier("\u212Aelvin"), pas.FoldIdentifier("KELVIN"))
+	check.Eq(t, pas.FoldIdentifier("Maſs"), pas.FoldIdentifier("mass"))
+	// The dotted capital I and the dotless small i only fold to themselves.
+	check.Eq(t, pas.SameIdentifier("\u0130", "I"), false)
+	check.Eq(t, pas.SameIdentifier("\u0130", "i"), false)
+	check.Eq(t, pas.SameIdentifier("\u0131", "I"), false)
+	check.Eq(t, pas.SameIdentifier("\u0131", "i"), false)
+	check.Eq(t, pas.FoldIdentifier("\u0130"), "\u0130")
+	check.Eq(t, pas.FoldIdentifier("\u0131"), "\u0131")
+}
+
 func TestParseClassFunctions(t *testing.T) {
 	parseFile(t, `
   unit U;
@@ -322,6 +508,347 @@ func TestParseClassFunctions(t *testing.T) {
 	)
 }
 
+func TestParseMethodDirectives(t *testing.T) {
+	parseFile(t, `
+  unit U;
+  interface
+  type C = class
+    procedure A; virtual; abstract;
+    procedure B; Override;
+    procedure M(var Msg: TMessage); message WM_PAINT;
+    function F: Integer; overload; inline; deprecated 'use G';
+    procedure P; stdcall;
+  end;
+  implementation
+  end.`,
+		&pas.File{
+			Kind: pas.Unit,
+			Name: "U",
+			Sections: []pas.FileSection{
+				{
+					Kind: pas.InterfaceSection,
+					Blocks: []pas.FileSectionBlock{
+						pas.TypeBlock{
+							pas.Class{
+								Name: "C", Sections: []pas.ClassSection{
+									{Members: []pas.ClassMember{
+										pas.Function{Name: "A", Directives: []string{"virtual", "abstract"}},
+										pas.Function{Name: "B", Directives: []string{"Override"}},
+										pas.Function{Name: "M",
+											Parameters: []pas.Parameter{{Names: []string{"Msg"}, Type: "TMessage", Qualifier: pas.Var}},
+											Directives: []string{"message WM_PAINT"}},
+										pas.Function{Name: "F", Returns: "Integer",
+											Directives: []string{"overload", "inline", "deprecated 'use G'"}},
+										pas.Function{Name: "P", Directives: []string{"stdcall"}},
+									}},
+								},
+							},
+						},
+					},
+				},
+				{Kind: pas.ImplementationSection},
+			},
+		},
+	)
+}
+
+func TestParseInterfaceTypes(t *testing.T) {
+	parseFile(t, `
+  unit U;
+  interface
+  type I = interface
+  end;
+  type J = interface(I) ['{0A5D1C3E-6E2B-4E8B-9F2C-1B2D3E4F5A6B}']
+    procedure P;
+    function F: Integer; stdcall;
+  end;
+  implementation
+  end.`,
+		&pas.File{
+			Kind: pas.Unit,
+			Name: "U",
+			Sections: []pas.FileSection{
+				{
+					Kind: pas.InterfaceSection,
+					Blocks: []pas.FileSectionBlock{
+						pas.TypeBlock{pas.Class{Name: "I", IsInterface: true}},
+						pas.TypeBlock{
+							pas.Class{
+								Name:         "J",
+								IsInterface:  true,
+								GUID:         "{0A5D1C3E-6E2B-4E8B-9F2C-1B2D3E4F5A6B}",
+								SuperClasses: []string{"I"},
+								Sections: []pas.ClassSection{
+									{Members: []pas.ClassMember{
+										pas.Function{Name: "P"},
+										pas.Function{Name: "F", Returns: "Integer", Directives: []string{"stdcall"}},
+									}},
+								},
+							},
+						},
+					},
+				},
+				{Kind: pas.ImplementationSection},
+			},
+		},
+	)
+}
+
+func TestParseRecordTypes(t *testing.T) {
+	parseFile(t, `
+  unit U;
+  interface
+  type
+    [Table('points')]
+    TPoint = record
+      [Column('x')] X: Integer;
+    private
+      FY: Integer;
+    public
+      function Length: Double;
+      property Y: Integer read FY;
+    end;
+  type TPacked = packed record
+    B: Byte;
+  end;
+  implementation
+  end.`,
+		&pas.File{
+			Kind: pas.Unit,
+			Name: "U",
+			Sections: []pas.FileSection{
+				{
+					Kind: pas.InterfaceSection,
+					Blocks: []pas.FileSectionBlock{
+						pas.TypeBlock{
+							pas.Class{
+								Name:       "TPoint",
+								IsRecord:   true,
+								Attributes: []pas.Attribute{{Name: "Table", Arguments: []string{"'points'"}}},
+								Sections: []pas.ClassSection{
+									{Members: []pas.ClassMember{
+										pas.Variable{
+											Name:       "X",
+											Type:       "Integer",
+											Attributes: []pas.Attribute{{Name: "Column", Arguments: []string{"'x'"}}},
+										},
+									}},
+									{Visibility: pas.Private, Members: []pas.ClassMember{
+										pas.Variable{Name: "FY", Type: "Integer"},
+									}},
+									{Visibility: pas.Public, Members: []pas.ClassMember{
+										pas.Function{Name: "Length", Returns: "Double"},
+										pas.Property{Name: "Y", Type: "Integer", Read: "FY"},
+									}},
+								},
+							},
+						},
+						pas.TypeBlock{
+							pas.Class{
+								Name:     "TPacked",
+								IsRecord: true,
+								IsPacked: true,
+								Sections: []pas.ClassSection{
+									{Members: []pas.ClassMember{
+										pas.Variable{Name: "B", Type: "Byte"},
+									}},
+								},
+							},
+						},
+					},
+				},
+				{Kind: pas.ImplementationSection},
+			},
+		},
+	)
+}
+
+func TestParseRoutineDeclarationsInTheInterface(t *testing.T) {
+	parseFile(t, `
+  unit U;
+  interface
+  procedure P(X: Integer);
+  function F: string; overload; stdcall;
+  var V: Integer;
+  implementation
+  procedure P(X: Integer);
+  begin
+  end;
+  end.`,
+		&pas.File{
+			Kind: pas.Unit,
+			Name: "U",
+			Sections: []pas.FileSection{
+				{
+					Kind: pas.InterfaceSection,
+					Blocks: []pas.FileSectionBlock{
+						pas.Routine{Header: pas.Function{
+							Name: "P",
+							Parameters: []pas.Parameter{
+								{Names: []string{"X"}, Type: "Integer"},
+							},
+						}},
+						pas.Routine{Header: pas.Function{
+							Name:       "F",
+							Returns:    "string",
+							Directives: []string{"overload", "stdcall"},
+						}},
+						pas.VarBlock{pas.Variable{Name: "V", Type: "Integer"}},
+					},
+				},
+				{
+					Kind: pas.ImplementationSection,
+					Blocks: []pas.FileSectionBlock{
+						pas.Routine{
+							Header: pas.Function{
+								Name: "P",
+								Parameters: []pas.Parameter{
+									{Names: []string{"X"}, Type: "Integer"},
+								},
+							},
+							Body: "begin\r\n  end;",
+						},
+					},
+				},
+			},
+		},
+	)
+}
+
+func TestParseDefaultParameterValues(t *testing.T) {
+	parseFile(t, `
+  unit U;
+  interface
+  procedure P(X: Integer; Y: Integer = 2 * Max; const S: string = ', ');
+  implementation
+  end.`,
+		&pas.File{
+			Kind: pas.Unit,
+			Name: "U",
+			Sections: []pas.FileSection{
+				{
+					Kind: pas.InterfaceSection,
+					Blocks: []pas.FileSectionBlock{
+						pas.Routine{Header: pas.Function{
+							Name: "P",
+							Parameters: []pas.Parameter{
+								{Names: []string{"X"}, Type: "Integer"},
+								{Names: []string{"Y"}, Type: "Integer", Default: "2 * Max"},
+								{Names: []string{"S"}, Type: "string", Qualifier: pas.Const, Default: "', '"},
+							},
+						}},
+					},
+				},
+				{Kind: pas.ImplementationSection},
+			},
+		},
+	)
+}
+
+func TestParseConstBlock(t *testing.T) {
+	parseFile(t, `
+  unit U;
+  interface
+  const
+    Max = 2 * (Count + 1);
+    [Info] Name = 'a;b';
+    Origin: TPoint = (X: 0; Y: 0);
+    Primes: TSet = [2, 3, 5];
+  implementation
+  const Local = Max;
+  end.`,
+		&pas.File{
+			Kind: pas.Unit,
+			Name: "U",
+			Sections: []pas.FileSection{
+				{
+					Kind: pas.InterfaceSection,
+					Blocks: []pas.FileSectionBlock{
+						pas.ConstBlock{
+							{Name: "Max", Value: "2 * (Count + 1)"},
+							{
+								Name:       "Name",
+								Value:      "'a;b'",
+								Attributes: []pas.Attribute{{Name: "Info"}},
+							},
+							{Name: "Origin", Type: "TPoint", Value: "(X: 0; Y: 0)"},
+							{Name: "Primes", Type: "TSet", Value: "[2, 3, 5]"},
+						},
+					},
+				},
+				{
+					Kind: pas.ImplementationSection,
+					Blocks: []pas.FileSectionBlock{
+						pas.ConstBlock{{Name: "Local", Value: "Max"}},
+					},
+				},
+			},
+		},
+	)
+}
+
+func TestParseProperties(t *testing.T) {
+	parseFile(t, `
+  unit U;
+  interface
+  type C = class
+    property Name: string read FName write SetName;
+    property Items[I: Integer; const S: string]: TItem read GetItem; default;
+    property Left: Integer index 0 read GetCoord stored False default -1;
+    property Caption;
+    [Attr] property X: Integer read FX nodefault;
+  end;
+  implementation
+  end.`,
+		&pas.File{
+			Kind: pas.Unit,
+			Name: "U",
+			Sections: []pas.FileSection{
+				{
+					Kind: pas.InterfaceSection,
+					Blocks: []pas.FileSectionBlock{
+						pas.TypeBlock{
+							pas.Class{
+								Name: "C",
+								Sections: []pas.ClassSection{
+									{Members: []pas.ClassMember{
+										pas.Property{Name: "Name", Type: "string", Read: "FName", Write: "SetName"},
+										pas.Property{
+											Name: "Items",
+											Parameters: []pas.Parameter{
+												{Names: []string{"I"}, Type: "Integer"},
+												{Names: []string{"S"}, Type: "string", Qualifier: pas.Const},
+											},
+											Type:      "TItem",
+											Read:      "GetItem",
+											IsDefault: true,
+										},
+										pas.Property{
+											Name:       "Left",
+											Type:       "Integer",
+											Read:       "GetCoord",
+											Specifiers: []string{"index 0", "stored False", "default -1"},
+										},
+										pas.Property{Name: "Caption"},
+										pas.Property{
+											Name:       "X",
+											Type:       "Integer",
+											Read:       "FX",
+											Specifiers: []string{"nodefault"},
+											Attributes: []pas.Attribute{{Name: "Attr"}},
+										},
+									}},
+								},
+							},
+						},
+					},
+				},
+				{Kind: pas.ImplementationSection},
+			},
+		},
+	)
+}
+
 func TestClassVisibilities(t *testing.T) {
 	parseFile(t, `
   unit U;
@@ -444,9 +971,6 @@ func TestParseTwoVarBlocks(t *testing.T) {
 }
 
 func TestComments(t *testing.T) {
-	// TODO For now we skip comments and throw them away. Figure out a way to
-	// include them in the tree, maybe as a parallel structure with references
-	// to the tree.
 	parseFile(t, `
   unit U;
   interface
@@ -460,9 +984,251 @@ func TestComments(t *testing.T) {
 				{Kind: pas.InterfaceSection},
 				{Kind: pas.ImplementationSection},
 			},
+			Comments: []*pas.CommentGroup{
+				{List: []pas.Comment{{Text: "{$R *.dfm}"}}},
+			},
 		})
 }
 
+func TestDocAndTrailingComments(t *testing.T) {
+	group := func(comments ...string) *pas.CommentGroup {
+		var g pas.CommentGroup
+		for _, c := range comments {
+			g.List = append(g.List, pas.Comment{Text: c})
+		}
+		return &g
+	}
+	unitComment := group("// This is not attached to anything.")
+	classDoc := group("/// <summary>C does things.</summary>")
+	classComment := group("// C is done.")
+	fieldDoc := group("{ A is the first field. }", "(* It is an Integer. *)")
+	fieldComment := group("// Trailing A.")
+	procDoc := group("// P does nothing.")
+	loneComment := group("// This stands alone.")
+	varComment := group("{ V }")
+
+	parseFile(t, `
+  unit U;
+  // This is not attached to anything.
+
+  interface
+  /// <summary>C does things.</summary>
+  type C = class
+    { A is the first field. }
+    (* It is an Integer. *)
+    A: Integer; // Trailing A.
+    // P does nothing.
+    procedure P;
+
+    // This stands alone.
+
+    B: Integer;
+  end; // C is done.
+  var V: Integer; { V }
+  implementation
+  end.`,
+		&pas.File{
+			Kind: pas.Unit,
+			Name: "U",
+			Sections: []pas.FileSection{
+				{
+					Kind: pas.InterfaceSection,
+					Blocks: []pas.FileSectionBlock{
+						pas.TypeBlock{
+							pas.Class{
+								Name: "C",
+								Sections: []pas.ClassSection{
+									{Members: []pas.ClassMember{
+										pas.Variable{
+											Name:    "A",
+											Type:    "Integer",
+											Doc:     fieldDoc,
+											Comment: fieldComment,
+										},
+										pas.Function{
+											Name: "P",
+											Doc:  procDoc,
+										},
+										pas.Variable{Name: "B", Type: "Integer"},
+									}},
+								},
+								Doc:     classDoc,
+								Comment: classComment,
+							},
+						},
+						pas.VarBlock{
+							{Name: "V", Type: "Integer", Comment: varComment},
+						},
+					},
+				},
+				{Kind: pas.ImplementationSection},
+			},
+			Comments: []*pas.CommentGroup{
+				unitComment,
+				classDoc,
+				fieldDoc,
+				fieldComment,
+				procDoc,
+				loneComment,
+				classComment,
+				varComment,
+			},
+		})
+}
+
+func TestCommentGroupText(t *testing.T) {
+	f, err := pas.ParseString(`unit U;
+interface
+type C = class
+  // Line comments
+  //   keep their indentation.
+  A: Integer;
+  {$IFDEF DEBUG}
+  {
+    Block comment
+  }
+  B: Integer;
+  ///<summary>XML</summary>
+  C: Integer;
+end;
+implementation
+end.`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	members := f.Sections[0].Blocks[0].(pas.TypeBlock)[0].(pas.Class).
+		Sections[0].Members
+	check.Eq(t, members[0].(pas.Variable).Doc.Text(),
+		"Line comments\n  keep their indentation.")
+	check.Eq(t, members[1].(pas.Variable).Doc.Text(), "Block comment")
+	check.Eq(t, members[2].(pas.Variable).Doc.Text(), "<summary>XML</summary>")
+	var noComment *pas.CommentGroup
+	check.Eq(t, noComment.Text(), "")
+}
+
+func TestParseInitializationAndFinalizationSections(t *testing.T) {
+	f, err := pas.ParseString(`unit U;
+interface
+implementation
+initialization
+  RegisterClass(TA);
+  if A then begin B; end;
+finalization
+  Free;
+end.`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos := func(offset, line, col int) pas.Position {
+		return pas.Position{Offset: offset, Line: line, Col: col}
+	}
+	check.Eq(t, len(f.Sections), 4)
+
+	init := f.Sections[2]
+	check.Eq(t, init.Kind, pas.InitializationSection)
+	check.Eq(t, init.Code, "RegisterClass(TA);\n  if A then begin B; end;")
+	check.Eq(t, init.Pos, pos(33, 4, 1))
+	check.Eq(t, init.CodePos, pos(50, 5, 3))
+	check.Eq(t, init.End, pos(94, 6, 26))
+
+	fin := f.Sections[3]
+	check.Eq(t, fin.Kind, pas.FinalizationSection)
+	check.Eq(t, fin.Code, "Free;")
+	check.Eq(t, fin.Pos, pos(95, 7, 1))
+	check.Eq(t, fin.CodePos, pos(110, 8, 3))
+	check.Eq(t, fin.End, pos(115, 8, 8))
+}
+
+func TestParseEmptyAndBeginInitializationSections(t *testing.T) {
+	parseFile(t, `
+  unit U;
+  interface
+  implementation
+  initialization
+  finalization
+  end.`,
+		&pas.File{
+			Kind: pas.Unit,
+			Name: "U",
+			Sections: []pas.FileSection{
+				{Kind: pas.InterfaceSection},
+				{Kind: pas.ImplementationSection},
+				{Kind: pas.InitializationSection},
+				{Kind: pas.FinalizationSection},
+			},
+		})
+	parseFile(t, `
+  unit U;
+  interface
+  implementation
+  begin
+    Run;
+  end.`,
+		&pas.File{
+			Kind: pas.Unit,
+			Name: "U",
+			Sections: []pas.FileSection{
+				{Kind: pas.InterfaceSection},
+				{Kind: pas.ImplementationSection},
+				{Kind: pas.InitializationSection, Code: "Run;"},
+			},
+		})
+}
+
+func TestNodePositions(t *testing.T) {
+	// Offsets are in bytes while columns are in characters, the 'Ä' takes up
+	// two bytes in UTF-8.
+	f, err := pas.ParseString(`unit Ä;
+interface
+type C = class
+  F: Integer;
+public
+  procedure P(const X: Y);
+end;
+implementation
+end.`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos := func(offset, line, col int) pas.Position {
+		return pas.Position{Offset: offset, Line: line, Col: col}
+	}
+	check.Eq(t, f.Pos, pos(0, 1, 1))
+	check.Eq(t, f.End, pos(106, 9, 5))
+
+	intf := f.Sections[0]
+	check.Eq(t, intf.Pos, pos(9, 2, 1))
+	check.Eq(t, intf.End, pos(86, 7, 5))
+
+	class := intf.Blocks[0].(pas.TypeBlock)[0].(pas.Class)
+	check.Eq(t, class.Pos, pos(24, 3, 6))
+	check.Eq(t, class.End, pos(86, 7, 5))
+	check.Eq(t, class.NamePos, pos(24, 3, 6))
+
+	fields := class.Sections[0]
+	check.Eq(t, fields.Pos, pos(36, 4, 3))
+	check.Eq(t, fields.End, pos(47, 4, 14))
+	field := fields.Members[0].(pas.Variable)
+	check.Eq(t, field.Pos, pos(36, 4, 3))
+	check.Eq(t, field.End, pos(47, 4, 14))
+	check.Eq(t, field.NamePos, pos(36, 4, 3))
+
+	methods := class.Sections[1]
+	check.Eq(t, methods.Pos, pos(48, 5, 1))
+	check.Eq(t, methods.End, pos(81, 6, 27))
+	method := methods.Members[0].(pas.Function)
+	check.Eq(t, method.Pos, pos(57, 6, 3))
+	check.Eq(t, method.End, pos(81, 6, 27))
+	check.Eq(t, method.NamePos, pos(67, 6, 13))
+	param := method.Parameters[0]
+	check.Eq(t, param.Pos, pos(69, 6, 15))
+	check.Eq(t, param.End, pos(79, 6, 25))
+
+	impl := f.Sections[1]
+	check.Eq(t, impl.Pos, pos(87, 8, 1))
+	check.Eq(t, impl.End, pos(101, 8, 15))
+}
+
 func parseFile(t *testing.T, code string, want *pas.File) {
 	t.Helper()
 	code = strings.Replace(code, "\n", "\r\n", -1)
@@ -470,5 +1236,50 @@ func parseFile(t *testing.T, code string, want *pas.File) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	check.Eq(t, f, want)
+	check.Eq(t, withoutPositions(f), want)
+}
+
+// withoutPositions returns a copy of f with all positions set to zero. Most
+// tests only care about the structure of the tree, positions are tested in
+// TestNodePositions.
+func withoutPositions(f *pas.File) *pas.File {
+	return clearPositions(reflect.ValueOf(f)).Interface().(*pas.File)
+}
+
+func clearPositions(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Elem().Type())
+		c.Elem().Set(clearPositions(v.Elem()))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(clearPositions(v.Elem()))
+		return c
+	case reflect.Slice:
+		if v.IsNil() || v.Type() == reflect.TypeOf([]pas.Position{}) {
+			return reflect.Zero(v.Type())
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(clearPositions(v.Index(i)))
+		}
+		return c
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(pas.Position{}) {
+			return reflect.Zero(v.Type())
+		}
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			c.Field(i).Set(clearPositions(v.Field(i)))
+		}
+		return c
+	}
+	return v
 }
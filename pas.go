@@ -1,13 +1,121 @@
 package pas
 
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ParseString parses the given Delphi code. If the code has syntax errors, the
+// error is an ErrorList and the returned File contains everything that could be
+// parsed.
 func ParseString(code string) (*File, error) {
-	return newParser([]rune(code)).parseFile()
+	return Options{}.ParseString(code)
+}
+
+// ParseFile reads and parses the file at the given path. The path is used as
+// the Filename in all positions. See Options.ParseBytes for how the file's
+// encoding is detected.
+func ParseFile(path string) (*File, error) {
+	return Options{Filename: path}.ParseFile(path)
+}
+
+// ParseReader reads all code from r and parses it. See Options.ParseBytes for
+// how the encoding is detected.
+func ParseReader(r io.Reader) (*File, error) {
+	return Options{}.ParseReader(r)
+}
+
+// ParseBytes parses the code. See Options.ParseBytes for how the encoding is
+// detected.
+func ParseBytes(code []byte) (*File, error) {
+	return Options{}.ParseBytes(code)
+}
+
+// Options control how code is decoded and parsed. The zero value is valid.
+type Options struct {
+	// Filename is stored in all positions and so appears in error messages.
+	// ParseFile uses the path if Filename is empty.
+	Filename string
+	// CodePage decodes code that has no byte order mark and is not valid
+	// UTF-8. If it is nil, Windows1252 is used.
+	CodePage *CodePage
+	// ForceCodePage decodes all code without byte order mark with CodePage,
+	// even if it is valid UTF-8. Use it for legacy ANSI units in which some
+	// byte sequences happen to look like UTF-8.
+	ForceCodePage bool
+}
+
+// ParseString parses the given code, see the package function ParseString.
+func (o Options) ParseString(code string) (*File, error) {
+	p := newParser([]rune(code))
+	p.tokens.filename = o.Filename
+	return p.parseFile()
+}
+
+// ParseFile reads and parses the file at the given path. If o.Filename is
+// empty, the path is used as the Filename.
+func (o Options) ParseFile(path string) (*File, error) {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if o.Filename == "" {
+		o.Filename = path
+	}
+	return o.ParseBytes(code)
+}
+
+// ParseReader reads all code from r and parses it.
+func (o Options) ParseReader(r io.Reader) (*File, error) {
+	code, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return o.ParseBytes(code)
+}
+
+// ParseBytes decodes the code and parses it. Code with a byte order mark is
+// decoded as UTF-8, UTF-16 LE or UTF-16 BE. Code without byte order mark is
+// decoded as UTF-8 if it is valid UTF-8 and with o.CodePage otherwise, see
+// Options.Decode.
+func (o Options) ParseBytes(code []byte) (*File, error) {
+	s, _, err := o.Decode(code)
+	if err != nil {
+		return nil, err
+	}
+	return o.ParseString(s)
 }
 
 type File struct {
 	Kind     FileKind
 	Name     string
 	Sections []FileSection
+	// Comments are all comments in the file, in the order in which they appear
+	// in the code. The Doc and Comment fields of the nodes point into this list.
+	Comments []*CommentGroup
+	Pos, End Position
+}
+
+// Position is a location in the source code. Every node in the tree has a Pos
+// at its first character and an End right after its last character.
+type Position struct {
+	// Filename is empty for code that does not come from a file.
+	Filename string `json:",omitempty"`
+	// Offset is the byte offset into the UTF-8 encoded source, starting at 0.
+	// For files in other encodings, this is the offset into the decoded code.
+	Offset int
+	// Line and Col both start at 1. Col counts characters, not bytes.
+	Line, Col int
+}
+
+// String returns "file:line:col" or "line:col" if there is no Filename.
+func (p Position) String() string {
+	if p.Filename != "" {
+		return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Col)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
 }
 
 type FileKind int
@@ -36,6 +144,16 @@ type FileSection struct {
 	Kind   FileSectionKind
 	Uses   []string
 	Blocks []FileSectionBlock
+	// Code is the code of the statements of an initialization or finalization
+	// section, from the first to the last statement, as it appears in the
+	// code. Statements are not parsed yet. CodePos is the start of the Code.
+	Code    string
+	CodePos Position
+	// Pos is the section keyword, e.g. "interface", End is right after the
+	// last declaration or statement in the section. The initialization
+	// section of a unit can also start with "begin" instead of
+	// "initialization".
+	Pos, End Position
 }
 
 type FileSectionKind int
@@ -61,43 +179,87 @@ func (k FileSectionKind) String() string {
 }
 
 type FileSectionBlock interface {
+	Node
 	isFileSectionBlock()
 }
 
-func (TypeBlock) isFileSectionBlock() {}
-func (VarBlock) isFileSectionBlock()  {}
+func (TypeBlock) isFileSectionBlock()  {}
+func (VarBlock) isFileSectionBlock()   {}
+func (ConstBlock) isFileSectionBlock() {}
+func (Routine) isFileSectionBlock()    {}
 
+// TypeBlock has no position of its own, it spans from its first to its last
+// declaration.
 type TypeBlock []TypeDeclaration
 
+// VarBlock has no position of its own, it spans from its first to its last
+// variable.
 type VarBlock []Variable
 
+// ConstBlock has no position of its own, it spans from its first to its last
+// constant.
+type ConstBlock []Constant
+
 type TypeDeclaration interface {
+	Node
 	isTypeDeclaration()
 }
 
 func (Class) isTypeDeclaration() {}
 
+// Class is a class, an interface or a record type. Interfaces and records have
+// the same structure, only the members of interfaces are all methods and they
+// have no visibility sections, and records have no super classes. The variant
+// parts of records, "case" in a record, are not supported.
 type Class struct {
-	Name         string
+	Name string
+	// IsInterface is true for "interface" types.
+	IsInterface bool
+	// IsRecord is true for "record" types, IsPacked for "packed record".
+	IsRecord bool
+	IsPacked bool
+	// GUID is the interface GUID without quotes, e.g.
+	// "{0A5D1C3E-6E2B-4E8B-9F2C-1B2D3E4F5A6B}", or empty if it has none.
+	GUID         string
 	SuperClasses []string
 	Sections     []ClassSection
+	Attributes   []Attribute
+	Doc          *CommentGroup
+	Comment      *CommentGroup
+	// Pos is the start of the first attribute or the class name, End is right
+	// after the ';' that follows the class' "end".
+	Pos, End Position
+	// NamePos is the start of the Name, Pos is in front of it if there are
+	// attributes.
+	NamePos Position
+	// SuperClassPos and SuperClassEnd are the starts and ends of the
+	// SuperClasses.
+	SuperClassPos, SuperClassEnd []Position
 }
 
-func (c *Class) appendMemberToCurrentSection(member ClassMember) {
+func (c *Class) appendMemberToCurrentSection(member ClassMember, pos, end Position) {
 	if len(c.Sections) == 0 {
-		c.newSection(DefaultPublished)
+		c.newSection(DefaultPublished, pos, pos)
 	}
 	i := len(c.Sections) - 1
 	c.Sections[i].Members = append(c.Sections[i].Members, member)
+	c.Sections[i].End = end
 }
 
-func (c *Class) newSection(v Visibility) {
-	c.Sections = append(c.Sections, ClassSection{Visibility: v})
+func (c *Class) newSection(v Visibility, pos, end Position) {
+	c.Sections = append(c.Sections, ClassSection{
+		Visibility: v,
+		Pos:        pos,
+		End:        end,
+	})
 }
 
 type ClassSection struct {
 	Visibility Visibility
 	Members    []ClassMember
+	// Pos is the visibility keyword or, for the DefaultPublished section, the
+	// first member. End is right after the last member.
+	Pos, End Position
 }
 
 type Visibility int
@@ -112,24 +274,188 @@ const (
 	Private          Visibility = 4
 )
 
+// String returns the keyword for the visibility. DefaultPublished has no
+// keyword so its String is the empty string.
+func (v Visibility) String() string {
+	switch v {
+	case DefaultPublished:
+		return ""
+	case Published:
+		return "published"
+	case Public:
+		return "public"
+	case Protected:
+		return "protected"
+	case Private:
+		return "private"
+	}
+	return "unknown Visibility"
+}
+
 type ClassMember interface {
+	Node
 	isClassMember()
 }
 
 func (Variable) isClassMember() {}
 func (Function) isClassMember() {}
+func (Property) isClassMember() {}
 
 type Variable struct {
+	Name       string
+	Type       string
+	Attributes []Attribute
+	Doc        *CommentGroup
+	Comment    *CommentGroup
+	// Pos is the start of the first attribute or the name.
+	Pos, End Position
+	// NamePos is the start of the Name, Pos is in front of it if there are
+	// attributes.
+	NamePos Position
+	// TypePos and TypeEnd are the span of the Type.
+	TypePos, TypeEnd Position
+}
+
+// Constant is a true constant like
+//
+//     Max = 2 * Count;
+//
+// or a typed constant like
+//
+//     Origin: TPoint = (X: 0; Y: 0);
+type Constant struct {
 	Name string
+	// Type is empty for true constants.
 	Type string
+	// Value is the code of the value without comments and with the white
+	// space between its tokens collapsed to single spaces, e.g. "2 * Count".
+	// Values are not parsed yet.
+	Value      string
+	Attributes []Attribute
+	Doc        *CommentGroup
+	Comment    *CommentGroup
+	// Pos is the start of the first attribute or the name, End is right after
+	// the closing ';'.
+	Pos, End Position
+	// NamePos is the start of the Name.
+	NamePos Position
+	// TypePos and TypeEnd are the span of the Type, they are not set for true
+	// constants.
+	TypePos, TypeEnd Position
 }
 
 type Function struct {
-	Name       string
-	Parameters []Parameter
+	Name string
+	// FunctionKind tells constructors and destructors apart from procedures
+	// and functions.
+	FunctionKind FunctionKind
+	// IsClassMethod is true for "class procedure" and "class function".
+	IsClassMethod bool
+	Parameters    []Parameter
 	// Returns is either the return type for functions or the empty string for
 	// procedures.
 	Returns string
+	// Directives are the words after the declaration, as written in the code,
+	// e.g. "virtual", "abstract" or "message WM_PAINT".
+	Directives []string
+	Attributes []Attribute
+	Doc        *CommentGroup
+	Comment    *CommentGroup
+	// Pos is the start of the first attribute or the "procedure" or "function"
+	// keyword, End is right after the closing ';' of the last directive.
+	Pos, End Position
+	// NamePos is the start of the Name. For method implementations, this is
+	// after the class name, e.g. at "Bar" in "procedure TFoo.Bar;".
+	NamePos Position
+	// ReturnsPos and ReturnsEnd are the span of the Returns type, they are
+	// not set for procedures.
+	ReturnsPos, ReturnsEnd Position
+}
+
+type FunctionKind int
+
+const (
+	// PlainFunction is a procedure or function, depending on the Returns of
+	// the Function.
+	PlainFunction FunctionKind = 0
+	Constructor   FunctionKind = 1
+	Destructor    FunctionKind = 2
+)
+
+// String returns the keyword for constructors and destructors and the empty
+// string for PlainFunction.
+func (k FunctionKind) String() string {
+	switch k {
+	case PlainFunction:
+		return ""
+	case Constructor:
+		return "constructor"
+	case Destructor:
+		return "destructor"
+	}
+	return "unknown FunctionKind"
+}
+
+// Property is a class property, e.g.
+//
+//     property Items[Index: Integer]: string read GetItem write SetItem; default;
+type Property struct {
+	Name string
+	// Parameters are the indices of array properties, they are written in
+	// brackets after the Name.
+	Parameters []Parameter
+	// Type is empty for properties that are redeclared to change their
+	// visibility, e.g. "property Caption;".
+	Type string
+	// Read and Write are the names of the field or method that the property
+	// reads from and writes to. They are empty if they are not specified.
+	Read, Write string
+	// Specifiers are the other specifiers after the type, as written in the
+	// code, e.g. "index 3", "stored False" or "default 0".
+	Specifiers []string
+	// IsDefault is true if the property is the default array property, i.e.
+	// it is followed by "default;".
+	IsDefault  bool
+	Attributes []Attribute
+	Doc        *CommentGroup
+	Comment    *CommentGroup
+	// Pos is the start of the first attribute or the "property" keyword, End
+	// is right after the closing ';'.
+	Pos, End Position
+	// NamePos is the start of the Name.
+	NamePos Position
+	// TypePos and TypeEnd, ReadPos and ReadEnd and WritePos and WriteEnd are
+	// the spans of the Type, Read and Write names. They are not set if the
+	// name is empty.
+	TypePos, TypeEnd   Position
+	ReadPos, ReadEnd   Position
+	WritePos, WriteEnd Position
+}
+
+// Routine is a procedure or function with its body, e.g. the implementation of
+// a method in the implementation section:
+//
+//     procedure TFoo.Bar(X: Integer);
+//     begin
+//       Writeln(X);
+//     end;
+//
+// The routines declared in the interface section are Routines without body.
+type Routine struct {
+	// ClassName is "TFoo" in "procedure TFoo.Bar", it is empty for routines
+	// that are not methods.
+	ClassName string
+	// Header is the routine's declaration. Its Name is "Bar", without the
+	// ClassName.
+	Header Function
+	// Body is the code of the local declarations and the block, up to and
+	// including the final ';', as it appears in the code. Bodies are not
+	// parsed yet. Forward and external routines and the declarations in the
+	// interface section have no Body.
+	Body string
+	// Pos is the start of the Header, BodyPos the start of the Body and End is
+	// right after the Body.
+	Pos, BodyPos, End Position
 }
 
 type Parameter struct {
@@ -139,6 +465,35 @@ type Parameter struct {
 	//     procedure(const A; var B);
 	Type      string
 	Qualifier Qualifier
+	// Default is the code of the default value, e.g. "0" for "X: Integer = 0".
+	// It is empty if the parameter has no default value.
+	Default string
+	// Attributes do not contain the [Ref] of ConstRef and RefConst parameters.
+	Attributes []Attribute
+	Pos, End   Position
+	// TypePos and TypeEnd are the span of the Type, they are not set for
+	// untyped parameters.
+	TypePos, TypeEnd Position
+}
+
+// Attribute is a custom attribute in front of a declaration, e.g. the
+// attributes in
+//
+//     [Required, MaxLength(50)]
+//     [JsonName('name')]
+//     Name: string;
+//
+// are Required, MaxLength with argument 50 and JsonName with argument 'name'.
+type Attribute struct {
+	Name string
+	// Arguments are the expressions between the parentheses as they appear in
+	// the code, e.g. "'name'" or "2 * Max". White space and comments between
+	// tokens are replaced by a single space.
+	Arguments []string
+	// Pos is the start of the Name, NameEnd is right after it and End is right
+	// after the arguments.
+	Pos, End Position
+	NameEnd  Position
 }
 
 type Qualifier int
@@ -171,3 +526,73 @@ func (q Qualifier) String() string {
 	}
 	return "unknown Qualifier"
 }
+
+// Comment is a single {...}, (*...*) or //... comment, including the comment
+// delimiters. For // comments, the line break is not part of the Text.
+// Compiler directives like {$R *.dfm} are comments as well.
+type Comment struct {
+	Text     string
+	Pos, End Position
+}
+
+// CommentGroup is a sequence of comments with no other tokens and no empty
+// lines between them.
+//
+// The Doc of a node is the comment group that ends on the line right before the
+// node. The Comment of a node is the comment group that starts on the line on
+// which the node ends, e.g.
+//
+//     /// Doc of F.
+//     F: Integer; // Comment of F.
+type CommentGroup struct {
+	List []Comment
+}
+
+// Text returns the text of the comments without comment delimiters and without
+// the indentation that all lines have in common. Compiler directives are left
+// out. Empty lines at the start and end and trailing white space are removed.
+func (g *CommentGroup) Text() string {
+	if g == nil {
+		return ""
+	}
+	var lines []string
+	for _, c := range g.List {
+		text := c.Text
+		switch {
+		case strings.HasPrefix(text, "{$") || strings.HasPrefix(text, "(*$"):
+			continue
+		case strings.HasPrefix(text, "//"):
+			text = strings.TrimLeft(text, "/")
+		case strings.HasPrefix(text, "{"):
+			text = strings.TrimSuffix(text[1:], "}")
+		case strings.HasPrefix(text, "(*"):
+			text = strings.TrimSuffix(text[2:], "*)")
+		}
+		for _, line := range strings.Split(text, "\n") {
+			lines = append(lines, strings.TrimRight(line, " \t\r"))
+		}
+	}
+
+	indent := -1
+	for _, line := range lines {
+		if line != "" {
+			n := len(line) - len(strings.TrimLeft(line, " \t"))
+			if indent == -1 || n < indent {
+				indent = n
+			}
+		}
+	}
+	for i := range lines {
+		if lines[i] != "" {
+			lines[i] = lines[i][indent:]
+		}
+	}
+
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
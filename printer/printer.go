@@ -0,0 +1,719 @@
+// Package printer turns a syntax tree back into Delphi source code.
+package printer
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gonutz/pas"
+)
+
+// KeywordCase is the way keywords like "procedure" or "end" are written.
+type KeywordCase int
+
+const (
+	// LowerCase writes keywords like "procedure".
+	LowerCase KeywordCase = 0
+	// UpperCase writes keywords like "PROCEDURE".
+	UpperCase KeywordCase = 1
+	// TitleCase writes keywords like "Procedure".
+	TitleCase KeywordCase = 2
+)
+
+// BeginStyle is the placement of a "begin" that follows "then", "do" or
+// "else".
+type BeginStyle int
+
+const (
+	// KeepBegin leaves "begin" on the line that it is on in the code.
+	KeepBegin BeginStyle = 0
+	// BeginOnNewLine writes "begin" on its own line, indented like the line
+	// with the "then", "do" or "else".
+	BeginOnNewLine BeginStyle = 1
+	// BeginOnSameLine writes "begin" at the end of the line with the "then",
+	// "do" or "else".
+	BeginOnSameLine BeginStyle = 2
+)
+
+// Config controls the style of the printed code.
+type Config struct {
+	// Indent is written once per indentation level, e.g. two spaces or a tab.
+	Indent string
+	// Keywords is the case in which keywords are written.
+	Keywords KeywordCase
+	// SpaceBeforeColon writes "A : Integer" instead of "A: Integer".
+	SpaceBeforeColon bool
+	// SpaceAroundEquals writes "C = class" instead of "C=class".
+	SpaceAroundEquals bool
+	// Begin is the placement of "begin" in routine bodies.
+	Begin BeginStyle
+	// MaxLineLength is the number of characters after which a declaration's
+	// parameter list is wrapped, one parameter per line. 0 means that lines
+	// are never wrapped.
+	MaxLineLength int
+	// LineBreak ends every line, usually "\r\n" or "\n".
+	LineBreak string
+}
+
+// DefaultConfig is the style used by the Delphi IDE's formatter.
+var DefaultConfig = Config{
+	Indent:            "  ",
+	Keywords:          LowerCase,
+	SpaceAroundEquals: true,
+	Begin:             BeginOnNewLine,
+	MaxLineLength:     80,
+	LineBreak:         "\r\n",
+}
+
+// Fprint writes f to w, formatted in the DefaultConfig style.
+func Fprint(w io.Writer, f *pas.File) error {
+	return DefaultConfig.Fprint(w, f)
+}
+
+// Fprint writes f to w, formatted in the style of c.
+//
+// Doc and trailing comments are written with the nodes they belong to. All
+// other comments in f.Comments are written in front of the next node that
+// follows them in the original code.
+func (c Config) Fprint(w io.Writer, f *pas.File) error {
+	p := printer{Config: c}
+	p.collectFreeComments(f)
+	p.file(f)
+	_, err := w.Write(p.buf.Bytes())
+	return err
+}
+
+type printer struct {
+	Config
+	buf    bytes.Buffer
+	indent int
+	// line is the current output line, it is written to buf by newline.
+	line strings.Builder
+	// freeComments are the comments which are not attached to any node, in
+	// the order in which they appear in the code.
+	freeComments []*pas.CommentGroup
+	// srcLine is the line in the original code of the last node that was
+	// printed. Free comments on that line stay on the same output line.
+	srcLine int
+}
+
+func (p *printer) collectFreeComments(f *pas.File) {
+	attached := make(map[*pas.CommentGroup]bool)
+	pas.Inspect(f, func(n pas.Node) bool {
+		if g, ok := n.(*pas.CommentGroup); ok {
+			attached[g] = true
+			return false
+		}
+		return true
+	})
+	for _, g := range f.Comments {
+		if !attached[g] {
+			p.freeComments = append(p.freeComments, g)
+		}
+	}
+}
+
+func (p *printer) file(f *pas.File) {
+	p.flushComments(f.Pos)
+	p.keyword(f.Kind.String())
+	p.print(" ", name(f.Name), ";")
+	p.srcLine = f.Pos.Line
+	p.lineComments()
+	for _, s := range f.Sections {
+		p.emptyLine()
+		p.section(s)
+	}
+	p.emptyLine()
+	p.flushComments(f.End)
+	p.keepEmptyLine(f.End)
+	p.keyword("end")
+	p.print(".")
+	p.srcLine = f.End.Line
+	p.lineComments()
+	// Write the comments that come after the final "end.".
+	p.flushComments(pas.Position{Offset: math.MaxInt})
+	p.endLine()
+}
+
+func (p *printer) section(s pas.FileSection) {
+	p.flushComments(s.Pos)
+	p.keepEmptyLine(s.Pos)
+	p.keyword(s.Kind.String())
+	p.srcLine = s.Pos.Line
+	p.lineComments()
+	if s.Code != "" {
+		p.code(s.Code, s.CodePos, s.End)
+	}
+	if len(s.Uses) > 0 {
+		p.emptyLine()
+		p.keyword("uses")
+		p.indent++
+		p.newline()
+		for i, u := range s.Uses {
+			u = name(u)
+			if i > 0 {
+				p.print(",")
+				if p.MaxLineLength > 0 &&
+					p.lineLength()+1+len(u)+1 > p.MaxLineLength {
+					p.newline()
+				} else {
+					p.print(" ")
+				}
+			}
+			p.print(u)
+		}
+		p.print(";")
+		p.indent--
+	}
+	for _, b := range s.Blocks {
+		p.emptyLine()
+		switch b := b.(type) {
+		case pas.TypeBlock:
+			p.typeBlock(b)
+		case pas.VarBlock:
+			p.varBlock(b)
+		case pas.ConstBlock:
+			p.constBlock(b)
+		case pas.Routine:
+			p.routine(b)
+		}
+	}
+}
+
+func (p *printer) typeBlock(b pas.TypeBlock) {
+	if len(b) > 0 {
+		pos, _ := b[0].Span()
+		p.flushComments(pos)
+	}
+	p.keyword("type")
+	p.indent++
+	for _, d := range b {
+		p.endLine()
+		switch d := d.(type) {
+		case pas.Class:
+			p.class(d)
+		}
+	}
+	p.indent--
+}
+
+func (p *printer) class(c pas.Class) {
+	p.flushComments(c.Pos)
+	p.doc(c.Doc)
+	p.attributes(c.Attributes)
+	p.print(name(c.Name), p.equals())
+	if c.IsInterface {
+		p.keyword("interface")
+	} else if c.IsPacked {
+		p.keyword("packed")
+		p.print(" ")
+		p.keyword("record")
+	} else if c.IsRecord {
+		p.keyword("record")
+	} else {
+		p.keyword("class")
+	}
+	if len(c.SuperClasses) > 0 {
+		p.print("(", names(c.SuperClasses), ")")
+	}
+	if c.GUID != "" {
+		p.print(" ['", c.GUID, "']")
+	}
+	p.srcLine = c.Pos.Line
+	p.lineComments()
+	for _, s := range c.Sections {
+		if s.Visibility != pas.DefaultPublished {
+			p.endLine()
+			p.flushComments(s.Pos)
+			p.keyword(s.Visibility.String())
+			p.srcLine = s.Pos.Line
+			p.lineComments()
+		}
+		p.indent++
+		for _, m := range s.Members {
+			p.endLine()
+			switch m := m.(type) {
+			case pas.Variable:
+				p.variable(m)
+			case pas.Function:
+				p.function(m, "")
+			case pas.Property:
+				p.property(m)
+			}
+		}
+		p.indent--
+	}
+	p.endLine()
+	p.indent++
+	p.flushComments(c.End)
+	p.indent--
+	p.keyword("end")
+	p.print(";")
+	p.trailing(c.Comment)
+	p.srcLine = c.End.Line
+	p.lineComments()
+}
+
+func (p *printer) varBlock(b pas.VarBlock) {
+	if len(b) > 0 {
+		p.flushComments(b[0].Pos)
+	}
+	p.keyword("var")
+	p.indent++
+	for _, v := range b {
+		p.endLine()
+		p.variable(v)
+	}
+	p.indent--
+}
+
+func (p *printer) variable(v pas.Variable) {
+	p.flushComments(v.Pos)
+	p.doc(v.Doc)
+	p.attributes(v.Attributes)
+	p.print(name(v.Name), p.colon(), typeName(v.Type), ";")
+	p.trailing(v.Comment)
+	p.srcLine = v.End.Line
+	p.lineComments()
+}
+
+func (p *printer) constBlock(b pas.ConstBlock) {
+	if len(b) > 0 {
+		p.flushComments(b[0].Pos)
+	}
+	p.keyword("const")
+	p.indent++
+	for _, c := range b {
+		p.endLine()
+		p.constant(c)
+	}
+	p.indent--
+}
+
+func (p *printer) constant(c pas.Constant) {
+	p.flushComments(c.Pos)
+	p.doc(c.Doc)
+	p.attributes(c.Attributes)
+	p.print(name(c.Name))
+	if c.Type != "" {
+		p.print(p.colon(), typeName(c.Type))
+	}
+	p.print(p.equals(), c.Value, ";")
+	p.trailing(c.Comment)
+	p.srcLine = c.End.Line
+	p.lineComments()
+}
+
+// routine writes the header of the routine and then its body as it is in the
+// code, since bodies are not parsed yet. Only the placement of "begin" is
+// changed, see Config.Begin.
+func (p *printer) routine(r pas.Routine) {
+	p.function(r.Header, r.ClassName)
+	if r.Body == "" {
+		return
+	}
+	p.code(r.Body, r.BodyPos, r.End)
+}
+
+// code writes code that is not parsed, like a routine body, as it is, on a new
+// line. Only the placement of "begin" is changed, see Config.Begin. The code
+// starts at pos and its node ends at end.
+func (p *printer) code(code string, pos, end pas.Position) {
+	p.endLine()
+	// Comments in front of the code are not part of it.
+	p.flushComments(pos)
+	// The code starts at its first token, keep that token's indentation.
+	code = strings.Repeat(" ", pos.Col-1) + code
+	code = placeBegin(strings.Replace(code, "\r\n", "\n", -1), p.Begin)
+	for i, line := range strings.Split(code, "\n") {
+		if i > 0 {
+			p.newline()
+		}
+		p.line.WriteString(line)
+	}
+	// The comments in the code were written with it, they are the free
+	// comments left before the end.
+	for len(p.freeComments) > 0 &&
+		p.freeComments[0].List[0].Pos.Offset < end.Offset {
+		p.freeComments = p.freeComments[1:]
+	}
+	p.srcLine = end.Line
+	p.lineComments()
+}
+
+// placeBegin moves every "begin" that follows a "then", "do" or "else" to the
+// line that the style asks for. A "begin" with comments in front of it is left
+// where it is.
+func placeBegin(code string, style BeginStyle) string {
+	if style == KeepBegin {
+		return code
+	}
+	var tokens []pas.Token
+	s := pas.NewScanner(code)
+	for t := s.Scan(); t.Kind != pas.EOFToken; t = s.Scan() {
+		tokens = append(tokens, t)
+	}
+	texts := make([]string, len(tokens))
+	for i, t := range tokens {
+		texts[i] = t.Text
+	}
+	for i := 2; i < len(tokens); i++ {
+		space, before := tokens[i-1], tokens[i-2]
+		if !isWord(tokens[i], "begin") || space.Kind != pas.WhiteSpaceToken ||
+			!(isWord(before, "then") || isWord(before, "do") || isWord(before, "else")) {
+			continue
+		}
+		onNewLine := strings.Contains(space.Text, "\n")
+		if style == BeginOnSameLine && onNewLine {
+			texts[i-1] = " "
+		}
+		if style == BeginOnNewLine && !onNewLine {
+			code := strings.Join(texts[:i-1], "")
+			line := code[strings.LastIndexByte(code, '\n')+1:]
+			texts[i-1] = "\n" + line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		}
+	}
+	return strings.Join(texts, "")
+}
+
+func isWord(t pas.Token, word string) bool {
+	return t.Kind == pas.WordToken && strings.ToLower(t.Text) == word
+}
+
+// function writes the function declaration. For method implementations, the
+// class name is written in front of the function name.
+func (p *printer) function(f pas.Function, className string) {
+	p.flushComments(f.Pos)
+	p.doc(f.Doc)
+	p.attributes(f.Attributes)
+	if f.IsClassMethod {
+		p.keyword("class")
+		p.print(" ")
+	}
+	if f.FunctionKind != pas.PlainFunction {
+		p.keyword(f.FunctionKind.String())
+	} else if f.Returns == "" {
+		p.keyword("procedure")
+	} else {
+		p.keyword("function")
+	}
+	if className != "" {
+		p.print(" ", name(className), ".", name(f.Name))
+	} else {
+		p.print(" ", name(f.Name))
+	}
+
+	var params []string
+	for _, param := range f.Parameters {
+		params = append(params, p.parameter(param))
+	}
+	var returns string
+	if f.Returns != "" {
+		returns = p.colon() + typeName(f.Returns)
+	}
+
+	oneLine := p.lineLength() + len(returns) + len(";")
+	if len(params) > 0 {
+		oneLine += len("()") + len(strings.Join(params, "; "))
+	}
+	if p.MaxLineLength > 0 && oneLine > p.MaxLineLength && len(params) > 0 {
+		p.print("(")
+		p.indent++
+		for i, param := range params {
+			p.newline()
+			p.print(param)
+			if i < len(params)-1 {
+				p.print(";")
+			}
+		}
+		p.indent--
+		p.print(")")
+	} else if len(params) > 0 {
+		p.print("(", strings.Join(params, "; "), ")")
+	}
+	p.print(returns, ";")
+	for _, d := range f.Directives {
+		// Only the directive itself is a keyword, not its argument.
+		word, arg := d, ""
+		if i := strings.IndexByte(d, ' '); i != -1 {
+			word, arg = d[:i], d[i:]
+		}
+		p.print(" ", p.keywordCase(word), arg, ";")
+	}
+	p.trailing(f.Comment)
+	p.srcLine = f.End.Line
+	p.lineComments()
+}
+
+func (p *printer) property(prop pas.Property) {
+	p.flushComments(prop.Pos)
+	p.doc(prop.Doc)
+	p.attributes(prop.Attributes)
+	p.keyword("property")
+	p.print(" ", name(prop.Name))
+	if len(prop.Parameters) > 0 {
+		var params []string
+		for _, param := range prop.Parameters {
+			params = append(params, p.parameter(param))
+		}
+		p.print("[", strings.Join(params, "; "), "]")
+	}
+	if prop.Type != "" {
+		p.print(p.colon(), typeName(prop.Type))
+	}
+	// The index comes before the accessors, all other specifiers after them.
+	specifier := func(s string) {
+		// Only the specifier itself is a keyword, not its argument.
+		word, arg := s, ""
+		if i := strings.IndexByte(s, ' '); i != -1 {
+			word, arg = s[:i], s[i:]
+		}
+		p.print(" ", p.keywordCase(word), arg)
+	}
+	for _, s := range prop.Specifiers {
+		if isIndex(s) {
+			specifier(s)
+		}
+	}
+	if prop.Read != "" {
+		p.print(" ", p.keywordCase("read"), " ", name(prop.Read))
+	}
+	if prop.Write != "" {
+		p.print(" ", p.keywordCase("write"), " ", name(prop.Write))
+	}
+	for _, s := range prop.Specifiers {
+		if !isIndex(s) {
+			specifier(s)
+		}
+	}
+	p.print(";")
+	if prop.IsDefault {
+		p.print(" ", p.keywordCase("default"), ";")
+	}
+	p.trailing(prop.Comment)
+	p.srcLine = prop.End.Line
+	p.lineComments()
+}
+
+func isIndex(specifier string) bool {
+	return strings.HasPrefix(strings.ToLower(specifier), "index ")
+}
+
+func (p *printer) parameter(param pas.Parameter) string {
+	var s string
+	if len(param.Attributes) > 0 {
+		s = attributeList(param.Attributes) + " "
+	}
+	switch param.Qualifier {
+	case pas.Var:
+		s += p.keywordCase("var") + " "
+	case pas.Const:
+		s += p.keywordCase("const") + " "
+	case pas.ConstRef:
+		s += p.keywordCase("const") + " [Ref] "
+	case pas.RefConst:
+		s += "[Ref] " + p.keywordCase("const") + " "
+	case pas.Out:
+		s += p.keywordCase("out") + " "
+	}
+	s += names(param.Names)
+	if param.Type != "" {
+		s += p.colon() + typeName(param.Type)
+	}
+	if param.Default != "" {
+		s += " = " + param.Default
+	}
+	return s
+}
+
+// name escapes the parts of a qualified identifier that are reserved words
+// with a '&', e.g. "&Type".
+func name(s string) string {
+	parts := strings.Split(s, ".")
+	for i := range parts {
+		if pas.IsReserved(parts[i]) {
+			parts[i] = "&" + parts[i]
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func names(s []string) string {
+	escaped := make([]string, len(s))
+	for i := range s {
+		escaped[i] = name(s[i])
+	}
+	return strings.Join(escaped, ", ")
+}
+
+// typeName is like name but leaves "string" alone, it is both a reserved word
+// and a type.
+func typeName(s string) string {
+	if strings.EqualFold(s, "string") {
+		return s
+	}
+	return name(s)
+}
+
+// attributes writes the attributes on their own line in front of a
+// declaration.
+func (p *printer) attributes(a []pas.Attribute) {
+	if len(a) > 0 {
+		p.print(attributeList(a))
+		p.endLine()
+	}
+}
+
+// attributeList returns the attributes in one pair of brackets, e.g.
+//
+//     [Required, MaxLength(50)]
+func attributeList(attributes []pas.Attribute) string {
+	var list []string
+	for _, a := range attributes {
+		s := name(a.Name)
+		if len(a.Arguments) > 0 {
+			s += "(" + strings.Join(a.Arguments, ", ") + ")"
+		}
+		list = append(list, s)
+	}
+	return "[" + strings.Join(list, ", ") + "]"
+}
+
+func (p *printer) doc(g *pas.CommentGroup) {
+	if g == nil {
+		return
+	}
+	p.comments(g)
+	p.endLine()
+}
+
+func (p *printer) trailing(g *pas.CommentGroup) {
+	if g != nil {
+		p.print(" ")
+		p.comments(g)
+	}
+}
+
+// comments writes the comments in g, keeping comments that were on the same
+// line in the code on one line.
+func (p *printer) comments(g *pas.CommentGroup) {
+	for i, c := range g.List {
+		if i > 0 {
+			if c.Pos.Line == g.List[i-1].End.Line {
+				p.print(" ")
+			} else {
+				p.newline()
+			}
+		}
+		p.print(c.Text)
+	}
+}
+
+// flushComments writes all free comments that come before pos in the code, each
+// on its own line. Empty lines in front of the comments are kept.
+func (p *printer) flushComments(pos pas.Position) {
+	for len(p.freeComments) > 0 &&
+		p.freeComments[0].List[0].Pos.Offset < pos.Offset {
+		g := p.freeComments[0]
+		p.freeComments = p.freeComments[1:]
+		p.endLine()
+		if p.srcLine > 0 && g.List[0].Pos.Line > p.srcLine+1 {
+			p.emptyLine()
+		}
+		p.comments(g)
+		p.endLine()
+		_, end := g.Span()
+		p.srcLine = end.Line
+	}
+}
+
+// keepEmptyLine writes an empty line if there is one in the code between the
+// last node or comment that was printed and pos.
+func (p *printer) keepEmptyLine(pos pas.Position) {
+	if p.srcLine > 0 && pos.Line > p.srcLine+1 {
+		p.emptyLine()
+	}
+}
+
+// lineComments writes the free comments that are on the same line as the last
+// node that was printed at the end of the current line.
+func (p *printer) lineComments() {
+	for len(p.freeComments) > 0 &&
+		p.freeComments[0].List[0].Pos.Line == p.srcLine {
+		p.trailing(p.freeComments[0])
+		p.freeComments = p.freeComments[1:]
+	}
+}
+
+func (p *printer) keyword(s string) {
+	p.print(p.keywordCase(s))
+}
+
+// keywordCase writes the keyword s in the configured case. Directives and
+// specifiers are keywords as well, they come from the code as written, e.g.
+// "VIRTUAL", so s can be in any case.
+func (p *printer) keywordCase(s string) string {
+	s = strings.ToLower(s)
+	switch p.Keywords {
+	case UpperCase:
+		return strings.ToUpper(s)
+	case TitleCase:
+		return strings.ToUpper(s[:1]) + s[1:]
+	}
+	return s
+}
+
+func (p *printer) colon() string {
+	if p.SpaceBeforeColon {
+		return " : "
+	}
+	return ": "
+}
+
+func (p *printer) equals() string {
+	if p.SpaceAroundEquals {
+		return " = "
+	}
+	return "="
+}
+
+func (p *printer) print(s ...string) {
+	for _, s := range s {
+		if p.line.Len() == 0 && s != "" {
+			p.line.WriteString(strings.Repeat(p.Indent, p.indent))
+		}
+		p.line.WriteString(s)
+	}
+}
+
+// lineLength is the number of characters in the current line, with tabs
+// counting as one character.
+func (p *printer) lineLength() int {
+	return utf8.RuneCountInString(p.line.String())
+}
+
+// newline ends the current line, even if it is empty.
+func (p *printer) newline() {
+	p.buf.WriteString(strings.TrimRight(p.line.String(), " \t"))
+	p.buf.WriteString(p.LineBreak)
+	p.line.Reset()
+}
+
+// endLine ends the current line if there is anything in it.
+func (p *printer) endLine() {
+	if p.line.Len() > 0 {
+		p.newline()
+	}
+}
+
+// emptyLine makes sure that the next line is preceded by an empty line.
+func (p *printer) emptyLine() {
+	p.endLine()
+	emptyLine := []byte(p.LineBreak + p.LineBreak)
+	if p.buf.Len() > 0 && !bytes.HasSuffix(p.buf.Bytes(), emptyLine) {
+		p.newline()
+	}
+}
@@ -0,0 +1,659 @@
+package printer_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/pas"
+	"github.com/gonutz/pas/printer"
+)
+
+func TestPrintDefaultStyle(t *testing.T) {
+	checkPrint(t, printer.DefaultConfig, `
+// Unit comment.
+unit U; // After unit.
+interface
+uses A,B.C;
+{ Free comment. }
+type
+  /// Doc of C.
+  C=class(TObject, IInterface)
+    A  :  Integer; // Trailing A.
+    // Doc of P.
+    procedure P(const [Ref] X: Integer; var Y; out LongParameterName, W: string; [Ref] const Q: Integer);
+  public
+    FUNCTION F: Integer;
+  end;
+var V: Integer;
+implementation
+
+{$R *.dfm}
+
+end.`, `
+// Unit comment.
+unit U; // After unit.
+
+interface
+
+uses
+  A, B.C;
+
+{ Free comment. }
+type
+  /// Doc of C.
+  C = class(TObject, IInterface)
+    A: Integer; // Trailing A.
+    // Doc of P.
+    procedure P(
+      const [Ref] X: Integer;
+      var Y;
+      out LongParameterName, W: string;
+      [Ref] const Q: Integer);
+  public
+    function F: Integer;
+  end;
+
+var
+  V: Integer;
+
+implementation
+
+{$R *.dfm}
+
+end.
+`)
+}
+
+func TestPrintCustomStyle(t *testing.T) {
+	checkPrint(t, printer.Config{
+		Indent:           "\t",
+		Keywords:         printer.UpperCase,
+		SpaceBeforeColon: true,
+		LineBreak:        "\r\n",
+	}, `
+unit U;
+interface
+type C = class
+  procedure P(const [Ref] X: Integer; var Y; out LongParameterName, W: string; [Ref] const Q: Integer);
+private
+  F: Integer;
+end;
+implementation
+end.`, `
+UNIT U;
+
+INTERFACE
+
+TYPE
+	C=CLASS
+		PROCEDURE P(CONST [Ref] X : Integer; VAR Y; OUT LongParameterName, W : string; [Ref] CONST Q : Integer);
+	PRIVATE
+		F : Integer;
+	END;
+
+IMPLEMENTATION
+
+END.
+`)
+}
+
+func TestPrintTitleCaseKeywords(t *testing.T) {
+	c := printer.DefaultConfig
+	c.Keywords = printer.TitleCase
+	checkPrint(t, c, `
+unit U;
+interface
+var I: Integer;
+implementation
+end.`, `
+Unit U;
+
+Interface
+
+Var
+  I: Integer;
+
+Implementation
+
+End.
+`)
+}
+
+func TestPrintDirectivesInKeywordCase(t *testing.T) {
+	code := `
+unit U;
+interface
+type C = class
+  procedure A; VIRTUAL; Abstract;
+  property P: Integer READ F Write F Default 0;
+end;
+implementation
+end.`
+	checkPrint(t, printer.DefaultConfig, code, `
+unit U;
+
+interface
+
+type
+  C = class
+    procedure A; virtual; abstract;
+    property P: Integer read F write F default 0;
+  end;
+
+implementation
+
+end.
+`)
+	c := printer.DefaultConfig
+	c.Keywords = printer.TitleCase
+	checkPrint(t, c, code, `
+Unit U;
+
+Interface
+
+Type
+  C = Class
+    Procedure A; Virtual; Abstract;
+    Property P: Integer Read F Write F Default 0;
+  End;
+
+Implementation
+
+End.
+`)
+}
+
+func TestPrintEscapesReservedWords(t *testing.T) {
+	checkPrint(t, printer.DefaultConfig, `
+unit U;
+interface
+type C = class
+  &Type: &begin.&End;
+  procedure &Set(&var: string);
+end;
+implementation
+end.`, `
+unit U;
+
+interface
+
+type
+  C = class
+    &Type: &begin.&End;
+    procedure &Set(&var: string);
+  end;
+
+implementation
+
+end.
+`)
+}
+
+func TestPrintAttributes(t *testing.T) {
+	checkPrint(t, printer.DefaultConfig, `
+unit U;
+interface
+type
+  /// Doc.
+  [Entity] [Table('users')]
+  C = class
+    [Required,MaxLength( 50 )]
+    Name: string;
+    [Get] procedure P([FromBody] const X: Integer; [Ref] const Y: Integer);
+  end;
+implementation
+end.`, `
+unit U;
+
+interface
+
+type
+  /// Doc.
+  [Entity, Table('users')]
+  C = class
+    [Required, MaxLength(50)]
+    Name: string;
+    [Get]
+    procedure P([FromBody] const X: Integer; [Ref] const Y: Integer);
+  end;
+
+implementation
+
+end.
+`)
+}
+
+func TestPrintRecords(t *testing.T) {
+	c := printer.DefaultConfig
+	c.Keywords = printer.TitleCase
+	checkPrint(t, c, `
+unit U;
+interface
+type [Table('points')] TPoint = RECORD
+  X : Integer;
+end;
+type TPacked = packed   record
+private
+  B: Byte;
+end;
+implementation
+end.`, `
+Unit U;
+
+Interface
+
+Type
+  [Table('points')]
+  TPoint = Record
+    X: Integer;
+  End;
+
+Type
+  TPacked = Packed Record
+  Private
+    B: Byte;
+  End;
+
+Implementation
+
+End.
+`)
+}
+
+func TestPrintConstants(t *testing.T) {
+	checkPrint(t, printer.DefaultConfig, `
+unit U;
+interface
+const
+  /// Doc.
+  Max=2*(Count+1);
+  [Info] Origin : TPoint = (X: 0;   Y: 0); // Comment.
+implementation
+end.`, `
+unit U;
+
+interface
+
+const
+  /// Doc.
+  Max = 2*(Count+1);
+  [Info]
+  Origin: TPoint = (X: 0; Y: 0); // Comment.
+
+implementation
+
+end.
+`)
+}
+
+func TestPrintDefaultParameterValues(t *testing.T) {
+	checkPrint(t, printer.DefaultConfig, `
+unit U;
+interface
+procedure P(X : Integer=0; const S: string =  'a');
+implementation
+end.`, `
+unit U;
+
+interface
+
+procedure P(X: Integer = 0; const S: string = 'a');
+
+implementation
+
+end.
+`)
+}
+
+func TestPrintInterfacesAndDirectives(t *testing.T) {
+	c := printer.DefaultConfig
+	c.Keywords = printer.TitleCase
+	checkPrint(t, c, `
+unit U;
+interface
+type
+  I = interface(IInterface) ['{0A5D1C3E-6E2B-4E8B-9F2C-1B2D3E4F5A6B}']
+    procedure P; stdcall;
+  end;
+type
+  C = class(TInterfacedObject, I)
+    procedure P; virtual; abstract;
+    procedure M(var Msg: TMessage); message WM_PAINT;
+  end;
+implementation
+end.`, `
+Unit U;
+
+Interface
+
+Type
+  I = Interface(IInterface) ['{0A5D1C3E-6E2B-4E8B-9F2C-1B2D3E4F5A6B}']
+    Procedure P; Stdcall;
+  End;
+
+Type
+  C = Class(TInterfacedObject, I)
+    Procedure P; Virtual; Abstract;
+    Procedure M(Var Msg: TMessage); Message WM_PAINT;
+  End;
+
+Implementation
+
+End.
+`)
+}
+
+func TestPrintProperties(t *testing.T) {
+	checkPrint(t, printer.DefaultConfig, `
+unit U;
+interface
+type
+  C = class
+    property Name:string READ FName Write SetName;
+    property Items[I:Integer]:TItem read GetItem;default; // Items.
+    property Caption;
+    property Left: Integer index 0 read GetCoord stored False default -1;
+  end;
+implementation
+end.`, `
+unit U;
+
+interface
+
+type
+  C = class
+    property Name: string read FName write SetName;
+    property Items[I: Integer]: TItem read GetItem; default; // Items.
+    property Caption;
+    property Left: Integer index 0 read GetCoord stored False default -1;
+  end;
+
+implementation
+
+end.
+`)
+}
+
+func TestPrintRoutinesKeepsBodies(t *testing.T) {
+	checkPrint(t, printer.DefaultConfig, `
+unit U;
+interface
+type
+  C = class
+    constructor Create;
+    class procedure P(X: Integer);
+  end;
+implementation
+constructor C.Create;
+begin
+  inherited; // Comment in body.
+end;
+  class procedure C.P(X : Integer);
+  var
+    I: Integer;
+  begin
+    for I := 1 to X do
+      Writeln(I);
+  end;
+end.`, `
+unit U;
+
+interface
+
+type
+  C = class
+    constructor Create;
+    class procedure P(X: Integer);
+  end;
+
+implementation
+
+constructor C.Create;
+begin
+  inherited; // Comment in body.
+end;
+
+class procedure C.P(X: Integer);
+  var
+    I: Integer;
+  begin
+    for I := 1 to X do
+      Writeln(I);
+  end;
+
+end.
+`)
+}
+
+func TestPrintKeepsCommentsBetweenHeaderAndBody(t *testing.T) {
+	checkPrint(t, printer.DefaultConfig, `
+unit U;
+interface
+implementation
+procedure P; // Header.
+// Before var.
+var
+  I: Integer;
+begin
+end;
+function F: Integer;
+{ Before begin. }
+begin
+  Result := 0;
+end;
+end.`, `
+unit U;
+
+interface
+
+implementation
+
+procedure P; // Header.
+// Before var.
+var
+  I: Integer;
+begin
+end;
+
+function F: Integer;
+{ Before begin. }
+begin
+  Result := 0;
+end;
+
+end.
+`)
+}
+
+func TestPrintInitializationAndFinalization(t *testing.T) {
+	checkPrint(t, printer.DefaultConfig, `
+unit U;
+interface
+implementation
+initialization // Register.
+  RegisterClass(TA);
+  if A then begin
+    { Keep me. }
+    B;
+  end;
+finalization
+// Before code.
+Free;
+end.`, `
+unit U;
+
+interface
+
+implementation
+
+initialization // Register.
+  RegisterClass(TA);
+  if A then
+  begin
+    { Keep me. }
+    B;
+  end;
+
+finalization
+// Before code.
+Free;
+
+end.
+`)
+	checkPrint(t, printer.DefaultConfig, `
+unit U;
+interface
+implementation
+begin
+  Run;
+end.`, `
+unit U;
+
+interface
+
+implementation
+
+initialization
+  Run;
+
+end.
+`)
+}
+
+func TestPrintBeginOnNewOrSameLine(t *testing.T) {
+	code := `
+unit U;
+interface
+implementation
+procedure P;
+begin
+  if A then begin
+    B;
+  end else
+  begin
+    C;
+  end;
+  while D do { Loop. } begin
+  end;
+  S := 'then begin';
+end;
+end.`
+	c := printer.DefaultConfig
+	c.LineBreak = "\n"
+	checkPrint(t, c, code, `
+unit U;
+
+interface
+
+implementation
+
+procedure P;
+begin
+  if A then
+  begin
+    B;
+  end else
+  begin
+    C;
+  end;
+  while D do { Loop. } begin
+  end;
+  S := 'then begin';
+end;
+
+end.
+`)
+	c.Begin = printer.BeginOnSameLine
+	checkPrint(t, c, code, `
+unit U;
+
+interface
+
+implementation
+
+procedure P;
+begin
+  if A then begin
+    B;
+  end else begin
+    C;
+  end;
+  while D do { Loop. } begin
+  end;
+  S := 'then begin';
+end;
+
+end.
+`)
+	c.Begin = printer.KeepBegin
+	checkPrint(t, c, code, `
+unit U;
+
+interface
+
+implementation
+
+procedure P;
+begin
+  if A then begin
+    B;
+  end else
+  begin
+    C;
+  end;
+  while D do { Loop. } begin
+  end;
+  S := 'then begin';
+end;
+
+end.
+`)
+}
+
+func TestPrintedCodeParsesToTheSameTree(t *testing.T) {
+	code := `unit U.V;
+interface
+uses System.SysUtils;
+type C = class(A.B)
+  F: Integer;
+published
+  procedure P(var X: Integer; const Y);
+  function G: string;
+end;
+var I: Integer;
+implementation
+end.`
+	f, err := pas.ParseString(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, f); err != nil {
+		t.Fatal(err)
+	}
+	g, err := pas.ParseString(buf.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, have bytes.Buffer
+	printer.Fprint(&want, f)
+	printer.Fprint(&have, g)
+	check.Eq(t, have.String(), want.String())
+}
+
+func checkPrint(t *testing.T, c printer.Config, code, want string) {
+	t.Helper()
+	f, err := pas.ParseString(strings.TrimPrefix(code, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := c.Fprint(&buf, f); err != nil {
+		t.Fatal(err)
+	}
+	want = strings.TrimPrefix(want, "\n")
+	want = strings.Replace(want, "\n", c.LineBreak, -1)
+	check.Eq(t, buf.String(), want)
+}
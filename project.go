@@ -0,0 +1,320 @@
+package pas
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Project is a Delphi program together with all the units that it uses,
+// directly or indirectly.
+type Project struct {
+	// Name is the program name from the .dpr file.
+	Name string
+	// MainSource is the path of the .dpr file.
+	MainSource string
+	// Config and Platform are the build configuration and target platform
+	// whose settings were used, e.g. "Debug" and "Win32". They are empty if
+	// the project was loaded from a .dpr file.
+	Config, Platform string
+	// SearchPath are the directories in which units are searched after the
+	// project directory.
+	SearchPath []string
+	// UnitScopeNames are prefixes for unit names in uses clauses, e.g. with
+	// the unit scope name "Vcl", "uses Forms" uses the unit Vcl.Forms.
+	UnitScopeNames []string
+	// Defines are the conditional defines of the build configuration. They are
+	// not used for parsing yet.
+	Defines []string
+	// Uses are the units in the uses clause of the .dpr file.
+	Uses []string
+	// Units are all units that were found, in the order in which they were
+	// found, starting with the units that the .dpr file uses.
+	Units []*ProjectUnit
+	// Unresolved are the names of used units that were not found, e.g. RTL
+	// units if their sources are not on the search path.
+	Unresolved []string
+
+	dir string
+	// explicit maps lower case unit names to the paths given in the .dpr or
+	// .dproj file.
+	explicit map[string]string
+	// dirs caches the lower case file names in each directory.
+	dirs map[string]map[string]string
+}
+
+// ProjectUnit is a unit of a Project.
+type ProjectUnit struct {
+	// Name is the unit name as declared in the unit, e.g. "Vcl.Forms" even if
+	// it is used as "Forms".
+	Name string
+	Path string
+	File *File
+}
+
+// ProjectOptions control how a project is loaded. The zero value is valid.
+type ProjectOptions struct {
+	// Config is the build configuration, e.g. "Debug" or "Release". If it is
+	// empty, the project's default configuration is used.
+	Config string
+	// Platform is the target platform, e.g. "Win32" or "Win64". If it is
+	// empty, the project's default platform is used.
+	Platform string
+	// Parse are the options for parsing the units. Their Filename is ignored.
+	Parse Options
+}
+
+// LoadProject loads a .dproj or .dpr file and parses all units that it uses,
+// see ProjectOptions.Load.
+func LoadProject(path string) (*Project, error) {
+	return ProjectOptions{}.Load(path)
+}
+
+// Load loads a .dproj or .dpr file and parses all units that it uses. The
+// search path, unit scope names and defines are read from the .dproj file.
+//
+// If units have syntax errors, the Project is returned with an ErrorList of
+// all errors. Units that cannot be found are not an error, they are listed in
+// Project.Unresolved.
+func (o ProjectOptions) Load(path string) (*Project, error) {
+	p := &Project{
+		dir:      filepath.Dir(path),
+		explicit: make(map[string]string),
+		dirs:     make(map[string]map[string]string),
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".dproj":
+		if err := p.readDproj(path, o); err != nil {
+			return nil, err
+		}
+	case ".dpr":
+		p.MainSource = path
+	default:
+		return nil, errors.New("pas: project must be a .dproj or .dpr file: " + path)
+	}
+	if err := p.readProgram(o.Parse); err != nil {
+		return nil, err
+	}
+	return p, p.parseUnits(o.Parse)
+}
+
+func (p *Project) readDproj(path string, o ProjectOptions) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	m, err := evaluateMSBuild(data, map[string]string{
+		"Config":   o.Config,
+		"Platform": o.Platform,
+	})
+	if err != nil {
+		return errors.New(path + ": " + err.Error())
+	}
+	p.Config = m.property("Config")
+	p.Platform = m.property("Platform")
+	main := m.property("MainSource")
+	if main == "" {
+		main = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + ".dpr"
+	}
+	p.MainSource = p.path(main)
+	for _, dir := range m.list("DCC_UnitSearchPath") {
+		p.SearchPath = append(p.SearchPath, p.path(dir))
+	}
+	p.UnitScopeNames = m.list("DCC_Namespace")
+	p.Defines = m.list("DCC_Define")
+	for _, ref := range m.references {
+		path := p.path(ref)
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		p.explicit[FoldIdentifier(name)] = path
+	}
+	return nil
+}
+
+// path converts a Windows path from a project file, which is relative to the
+// project directory, to a path on this system.
+func (p *Project) path(s string) string {
+	s = filepath.FromSlash(strings.Replace(s, `\`, "/", -1))
+	if filepath.IsAbs(s) {
+		return s
+	}
+	return filepath.Join(p.dir, s)
+}
+
+// readProgram reads the program name and the uses clause from the .dpr file.
+// The parser does not support programs yet, so only these parts are scanned:
+//
+//     program Name;
+//     uses
+//       A.B,
+//       C in 'src\C.pas' {Form1};
+func (p *Project) readProgram(o Options) error {
+	data, err := os.ReadFile(p.MainSource)
+	if err != nil {
+		return err
+	}
+	code, _, err := o.Decode(data)
+	if err != nil {
+		return err
+	}
+	s := NewScanner(code)
+	s.SkipWhiteSpace = true
+	s.SkipComments = true
+	s.tokens.filename = p.MainSource
+
+	t := s.Scan()
+	expect := func(what string, ok bool) error {
+		if ok {
+			return nil
+		}
+		return &ParseError{Pos: t.Pos, Expected: what, Found: t.Kind.String() + ` "` + t.Text + `"`}
+	}
+	qualifiedName := func() string {
+		name := t.Text
+		t = s.Scan()
+		for t.Text == "." {
+			t = s.Scan()
+			name += "." + strings.TrimPrefix(t.Text, "&")
+			t = s.Scan()
+		}
+		return strings.TrimPrefix(name, "&")
+	}
+
+	if err := expect(`keyword "program"`, strings.EqualFold(t.Text, "program")); err != nil {
+		return err
+	}
+	t = s.Scan()
+	if err := expect("program name", t.Kind == WordToken); err != nil {
+		return err
+	}
+	p.Name = qualifiedName()
+	if err := expect(`token ";"`, t.Text == ";"); err != nil {
+		return err
+	}
+	t = s.Scan()
+	if !strings.EqualFold(t.Text, "uses") {
+		return nil
+	}
+	for {
+		t = s.Scan()
+		if err := expect("uses clause", t.Kind == WordToken); err != nil {
+			return err
+		}
+		name := qualifiedName()
+		p.Uses = append(p.Uses, name)
+		if strings.EqualFold(t.Text, "in") {
+			t = s.Scan()
+			if err := expect("file name", t.Kind == StringToken); err != nil {
+				return err
+			}
+			path := strings.Replace(strings.Trim(t.Text, "'"), "''", "'", -1)
+			p.explicit[FoldIdentifier(name)] = p.path(path)
+			t = s.Scan()
+		}
+		if t.Text != "," {
+			break
+		}
+	}
+	return expect(`token ";"`, t.Text == ";")
+}
+
+// parseUnits parses the units that the program uses and the units that these
+// use, until all units are parsed.
+func (p *Project) parseUnits(o Options) error {
+	o.Filename = ""
+	var errs ErrorList
+	seen := make(map[string]bool)
+	unresolved := make(map[string]bool)
+	queue := p.Uses
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		path, ok := p.Resolve(name)
+		if !ok {
+			if !unresolved[FoldIdentifier(name)] {
+				unresolved[FoldIdentifier(name)] = true
+				p.Unresolved = append(p.Unresolved, name)
+			}
+			continue
+		}
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		f, err := o.ParseFile(path)
+		if list, ok := err.(ErrorList); ok {
+			errs = append(errs, list...)
+		} else if err != nil {
+			return err
+		}
+		u := &ProjectUnit{Name: f.Name, Path: path, File: f}
+		if u.Name == "" {
+			u.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+		p.Units = append(p.Units, u)
+		for _, s := range f.Sections {
+			queue = append(queue, s.Uses...)
+		}
+	}
+	return errs.Err()
+}
+
+// Resolve returns the path of the unit with the given name, as written in a
+// uses clause. Units whose paths are given in the .dpr or .dproj file are found
+// first. Otherwise the unit is searched in the project directory and then in
+// the SearchPath, first with the name as it is and then with each of the
+// UnitScopeNames in front of it.
+func (p *Project) Resolve(name string) (path string, ok bool) {
+	if path, ok := p.explicit[FoldIdentifier(name)]; ok {
+		return path, true
+	}
+	names := []string{name}
+	for _, scope := range p.UnitScopeNames {
+		names = append(names, scope+"."+name)
+	}
+	dirs := append([]string{p.dir}, p.SearchPath...)
+	for _, name := range names {
+		for _, dir := range dirs {
+			if path, ok := p.findFile(dir, name+".pas"); ok {
+				return path, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Unit returns the unit with the given name, as written in a uses clause, or
+// nil if it is not part of the project.
+func (p *Project) Unit(name string) *ProjectUnit {
+	path, ok := p.Resolve(name)
+	if !ok {
+		return nil
+	}
+	for _, u := range p.Units {
+		if u.Path == path {
+			return u
+		}
+	}
+	return nil
+}
+
+// findFile looks for the file in dir, ignoring case like Windows does.
+func (p *Project) findFile(dir, name string) (string, bool) {
+	files, ok := p.dirs[dir]
+	if !ok {
+		files = make(map[string]string)
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			if !e.IsDir() {
+				files[strings.ToLower(e.Name())] = e.Name()
+			}
+		}
+		p.dirs[dir] = files
+	}
+	file, ok := files[strings.ToLower(name)]
+	if !ok {
+		return "", false
+	}
+	return filepath.Join(dir, file), true
+}
@@ -0,0 +1,145 @@
+package pas_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/pas"
+)
+
+const testDproj = `<?xml version="1.0" encoding="utf-8"?>
+<Project xmlns="http://schemas.microsoft.com/developer/msbuild/2003">
+  <PropertyGroup>
+    <MainSource>P.dpr</MainSource>
+    <Config Condition="'$(Config)'==''">Debug</Config>
+    <Platform Condition="'$(Platform)'==''">Win32</Platform>
+  </PropertyGroup>
+  <PropertyGroup Condition="'$(Config)'=='Base' or '$(Base)'!=''">
+    <Base>true</Base>
+  </PropertyGroup>
+  <PropertyGroup Condition="('$(Platform)'=='Win64' and '$(Base)'=='true') or '$(Base_Win64)'!=''">
+    <Base_Win64>true</Base_Win64>
+  </PropertyGroup>
+  <PropertyGroup Condition="'$(Config)'=='Debug' or '$(Cfg_1)'!=''">
+    <Cfg_1>true</Cfg_1>
+    <CfgParent>Base</CfgParent>
+    <Base>true</Base>
+  </PropertyGroup>
+  <PropertyGroup Condition="'$(Config)'=='Release' or '$(Cfg_2)'!=''">
+    <Cfg_2>true</Cfg_2>
+    <CfgParent>Base</CfgParent>
+    <Base>true</Base>
+  </PropertyGroup>
+  <PropertyGroup Condition="'$(Base)'!=''">
+    <DCC_UnitSearchPath>lib;$(DCC_UnitSearchPath)</DCC_UnitSearchPath>
+    <DCC_Namespace>System;Vcl;$(DCC_Namespace)</DCC_Namespace>
+  </PropertyGroup>
+  <PropertyGroup Condition="'$(Base_Win64)'!=''">
+    <DCC_Define>WIN64;$(DCC_Define)</DCC_Define>
+  </PropertyGroup>
+  <PropertyGroup Condition="'$(Cfg_1)'!=''">
+    <DCC_Define>DEBUG;$(DCC_Define)</DCC_Define>
+  </PropertyGroup>
+  <PropertyGroup Condition="'$(Cfg_2)'!=''">
+    <DCC_Define>RELEASE;$(DCC_Define)</DCC_Define>
+  </PropertyGroup>
+  <ItemGroup>
+    <DelphiCompile Include="$(MainSource)">
+      <MainSource>MainSource</MainSource>
+    </DelphiCompile>
+    <DCCReference Include="src\Main.pas"/>
+  </ItemGroup>
+  <Import Project="$(BDS)\Bin\CodeGear.Delphi.Targets" Condition="Exists('$(BDS)\Bin\CodeGear.Delphi.Targets')"/>
+</Project>`
+
+func writeTestProject(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"P.dproj": testDproj,
+		"P.dpr": `program P;
+uses
+  Vcl.Forms,
+  Main in 'src\Main.pas' {Form1},
+  Helper;
+{$R *.res}
+begin
+end.`,
+		"src/Main.pas": `unit Main;
+interface
+uses Helper;
+implementation
+uses Vcl.Dialogs, Tools;
+end.`,
+		"HELPER.PAS":        "unit Helper; interface type THelper = class end; implementation end.",
+		"lib/Vcl.Tools.pas": "unit Vcl.Tools; interface uses Helper implementation end.",
+	}
+	for name, code := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(code), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadProjectFromDproj(t *testing.T) {
+	dir := writeTestProject(t)
+	p, err := pas.LoadProject(filepath.Join(dir, "P.dproj"))
+
+	// The syntax error in Vcl.Tools is reported but all units are loaded.
+	check.Eq(t, err != nil, true)
+	check.Eq(t, strings.HasPrefix(err.Error(),
+		`token ";" expected but was word "implementation" at `+
+			filepath.Join(dir, "lib", "Vcl.Tools.pas")+":1:39"), true)
+
+	check.Eq(t, p.Name, "P")
+	check.Eq(t, p.MainSource, filepath.Join(dir, "P.dpr"))
+	check.Eq(t, p.Config, "Debug")
+	check.Eq(t, p.Platform, "Win32")
+	check.Eq(t, p.SearchPath, []string{filepath.Join(dir, "lib")})
+	check.Eq(t, p.UnitScopeNames, []string{"System", "Vcl"})
+	check.Eq(t, p.Defines, []string{"DEBUG"})
+	check.Eq(t, p.Uses, []string{"Vcl.Forms", "Main", "Helper"})
+	check.Eq(t, p.Unresolved, []string{"Vcl.Forms", "Vcl.Dialogs"})
+
+	var names, paths []string
+	for _, u := range p.Units {
+		names = append(names, u.Name)
+		rel, _ := filepath.Rel(dir, u.Path)
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+	check.Eq(t, names, []string{"Main", "Helper", "Vcl.Tools"})
+	check.Eq(t, paths, []string{"src/Main.pas", "HELPER.PAS", "lib/Vcl.Tools.pas"})
+
+	check.Eq(t, p.Unit("Tools").Name, "Vcl.Tools")
+	check.Eq(t, p.Unit("helper").Path, filepath.Join(dir, "HELPER.PAS"))
+	var noUnit *pas.ProjectUnit
+	check.Eq(t, p.Unit("Vcl.Forms"), noUnit)
+}
+
+func TestProjectConfigAndPlatformCanBeChosen(t *testing.T) {
+	dir := writeTestProject(t)
+	p, _ := pas.ProjectOptions{
+		Config:   "Release",
+		Platform: "Win64",
+	}.Load(filepath.Join(dir, "P.dproj"))
+	check.Eq(t, p.Config, "Release")
+	check.Eq(t, p.Platform, "Win64")
+	check.Eq(t, p.Defines, []string{"RELEASE", "WIN64"})
+}
+
+func TestLoadProjectFromDpr(t *testing.T) {
+	dir := writeTestProject(t)
+	p, _ := pas.LoadProject(filepath.Join(dir, "P.dpr"))
+	check.Eq(t, p.Name, "P")
+	check.Eq(t, len(p.SearchPath), 0)
+	// Without the .dproj, there is no search path and no unit scope names.
+	check.Eq(t, p.Unresolved, []string{"Vcl.Forms", "Vcl.Dialogs", "Tools"})
+	check.Eq(t, len(p.Units), 2)
+}
@@ -0,0 +1,104 @@
+package pas
+
+import "fmt"
+
+// Scanner splits Delphi code into tokens, e.g. for syntax highlighting. It
+// does not parse the code, so it never fails, code that is not valid Delphi
+// produces IllegalTokens.
+//
+// By default, Scan returns all tokens, including white space and comments, so
+// the Text of all tokens put together is the original code.
+type Scanner struct {
+	// SkipWhiteSpace makes Scan leave out WhiteSpaceTokens.
+	SkipWhiteSpace bool
+	// SkipComments makes Scan leave out CommentTokens. Compiler directives
+	// like {$R *.dfm} are comments as well.
+	SkipComments bool
+	tokens       tokenizer
+}
+
+// NewScanner creates a Scanner for the given code.
+func NewScanner(code string) *Scanner {
+	return &Scanner{tokens: newTokenizer([]rune(code))}
+}
+
+// Scan returns the next token. At the end of the code it returns an EOFToken,
+// calling Scan after that returns the same EOFToken again.
+func (s *Scanner) Scan() Token {
+	for {
+		t := s.tokens.next()
+		if s.SkipWhiteSpace && t.tokenType == tokenWhiteSpace ||
+			s.SkipComments && t.tokenType == tokenComment {
+			continue
+		}
+		return Token{
+			Kind: t.kind(),
+			Text: t.text,
+			Pos:  t.pos(),
+			End:  t.end(),
+		}
+	}
+}
+
+// Token is a piece of code as returned by Scanner.Scan.
+type Token struct {
+	Kind TokenKind
+	// Text is the code of the token, e.g. the word, the symbol or the comment
+	// including its delimiters. It is empty for the EOFToken.
+	Text     string
+	Pos, End Position
+}
+
+// String returns the kind, text and position of the token, e.g.
+//
+//     word "unit" at 1:1
+func (t Token) String() string {
+	if t.Kind == EOFToken {
+		return fmt.Sprintf("%v at %v", t.Kind, t.Pos)
+	}
+	return fmt.Sprintf("%v %q at %v", t.Kind, t.Text, t.Pos)
+}
+
+type TokenKind int
+
+const (
+	// IllegalToken is a character that is not valid in Delphi code.
+	IllegalToken TokenKind = 0
+	// EOFToken marks the end of the code.
+	EOFToken TokenKind = 1
+	// WordToken is an identifier or keyword.
+	WordToken TokenKind = 2
+	// SymbolToken is a single character like ';', '(' or '+'.
+	SymbolToken TokenKind = 3
+	// WhiteSpaceToken is a sequence of spaces, tabs and line breaks.
+	WhiteSpaceToken TokenKind = 4
+	// CommentToken is a {...}, (*...*) or //... comment. A // comment includes
+	// the line break at its end.
+	CommentToken TokenKind = 5
+	// StringToken is a string literal like 'It''s'#13#10.
+	StringToken TokenKind = 6
+	// NumberToken is a number like 1, 1.5e3 or $FF.
+	NumberToken TokenKind = 7
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case IllegalToken:
+		return "illegal token"
+	case EOFToken:
+		return "end of file"
+	case WordToken:
+		return "word"
+	case SymbolToken:
+		return "symbol"
+	case WhiteSpaceToken:
+		return "white space"
+	case CommentToken:
+		return "comment"
+	case StringToken:
+		return "string"
+	case NumberToken:
+		return "number"
+	}
+	return "unknown TokenKind"
+}
@@ -0,0 +1,83 @@
+package pas_test
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/pas"
+)
+
+func TestScannerReturnsAllTokens(t *testing.T) {
+	s := pas.NewScanner("unit U; {c}\n%")
+	var tokens []string
+	for {
+		t := s.Scan()
+		tokens = append(tokens, t.String())
+		if t.Kind == pas.EOFToken {
+			break
+		}
+	}
+	check.Eq(t, tokens, []string{
+		`word "unit" at 1:1`,
+		`white space " " at 1:5`,
+		`word "U" at 1:6`,
+		`symbol ";" at 1:7`,
+		`white space " " at 1:8`,
+		`comment "{c}" at 1:9`,
+		`white space "\n" at 1:12`,
+		`illegal token "%" at 2:1`,
+		`end of file at 2:2`,
+	})
+	check.Eq(t, s.Scan().Kind, pas.EOFToken)
+}
+
+func TestScannerCanSkipWhiteSpaceAndComments(t *testing.T) {
+	s := pas.NewScanner("unit U; {c} // line\n(*old*) end")
+	s.SkipWhiteSpace = true
+	s.SkipComments = true
+	var texts []string
+	for t := s.Scan(); t.Kind != pas.EOFToken; t = s.Scan() {
+		texts = append(texts, t.Text)
+	}
+	check.Eq(t, texts, []string{"unit", "U", ";", "end"})
+}
+
+func TestScannerTokenSpans(t *testing.T) {
+	s := pas.NewScanner("{ä\n}x")
+	comment := s.Scan()
+	check.Eq(t, comment.Pos, pas.Position{Offset: 0, Line: 1, Col: 1})
+	check.Eq(t, comment.End, pas.Position{Offset: 5, Line: 2, Col: 2})
+	word := s.Scan()
+	check.Eq(t, word.Pos, comment.End)
+}
+
+func TestScannerReturnsStringsAndNumbers(t *testing.T) {
+	s := pas.NewScanner("X := '{'#13 + $1F;")
+	s.SkipWhiteSpace = true
+	var tokens []string
+	for t := s.Scan(); t.Kind != pas.EOFToken; t = s.Scan() {
+		tokens = append(tokens, t.Kind.String()+" "+t.Text)
+	}
+	check.Eq(t, tokens, []string{
+		"word X",
+		"symbol :",
+		"symbol =",
+		"string '{'#13",
+		"symbol +",
+		"number $1F",
+		"symbol ;",
+	})
+}
+
+func TestScannerDoesNotStopAtNULCharacters(t *testing.T) {
+	s := pas.NewScanner("A\x00B")
+	var tokens []string
+	for t := s.Scan(); t.Kind != pas.EOFToken; t = s.Scan() {
+		tokens = append(tokens, t.Kind.String()+" "+t.Text)
+	}
+	check.Eq(t, tokens, []string{
+		"word A",
+		"illegal token \x00",
+		"word B",
+	})
+}
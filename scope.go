@@ -0,0 +1,921 @@
+package pas
+
+import "strings"
+
+// Info is the result of name resolution over a set of units, see ResolveNames.
+type Info struct {
+	// Scopes are the interface and implementation scopes of all files, in the
+	// order of the files. Class, routine and with scopes are their Children.
+	Scopes []*Scope
+	// References are all identifiers in the files that refer to declarations,
+	// in the order in which they appear.
+	References []Reference
+	// System is the scope of the predeclared types like Integer and string.
+	// Every unit implicitly uses it before all other units.
+	System *Scope
+}
+
+// Scope is a region of code in which declarations are visible.
+type Scope struct {
+	Kind ScopeKind
+	// Name is the unit, class or routine name, or the expression of a with
+	// statement. Method implementations are named like "TFoo.Bar". The System
+	// scope is named "System".
+	Name string
+	// File is the file that the scope is in, it is nil for the System scope.
+	File *File
+	// Pos and End are the span of the section, class, routine or with
+	// statement. The interface scope of a unit spans only its interface
+	// section.
+	Pos, End Position
+	Parent   *Scope
+	Children []*Scope
+	// Symbols are the declarations in the scope, in the order in which they
+	// appear, including duplicates.
+	Symbols []*Symbol
+
+	// names maps lower case names to the first symbol of that name.
+	names map[string]*Symbol
+	// uses are the units of a unit scope's uses clause, in order.
+	uses []usedScope
+	// bases are the scopes of a class scope's super classes. In the routine
+	// scope of a method implementation, it is the class, in a with scope the
+	// type of the expression. Names that are not declared in the scope itself
+	// are looked up in the bases.
+	bases []*Scope
+	// routines are the routines that a unit scope declares or implements, or
+	// the nested routines of a routine scope.
+	routines []Routine
+	// routine is the routine of a routine scope with a body. block is the
+	// position of the body's "begin" or "asm", its Line is 0 if not all local
+	// declarations could be parsed.
+	routine *Routine
+	block   Position
+	// errors are the errors in the block of a routine scope. The block is
+	// checked when its references are resolved, CheckTypes reports them.
+	errors []*TypeError
+}
+
+type usedScope struct {
+	// name is the unit name as written in the uses clause.
+	name string
+	// scope is the interface scope of the unit, or nil if it was not found.
+	scope *Scope
+}
+
+type ScopeKind int
+
+const (
+	SystemScope         ScopeKind = 0
+	InterfaceScope      ScopeKind = 1
+	ImplementationScope ScopeKind = 2
+	ClassScope          ScopeKind = 3
+	RoutineScope        ScopeKind = 4
+	WithScope           ScopeKind = 5
+)
+
+func (k ScopeKind) String() string {
+	switch k {
+	case SystemScope:
+		return "system"
+	case InterfaceScope:
+		return "interface"
+	case ImplementationScope:
+		return "implementation"
+	case ClassScope:
+		return "class"
+	case RoutineScope:
+		return "routine"
+	case WithScope:
+		return "with"
+	}
+	return "unknown ScopeKind"
+}
+
+// Symbol is a declared name.
+type Symbol struct {
+	Name string
+	Kind SymbolKind
+	// Decl is the declaring Class, Variable, Constant, Function or Parameter.
+	// It is nil for predeclared types.
+	Decl Node
+	// File is the file that declares the symbol, it is nil for predeclared
+	// types.
+	File *File
+	// Scope is the scope that the symbol opens, i.e. the members of a class or
+	// the parameters of a method declaration or of a routine with a body,
+	// otherwise it is nil.
+	Scope *Scope
+}
+
+type SymbolKind int
+
+const (
+	// TypeSymbol is a predeclared type of the System scope.
+	TypeSymbol      SymbolKind = 0
+	ClassSymbol     SymbolKind = 1
+	VariableSymbol  SymbolKind = 2
+	FieldSymbol     SymbolKind = 3
+	MethodSymbol    SymbolKind = 4
+	ParameterSymbol SymbolKind = 5
+	InterfaceSymbol SymbolKind = 6
+	PropertySymbol  SymbolKind = 7
+	RecordSymbol    SymbolKind = 8
+	ConstantSymbol  SymbolKind = 9
+	// RoutineSymbol is a procedure or function that is not a method.
+	RoutineSymbol SymbolKind = 10
+)
+
+func (k SymbolKind) String() string {
+	switch k {
+	case TypeSymbol:
+		return "type"
+	case ClassSymbol:
+		return "class"
+	case VariableSymbol:
+		return "variable"
+	case FieldSymbol:
+		return "field"
+	case MethodSymbol:
+		return "method"
+	case ParameterSymbol:
+		return "parameter"
+	case InterfaceSymbol:
+		return "interface"
+	case PropertySymbol:
+		return "property"
+	case RecordSymbol:
+		return "record"
+	case ConstantSymbol:
+		return "constant"
+	case RoutineSymbol:
+		return "routine"
+	}
+	return "unknown SymbolKind"
+}
+
+// Reference is an identifier that refers to a declaration, e.g. the type of a
+// variable, the super class of a class, the field that a property reads or a
+// name in a routine body.
+type Reference struct {
+	// Name is the identifier as written, e.g. "TForm" or "Vcl.Forms.TForm".
+	// For attributes it is the name in brackets, even if it refers to a class
+	// with the suffix "Attribute".
+	Name string
+	// Node is the Class, Variable, Constant, Function, Parameter, Property or
+	// Attribute that contains the reference. For the names in a routine body,
+	// it is the Routine.
+	Node Node
+	// Scope is the scope in which the name is looked up. For a member, like
+	// Caption in "Form.Caption", it is the scope of the class.
+	Scope *Scope
+	// Symbol is the declaration that the name refers to, or nil if it is not
+	// declared.
+	Symbol *Symbol
+	// Pos is the start of the identifier, End is right after it. Qualified
+	// names span all their parts.
+	Pos, End Position
+
+	// value is set for the references to values and routines, i.e. the
+	// property accessors and the names in routine bodies. All other
+	// references are to types.
+	value bool
+}
+
+// DefaultUnitScopeNames are the unit scope names that ResolveNames tries for
+// the used units that it does not find by their name. They are the ones of a
+// new VCL application for Windows.
+var DefaultUnitScopeNames = []string{
+	"Winapi", "System.Win", "Data.Win", "Datasnap.Win", "Web.Win", "Soap.Win",
+	"Xml.Win", "System", "Xml", "Data", "Datasnap", "Web", "Soap", "Vcl",
+	"Vcl.Imaging", "Vcl.Touch", "Vcl.Samples", "Vcl.Shell",
+}
+
+// predeclaredTypes are the types of Delphi's System unit that are used most.
+var predeclaredTypes = []string{
+	"Boolean", "ByteBool", "WordBool", "LongBool",
+	"ShortInt", "SmallInt", "Integer", "LongInt", "Int64", "NativeInt",
+	"Byte", "Word", "Cardinal", "LongWord", "UInt64", "NativeUInt",
+	"Int8", "Int16", "Int32", "UInt8", "UInt16", "UInt32",
+	"Single", "Double", "Extended", "Real", "Currency", "Comp",
+	"Char", "AnsiChar", "WideChar",
+	"string", "AnsiString", "WideString", "UnicodeString", "ShortString",
+	"RawByteString", "UTF8String",
+	"Pointer", "PChar", "PAnsiChar", "PWideChar",
+	"Variant", "OleVariant", "TDateTime",
+	"TObject", "TClass", "IInterface", "IUnknown", "TInterfacedObject",
+	"TCustomAttribute",
+}
+
+// ResolveNames builds the scopes of the files and resolves all references in
+// them. Used units are looked up by name among the files and, if there is no
+// such file, with each of the DefaultUnitScopeNames in front of the name, so
+// "uses Forms" uses the file of unit Vcl.Forms. Use Project.ResolveNames to
+// resolve them like the compiler, with the project's unit scope names.
+//
+// Names are looked up like in Delphi: case is ignored, declarations of the unit
+// itself come first, then the used units in reverse order, so the last used
+// unit that declares a name wins. Members of a class are also looked up in its
+// super classes. Names can be qualified with a unit name, e.g. "SysUtils.TBytes".
+//
+// Routines with bodies have routine scopes with their parameters, local
+// variables and constants and nested routines. In method implementations, the
+// members of the class are visible as well. The statements of a body are not
+// parsed, but with statements are found in its code and get with scopes in
+// which the members of the type of their expression are visible. Only names
+// like "Form1.Edit1" and type casts like "TFoo(X)" are supported as with
+// expressions.
+//
+// The references are the types, super classes and attributes in declarations,
+// including those of the local declarations, the read and write accessors of
+// properties and the names in the blocks of routine bodies. Names in bodies
+// are resolved as far as CheckTypes checks the bodies: members are found if
+// the type of the expression in front of them is known, and bodies with local
+// types or labels have no references.
+func ResolveNames(files []*File) *Info {
+	byName := make(map[string]*File)
+	for _, f := range files {
+		if _, ok := byName[FoldIdentifier(f.Name)]; !ok {
+			byName[FoldIdentifier(f.Name)] = f
+		}
+	}
+	return resolveNames(files, func(name string) *File {
+		if f := byName[FoldIdentifier(name)]; f != nil {
+			return f
+		}
+		for _, scope := range DefaultUnitScopeNames {
+			if f := byName[FoldIdentifier(scope+"."+name)]; f != nil {
+				return f
+			}
+		}
+		return nil
+	})
+}
+
+// ResolveNames builds the scopes of the project's units and resolves all
+// references in them, see the package function ResolveNames. Used units are
+// resolved like in Project.Resolve, including the unit scope names.
+func (p *Project) ResolveNames() *Info {
+	var files []*File
+	for _, u := range p.Units {
+		files = append(files, u.File)
+	}
+	return resolveNames(files, func(name string) *File {
+		if u := p.Unit(name); u != nil {
+			return u.File
+		}
+		return nil
+	})
+}
+
+func resolveNames(files []*File, resolve func(string) *File) *Info {
+	info := &Info{System: &Scope{Kind: SystemScope, Name: "System"}}
+	for _, name := range predeclaredTypes {
+		info.System.add(&Symbol{Name: name, Kind: TypeSymbol})
+	}
+
+	// First declare everything, then resolve the uses clauses and references,
+	// which can refer to declarations in any of the files.
+	interfaces := make(map[*File]*Scope)
+	for _, f := range files {
+		var intf *Scope
+		for _, s := range f.Sections {
+			// Initialization and finalization sections declare nothing.
+			if s.Kind != InterfaceSection && s.Kind != ImplementationSection {
+				continue
+			}
+			scope := declareSection(f, s, info.System, intf)
+			if s.Kind == InterfaceSection {
+				intf = scope
+				interfaces[f] = scope
+			}
+			info.Scopes = append(info.Scopes, scope)
+		}
+	}
+	for _, scope := range info.Scopes {
+		for i, u := range scope.uses {
+			if u.scope == nil {
+				if f := resolve(u.name); f != nil {
+					scope.uses[i].scope = interfaces[f]
+				}
+			}
+		}
+	}
+	for _, scope := range info.Scopes {
+		resolveBases(scope)
+	}
+	// Routine scopes need the class hierarchy for the with statements.
+	for _, scope := range info.Scopes {
+		for _, r := range scope.routines {
+			if r.Body != "" {
+				info.declareRoutine(scope, r)
+			}
+		}
+	}
+	for _, scope := range info.Scopes {
+		info.References = append(info.References, info.resolveReferences(scope)...)
+	}
+	return info
+}
+
+// declareSection creates the scope of a file section with the scopes of its
+// classes and their methods. The used units are resolved later, except for the
+// System scope, which every interface scope uses first. The parent of the
+// implementation scope is the interface scope intf, the routines that it
+// declares are not declared again by their implementations.
+func declareSection(f *File, s FileSection, system, intf *Scope) *Scope {
+	kind := InterfaceScope
+	if s.Kind != InterfaceSection {
+		kind = ImplementationScope
+	}
+	scope := &Scope{Kind: kind, Name: f.Name, File: f, Pos: s.Pos, End: s.End}
+	if kind == ImplementationScope {
+		scope.Parent = intf
+	}
+	if kind == InterfaceScope {
+		scope.uses = append(scope.uses, usedScope{name: "System", scope: system})
+	}
+	for _, name := range s.Uses {
+		scope.uses = append(scope.uses, usedScope{name: name})
+	}
+	for _, b := range s.Blocks {
+		switch b := b.(type) {
+		case TypeBlock:
+			for _, t := range b {
+				if c, ok := t.(Class); ok {
+					kind := ClassSymbol
+					if c.IsInterface {
+						kind = InterfaceSymbol
+					} else if c.IsRecord {
+						kind = RecordSymbol
+					}
+					scope.add(&Symbol{
+						Name:  c.Name,
+						Kind:  kind,
+						Decl:  c,
+						File:  f,
+						Scope: declareClass(f, c, scope),
+					})
+				}
+			}
+		case VarBlock:
+			for _, v := range b {
+				scope.add(&Symbol{Name: v.Name, Kind: VariableSymbol, Decl: v, File: f})
+			}
+		case ConstBlock:
+			for _, c := range b {
+				scope.add(&Symbol{Name: c.Name, Kind: ConstantSymbol, Decl: c, File: f})
+			}
+		case Routine:
+			scope.routines = append(scope.routines, b)
+			if b.ClassName == "" && !(intf != nil && kind == ImplementationScope &&
+				intf.declaresRoutine(b.Header.Name)) {
+				scope.add(&Symbol{Name: b.Header.Name, Kind: RoutineSymbol, Decl: b.Header, File: f})
+			}
+		}
+	}
+	return scope
+}
+
+func (s *Scope) declaresRoutine(name string) bool {
+	sym := s.names[FoldIdentifier(name)]
+	return sym != nil && sym.Kind == RoutineSymbol
+}
+
+func declareClass(f *File, c Class, parent *Scope) *Scope {
+	scope := parent.child(ClassScope, c.Name, c.Pos, c.End)
+	for _, s := range c.Sections {
+		for _, m := range s.Members {
+			switch m := m.(type) {
+			case Variable:
+				scope.add(&Symbol{Name: m.Name, Kind: FieldSymbol, Decl: m, File: f})
+			case Function:
+				routine := scope.child(RoutineScope, m.Name, m.Pos, m.End)
+				for _, p := range m.Parameters {
+					for _, name := range p.Names {
+						routine.add(&Symbol{Name: name, Kind: ParameterSymbol, Decl: p, File: f})
+					}
+				}
+				scope.add(&Symbol{Name: m.Name, Kind: MethodSymbol, Decl: m, File: f, Scope: routine})
+			case Property:
+				scope.add(&Symbol{Name: m.Name, Kind: PropertySymbol, Decl: m, File: f})
+			}
+		}
+	}
+	return scope
+}
+
+// declareRoutine creates the scope of a routine with a body, with the scopes of
+// its nested routines and with statements.
+func (info *Info) declareRoutine(parent *Scope, r Routine) {
+	name := r.Header.Name
+	if r.ClassName != "" {
+		name = r.ClassName + "." + name
+	}
+	scope := parent.child(RoutineScope, name, r.Pos, r.End)
+	scope.routine = &r
+	for _, sym := range parent.Symbols {
+		if f, ok := sym.Decl.(Function); ok && sym.Kind == RoutineSymbol && f.Pos == r.Header.Pos {
+			sym.Scope = scope
+		}
+	}
+	if r.ClassName != "" {
+		if class := parent.Lookup(r.ClassName); class != nil &&
+			(class.Kind == ClassSymbol || class.Kind == RecordSymbol) {
+			scope.bases = []*Scope{class.Scope}
+		}
+	}
+	f := parent.File
+	for _, p := range r.Header.Parameters {
+		for _, name := range p.Names {
+			scope.add(&Symbol{Name: name, Kind: ParameterSymbol, Decl: p, File: f})
+		}
+	}
+	locals, block := parseLocals(r)
+	scope.block = block
+	for _, b := range locals {
+		switch b := b.(type) {
+		case VarBlock:
+			for _, v := range b {
+				scope.add(&Symbol{Name: v.Name, Kind: VariableSymbol, Decl: v, File: f})
+			}
+		case ConstBlock:
+			for _, c := range b {
+				scope.add(&Symbol{Name: c.Name, Kind: ConstantSymbol, Decl: c, File: f})
+			}
+		case Routine:
+			scope.routines = append(scope.routines, b)
+			scope.add(&Symbol{Name: b.Header.Name, Kind: RoutineSymbol, Decl: b.Header, File: f})
+		}
+	}
+	for _, nested := range scope.routines {
+		if nested.Body != "" {
+			info.declareRoutine(scope, nested)
+		}
+	}
+	info.declareWiths(scope, r)
+}
+
+// declareWiths creates the scopes of the with statements in the routine's body.
+// The with statements of nested routines are in their own scopes. A with
+// statement with several expressions, like "with A, B do", is the same as
+// nested with statements, so there is one scope per expression.
+func (info *Info) declareWiths(routine *Scope, r Routine) {
+	s := NewScanner(r.Body)
+	s.SkipWhiteSpace = true
+	s.SkipComments = true
+	s.tokens.filename = r.BodyPos.Filename
+	s.tokens.offset = r.BodyPos.Offset
+	s.tokens.line = r.BodyPos.Line
+	s.tokens.col = r.BodyPos.Col
+	var tokens []Token
+	for t := s.Scan(); t.Kind != EOFToken; t = s.Scan() {
+		nested := false
+		for _, n := range routine.routines {
+			nested = nested || n.Pos.Offset <= t.Pos.Offset && t.Pos.Offset < n.End.Offset
+		}
+		if !nested {
+			tokens = append(tokens, t)
+		}
+	}
+
+	// open are the innermost scopes of the with statements around the current
+	// token.
+	var open []*Scope
+	for i, t := range tokens {
+		if !isWord(t, "with") {
+			continue
+		}
+		for len(open) > 0 && open[len(open)-1].End.Offset <= t.Pos.Offset {
+			open = open[:len(open)-1]
+		}
+		parent := routine
+		if len(open) > 0 {
+			parent = open[len(open)-1]
+		}
+		var exprs []string
+		expr := ""
+		depth := 0
+		j := i + 1
+		for ; j < len(tokens) && !(depth == 0 && isWord(tokens[j], "do")); j++ {
+			switch tokens[j].Text {
+			case "(", "[":
+				depth++
+			case ")", "]":
+				depth--
+			case ",":
+				if depth == 0 {
+					exprs = append(exprs, expr)
+					expr = ""
+					continue
+				}
+			}
+			expr += tokens[j].Text
+		}
+		if j == len(tokens) {
+			break
+		}
+		exprs = append(exprs, expr)
+		end := statementEnd(tokens, j+1)
+		for _, expr := range exprs {
+			with := parent.child(WithScope, expr, t.Pos, end)
+			if typ := info.expressionScope(parent, expr); typ != nil {
+				with.bases = []*Scope{typ}
+			}
+			parent = with
+		}
+		open = append(open, parent)
+	}
+}
+
+func isWord(t Token, word string) bool {
+	return t.Kind == WordToken && FoldIdentifier(t.Text) == word
+}
+
+// statementEnd returns the end of the statement that starts with tokens[start].
+// The statement ends at a ';' or in front of the keyword that ends the block
+// around it, like "end", "until" or the "else" of an if statement around it.
+func statementEnd(tokens []Token, start int) Position {
+	depth, ifs := 0, 0
+	for i := start; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.Text == ";" && depth == 0 {
+			return tokens[i-1].End
+		}
+		if t.Kind != WordToken {
+			continue
+		}
+		switch FoldIdentifier(t.Text) {
+		case "begin", "asm", "case", "try", "repeat", "record":
+			depth++
+		case "end", "until":
+			if depth == 0 {
+				return tokens[i-1].End
+			}
+			depth--
+		case "if":
+			if depth == 0 {
+				ifs++
+			}
+		case "else":
+			if depth == 0 && ifs == 0 {
+				return tokens[i-1].End
+			}
+			if depth == 0 {
+				ifs--
+			}
+		case "finally", "except":
+			if depth == 0 {
+				return tokens[i-1].End
+			}
+		}
+	}
+	return tokens[len(tokens)-1].End
+}
+
+// expressionScope returns the class, interface or record scope of the type of
+// a with expression, or nil if it is not known. The expression is either a name
+// like "Form1.Edit1" or a type cast like "TFoo(X)".
+func (info *Info) expressionScope(in *Scope, expr string) *Scope {
+	if i := strings.IndexByte(expr, '('); i > 0 && strings.HasSuffix(expr, ")") {
+		if sym := in.Lookup(expr[:i]); sym != nil && sym.Scope != nil &&
+			(sym.Kind == ClassSymbol || sym.Kind == InterfaceSymbol || sym.Kind == RecordSymbol) {
+			return sym.Scope
+		}
+		return nil
+	}
+	sym := in.Lookup(expr)
+	if sym == nil {
+		parts := strings.Split(expr, ".")
+		sym = in.Lookup(parts[0])
+		for _, name := range parts[1:] {
+			scope := info.TypeScope(sym)
+			if scope == nil {
+				return nil
+			}
+			sym = scope.member(name, nil)
+		}
+	}
+	return info.TypeScope(sym)
+}
+
+// TypeScope returns the class, interface or record scope of the symbol's type,
+// e.g. for a field of type TButton the scope of TButton. For classes,
+// interfaces and records, it is their own scope. It returns nil if the type is
+// not a class, interface or record or if it is not declared.
+func (info *Info) TypeScope(sym *Symbol) *Scope {
+	if sym == nil {
+		return nil
+	}
+	if sym.Kind == ClassSymbol || sym.Kind == InterfaceSymbol || sym.Kind == RecordSymbol {
+		return sym.Scope
+	}
+	var typ string
+	switch d := sym.Decl.(type) {
+	case Variable:
+		typ = d.Type
+	case Constant:
+		typ = d.Type
+	case Property:
+		typ = d.Type
+	case Function:
+		typ = d.Returns
+	case Parameter:
+		typ = d.Type
+	}
+	if typ == "" || sym.File == nil {
+		return nil
+	}
+	pos, _ := sym.Decl.Span()
+	scope := info.ScopeAt(sym.File, pos.Offset)
+	if scope == nil {
+		return nil
+	}
+	if t := scope.Lookup(typ); t != nil &&
+		(t.Kind == ClassSymbol || t.Kind == InterfaceSymbol || t.Kind == RecordSymbol) {
+		return t.Scope
+	}
+	return nil
+}
+
+func (s *Scope) child(kind ScopeKind, name string, pos, end Position) *Scope {
+	c := &Scope{Kind: kind, Name: name, File: s.File, Pos: pos, End: end, Parent: s}
+	s.Children = append(s.Children, c)
+	return c
+}
+
+func (s *Scope) add(sym *Symbol) {
+	s.Symbols = append(s.Symbols, sym)
+	if s.names == nil {
+		s.names = make(map[string]*Symbol)
+	}
+	if _, ok := s.names[FoldIdentifier(sym.Name)]; !ok {
+		s.names[FoldIdentifier(sym.Name)] = sym
+	}
+}
+
+// resolveBases finds the scopes of the super classes and interfaces of all
+// classes and interfaces in the scope.
+func resolveBases(scope *Scope) {
+	for _, sym := range scope.Symbols {
+		if sym.Kind != ClassSymbol && sym.Kind != InterfaceSymbol {
+			continue
+		}
+		for _, super := range sym.Decl.(Class).SuperClasses {
+			if base := scope.Lookup(super); base != nil &&
+				(base.Kind == ClassSymbol || base.Kind == InterfaceSymbol) {
+				sym.Scope.bases = append(sym.Scope.bases, base.Scope)
+			}
+		}
+	}
+}
+
+// resolveReferences resolves the references in the declarations of the scope,
+// of its classes, in the headers of its routines and in their blocks.
+func (info *Info) resolveReferences(scope *Scope) []Reference {
+	var refs []Reference
+	ref := func(name string, n Node, in *Scope, pos, end Position) {
+		if name != "" {
+			refs = append(refs, Reference{
+				Name: name, Node: n, Scope: in, Symbol: in.Lookup(name), Pos: pos, End: end,
+			})
+		}
+	}
+	accessor := func(name string, n Node, in *Scope, pos, end Position) {
+		ref(name, n, in, pos, end)
+		if name != "" {
+			refs[len(refs)-1].value = true
+		}
+	}
+	attributes := func(list []Attribute, in *Scope) {
+		for _, a := range list {
+			sym := in.Lookup(a.Name + "Attribute")
+			if sym == nil {
+				sym = in.Lookup(a.Name)
+			}
+			refs = append(refs, Reference{
+				Name: a.Name, Node: a, Scope: in, Symbol: sym, Pos: a.Pos, End: a.NameEnd,
+			})
+		}
+	}
+	function := func(f Function, in *Scope) {
+		attributes(f.Attributes, in)
+		for _, p := range f.Parameters {
+			attributes(p.Attributes, in)
+			ref(p.Type, p, in, p.TypePos, p.TypeEnd)
+		}
+		ref(f.Returns, f, in, f.ReturnsPos, f.ReturnsEnd)
+	}
+	for _, sym := range scope.Symbols {
+		switch d := sym.Decl.(type) {
+		case Class:
+			attributes(d.Attributes, scope)
+			for i, super := range d.SuperClasses {
+				ref(super, d, scope, d.SuperClassPos[i], d.SuperClassEnd[i])
+			}
+			refs = append(refs, info.resolveReferences(sym.Scope)...)
+		case Variable:
+			attributes(d.Attributes, scope)
+			ref(d.Type, d, scope, d.TypePos, d.TypeEnd)
+		case Constant:
+			attributes(d.Attributes, scope)
+			ref(d.Type, d, scope, d.TypePos, d.TypeEnd)
+		case Function:
+			// The headers of routines are resolved with the routines below.
+			if sym.Kind == MethodSymbol {
+				function(d, scope)
+			}
+		case Property:
+			attributes(d.Attributes, scope)
+			for _, p := range d.Parameters {
+				attributes(p.Attributes, scope)
+				ref(p.Type, p, scope, p.TypePos, p.TypeEnd)
+			}
+			ref(d.Type, d, scope, d.TypePos, d.TypeEnd)
+			// The accessors are fields or methods of the class.
+			accessor(d.Read, d, scope, d.ReadPos, d.ReadEnd)
+			accessor(d.Write, d, scope, d.WritePos, d.WriteEnd)
+		}
+	}
+	// The types in the header of a method implementation are looked up in the
+	// class, like in its declaration.
+	for _, r := range scope.routines {
+		in := scope
+		if r.ClassName != "" {
+			if class := scope.Lookup(r.ClassName); class != nil &&
+				(class.Kind == ClassSymbol || class.Kind == RecordSymbol) {
+				in = class.Scope
+			}
+		}
+		function(r.Header, in)
+		if body := scope.body(r); body != nil {
+			refs = append(refs, info.resolveReferences(body)...)
+			if body.routine != nil && body.block.Line != 0 {
+				var blockRefs []Reference
+				blockRefs, body.errors = info.checkBody(body)
+				refs = append(refs, blockRefs...)
+			}
+		}
+	}
+	return refs
+}
+
+// body returns the routine scope of the routine, or nil if it has no body.
+func (s *Scope) body(r Routine) *Scope {
+	for _, c := range s.Children {
+		if c.Kind == RoutineScope && c.Pos == r.Pos {
+			return c
+		}
+	}
+	return nil
+}
+
+// Lookup returns the symbol that the name refers to in this scope, or nil if
+// it is not declared. The name can be qualified with a unit name, like
+// "Vcl.Forms.TForm", or refer to a member, like "TForm.Caption".
+func (s *Scope) Lookup(name string) *Symbol {
+	parts := strings.Split(name, ".")
+	var sym *Symbol
+	rest := parts[1:]
+	found := false
+	for n := len(parts) - 1; n >= 1 && !found; n-- {
+		if unit := s.unit(strings.Join(parts[:n], ".")); unit != nil {
+			sym = unit.names[FoldIdentifier(parts[n])]
+			rest = parts[n+1:]
+			found = true
+		}
+	}
+	if !found {
+		sym = s.lookup(parts[0])
+	}
+	for _, member := range rest {
+		if sym == nil || sym.Scope == nil {
+			return nil
+		}
+		sym = sym.Scope.member(member, nil)
+	}
+	return sym
+}
+
+// lookup finds an unqualified name, first in this scope and its parents, then
+// in the used units.
+func (s *Scope) lookup(name string) *Symbol {
+	for scope := s; scope != nil; scope = scope.Parent {
+		if sym := scope.member(name, nil); sym != nil {
+			return sym
+		}
+	}
+	for scope := s; scope != nil; scope = scope.Parent {
+		for i := len(scope.uses) - 1; i >= 0; i-- {
+			if u := scope.uses[i].scope; u != nil {
+				if sym := u.names[FoldIdentifier(name)]; sym != nil {
+					return sym
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// member finds a name that is declared in this scope or, for classes, in one
+// of the super classes. visited guards against cyclic class hierarchies.
+func (s *Scope) member(name string, visited map[*Scope]bool) *Symbol {
+	if sym := s.names[FoldIdentifier(name)]; sym != nil {
+		return sym
+	}
+	if len(s.bases) == 0 {
+		return nil
+	}
+	if visited == nil {
+		visited = make(map[*Scope]bool)
+	}
+	visited[s] = true
+	for _, base := range s.bases {
+		if !visited[base] {
+			if sym := base.member(name, visited); sym != nil {
+				return sym
+			}
+		}
+	}
+	return nil
+}
+
+// Members returns the symbols that are declared in the scope and, for classes
+// and interfaces, the inherited ones that are not hidden by a symbol of the
+// same name, nearest first. Overloads in the same scope are all returned.
+func (s *Scope) Members() []*Symbol {
+	var list []*Symbol
+	hidden := make(map[string]bool)
+	visited := make(map[*Scope]bool)
+	var add func(s *Scope)
+	add = func(s *Scope) {
+		if visited[s] {
+			return
+		}
+		visited[s] = true
+		var names []string
+		for _, sym := range s.Symbols {
+			name := FoldIdentifier(sym.Name)
+			if !hidden[name] {
+				list = append(list, sym)
+				names = append(names, name)
+			}
+		}
+		for _, name := range names {
+			hidden[name] = true
+		}
+		for _, base := range s.bases {
+			add(base)
+		}
+	}
+	add(s)
+	return list
+}
+
+// unit returns the interface scope of the unit with the given name if it is
+// this unit or one that it uses.
+func (s *Scope) unit(name string) *Scope {
+	for scope := s; scope != nil; scope = scope.Parent {
+		if scope.Kind == InterfaceScope && SameIdentifier(scope.Name, name) {
+			return scope
+		}
+		for _, u := range scope.uses {
+			if u.scope != nil &&
+				(SameIdentifier(u.name, name) || SameIdentifier(u.scope.Name, name)) {
+				return u.scope
+			}
+		}
+	}
+	return nil
+}
+
+// ScopeAt returns the innermost scope of the file that contains the byte
+// offset, or nil if the offset is outside of all sections.
+func (info *Info) ScopeAt(f *File, offset int) *Scope {
+	var found *Scope
+	scopes := info.Scopes
+	for len(scopes) > 0 {
+		var inner []*Scope
+		for _, s := range scopes {
+			if s.File == f && s.Pos.Offset <= offset && offset < s.End.Offset {
+				found = s
+				inner = s.Children
+				break
+			}
+		}
+		scopes = inner
+	}
+	return found
+}
+
+// ReferencesTo returns all references to the symbol, in the order in which they
+// appear.
+func (info *Info) ReferencesTo(sym *Symbol) []Reference {
+	var refs []Reference
+	for _, r := range info.References {
+		if r.Symbol == sym {
+			refs = append(refs, r)
+		}
+	}
+	return refs
+}
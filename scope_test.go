@@ -0,0 +1,353 @@
+package pas_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/pas"
+)
+
+// references returns the references as "Name -> Kind Unit.Name" strings.
+func references(info *pas.Info) []string {
+	var refs []string
+	for _, r := range info.References {
+		s := r.Name + " -> "
+		if r.Symbol == nil {
+			s += "undeclared"
+		} else if r.Symbol.File == nil {
+			s += r.Symbol.Kind.String() + " System." + r.Symbol.Name
+		} else {
+			s += r.Symbol.Kind.String() + " " + r.Symbol.File.Name + "." + r.Symbol.Name
+		}
+		refs = append(refs, s)
+	}
+	return refs
+}
+
+func TestNamesAreResolvedLikeSameIdentifier(t *testing.T) {
+	info := pas.ResolveNames(parseUnits(t, `unit Main;
+interface
+type TMaſs = class end;
+var V: TMASS;
+implementation
+end.`))
+	check.Eq(t, references(info), []string{"TMASS -> class Main.TMaſs"})
+}
+
+func TestResolveNames(t *testing.T) {
+	info := pas.ResolveNames(parseUnits(t, `unit Main;
+interface
+uses A, B;
+type TMain = class(TBase)
+  F: TShared;
+  procedure P(X: a.tshared; Y: Integer; Z: TMain);
+end;
+var V: tmain;
+implementation
+uses C;
+type TImpl = class
+  [Info] G: TShared;
+  H: Unknown;
+end;
+end.`,
+		"unit A; interface type TShared = class end; type TBase = class end; implementation end.",
+		"unit B; interface type TShared = class end; implementation end.",
+		"unit C; interface type TShared = class end; type InfoAttribute = class end; implementation end.",
+	))
+	check.Eq(t, references(info), []string{
+		"TBase -> class A.TBase",
+		"TShared -> class B.TShared",
+		"a.tshared -> class A.TShared",
+		"Integer -> type System.Integer",
+		"TMain -> class Main.TMain",
+		"tmain -> class Main.TMain",
+		"Info -> class C.InfoAttribute",
+		"TShared -> class C.TShared",
+		"Unknown -> undeclared",
+	})
+}
+
+func TestResolveNamesLooksUpMembersInSuperClasses(t *testing.T) {
+	files := parseUnits(t, `unit U;
+interface
+type TBase = class
+  Count: Integer;
+end;
+type TDerived = class(TBase)
+  procedure P(A: Integer);
+end;
+type TCycle = class(TCycle)
+end;
+implementation
+end.`)
+	info := pas.ResolveNames(files)
+	intf := info.Scopes[0]
+	check.Eq(t, intf.Kind, pas.InterfaceScope)
+	check.Eq(t, intf.Lookup("TDerived.Count").Kind, pas.FieldSymbol)
+	check.Eq(t, intf.Lookup("U.TDerived.P").Kind, pas.MethodSymbol)
+	check.Eq(t, intf.Lookup("TCycle.Missing") == nil, true)
+
+	// The offset is inside the parameter list of P.
+	scope := info.ScopeAt(files[0], 108)
+	check.Eq(t, scope.Kind, pas.RoutineScope)
+	check.Eq(t, scope.Name, "P")
+	check.Eq(t, scope.Lookup("a").Kind, pas.ParameterSymbol)
+	check.Eq(t, scope.Lookup("count").Kind, pas.FieldSymbol)
+	check.Eq(t, scope.Parent.Kind, pas.ClassScope)
+
+	tbase := intf.Lookup("TBase")
+	check.Eq(t, len(info.ReferencesTo(tbase)), 1)
+
+	var members []string
+	for _, m := range intf.Lookup("TDerived").Scope.Members() {
+		members = append(members, m.Kind.String()+" "+m.Name)
+	}
+	check.Eq(t, members, []string{"method P", "field Count"})
+}
+
+func TestRecordMethodHeadersAreResolvedInTheRecord(t *testing.T) {
+	info := pas.ResolveNames(parseUnits(t, `unit U;
+interface
+type TPoint = record
+  function Add(const P: TPoint): TPoint;
+end;
+implementation
+function TPoint.Add(const P: TPoint): TPoint;
+begin
+end;
+end.`))
+	var scopes []string
+	for _, r := range info.References {
+		scopes = append(scopes, r.Scope.Kind.String()+" "+r.Scope.Name)
+	}
+	check.Eq(t, references(info)[2:], []string{
+		"TPoint -> record U.TPoint",
+		"TPoint -> record U.TPoint",
+	})
+	check.Eq(t, scopes[2:], []string{"class TPoint", "class TPoint"})
+}
+
+func TestReferencesToFieldInBodiesAndProperties(t *testing.T) {
+	code := `unit U;
+interface
+type TCounter = class
+  FCount: Integer;
+  procedure Increment;
+  property Count: Integer read FCount write FCount;
+end;
+implementation
+procedure TCounter.Increment;
+begin
+  FCount := FCount + 1;
+  Self.FCount := U.TCounter(Self).FCount;
+end;
+end.`
+	files := parseUnits(t, code)
+	info := pas.ResolveNames(files)
+	class := info.Scopes[0].Lookup("TCounter")
+	describe := func(refs []pas.Reference) []string {
+		var list []string
+		for _, r := range refs {
+			var node string
+			switch r.Node.(type) {
+			case pas.Property:
+				node = "property"
+			case pas.Routine:
+				node = "routine"
+			case pas.Variable:
+				node = "variable"
+			}
+			list = append(list, fmt.Sprintf("%s %d:%d %q in %s %s",
+				node, r.Pos.Line, r.Pos.Col, code[r.Pos.Offset:r.End.Offset],
+				r.Scope.Kind, r.Scope.Name))
+		}
+		return list
+	}
+	check.Eq(t, describe(info.ReferencesTo(class.Scope.Lookup("FCount"))), []string{
+		`property 6:32 "FCount" in class TCounter`,
+		`property 6:45 "FCount" in class TCounter`,
+		`routine 11:3 "FCount" in routine TCounter.Increment`,
+		`routine 11:13 "FCount" in routine TCounter.Increment`,
+		`routine 12:8 "FCount" in class TCounter`,
+		`routine 12:35 "FCount" in class TCounter`,
+	})
+	refs := info.ReferencesTo(class)
+	check.Eq(t, len(refs), 1)
+	check.Eq(t, refs[0].Name, "U.TCounter")
+	check.Eq(t, describe(refs), []string{
+		`routine 12:18 "U.TCounter" in routine TCounter.Increment`,
+	})
+	check.Eq(t, describe(info.ReferencesTo(info.System.Lookup("Integer"))), []string{
+		`variable 4:11 "Integer" in class TCounter`,
+		`property 6:19 "Integer" in class TCounter`,
+	})
+}
+
+func TestRoutineBodiesHaveScopesWithTheirLocalDeclarations(t *testing.T) {
+	code := `unit U;
+interface
+type TForm = class
+  Caption: string;
+  procedure Show(Modal: Boolean);
+end;
+implementation
+type TEdit = class
+  Text: string;
+end;
+procedure TForm.Show(Modal: Boolean);
+const Max = 10;
+var
+  I, J: Integer;
+  Edit: TEdit;
+  procedure Nested(K: Integer);
+  var L: TForm;
+  begin
+    { inside Nested }
+  end;
+begin
+  with Edit do
+    Text := { inside with } '';
+  { after with }
+end;
+end.`
+	files := parseUnits(t, code)
+	info := pas.ResolveNames(files)
+	at := func(marker string) *pas.Scope {
+		return info.ScopeAt(files[0], strings.Index(code, marker))
+	}
+
+	show := at("after with")
+	check.Eq(t, show.Kind, pas.RoutineScope)
+	check.Eq(t, show.Name, "TForm.Show")
+	var names []string
+	for _, sym := range show.Symbols {
+		names = append(names, sym.Kind.String()+" "+sym.Name)
+	}
+	check.Eq(t, names, []string{
+		"parameter Modal", "constant Max", "variable I", "variable J", "variable Edit",
+		"routine Nested",
+	})
+	check.Eq(t, show.Lookup("caption").Kind, pas.FieldSymbol)
+	check.Eq(t, show.Lookup("Text") == nil, true)
+
+	nested := at("inside Nested")
+	check.Eq(t, nested.Name, "Nested")
+	check.Eq(t, nested.Lookup("L").Kind, pas.VariableSymbol)
+	check.Eq(t, nested.Lookup("Edit").Kind, pas.VariableSymbol)
+	check.Eq(t, nested.Lookup("Caption").Kind, pas.FieldSymbol)
+
+	with := at("inside with")
+	check.Eq(t, with.Kind, pas.WithScope)
+	check.Eq(t, with.Name, "Edit")
+	check.Eq(t, with.Lookup("Text").Kind, pas.FieldSymbol)
+	check.Eq(t, with.Lookup("I").Kind, pas.VariableSymbol)
+
+	check.Eq(t, references(info)[3:], []string{
+		"Boolean -> type System.Boolean",
+		"Integer -> type System.Integer",
+		"Integer -> type System.Integer",
+		"TEdit -> class U.TEdit",
+		"Integer -> type System.Integer",
+		"TForm -> class U.TForm",
+		"Edit -> variable U.Edit",
+		"Text -> field U.Text",
+	})
+}
+
+func TestWithStatementsNestLikeTheirExpressions(t *testing.T) {
+	code := `unit U;
+interface
+type
+  TInner = class
+    Value: Integer;
+  end;
+type
+  TOuter = class
+    Inner: TInner;
+    Value: string;
+  end;
+implementation
+procedure P(Outer: TOuter; O: TObject);
+begin
+  with Outer, Inner do
+  begin
+    { inner }
+  end;
+  if True then
+    with TOuter(O) do Inner := { cast } nil else { else } Exit;
+end;
+end.`
+	files := parseUnits(t, code)
+	info := pas.ResolveNames(files)
+	at := func(marker string) *pas.Scope {
+		return info.ScopeAt(files[0], strings.Index(code, marker))
+	}
+	inner := at("inner")
+	check.Eq(t, inner.Name, "Inner")
+	check.Eq(t, inner.Parent.Name, "Outer")
+	check.Eq(t, inner.Lookup("Value").Decl.(pas.Variable).Type, "Integer")
+	check.Eq(t, inner.Parent.Lookup("Value").Decl.(pas.Variable).Type, "string")
+	check.Eq(t, at("cast").Name, "TOuter(O)")
+	check.Eq(t, at("cast").Lookup("Inner").Kind, pas.FieldSymbol)
+	check.Eq(t, at("else").Kind, pas.RoutineScope)
+}
+
+func TestRoutinesAreDeclaredWhereTheyAreFirstDeclared(t *testing.T) {
+	info := pas.ResolveNames(parseUnits(t, `unit U;
+interface
+procedure P(X: Integer); overload;
+procedure P(S: string); overload;
+implementation
+procedure Local; forward;
+procedure P(X: Integer);
+begin
+end;
+procedure P(S: string);
+begin
+end;
+procedure Local;
+begin
+end;
+end.`))
+	symbols := func(s *pas.Scope) []string {
+		var list []string
+		for _, sym := range s.Symbols {
+			list = append(list, sym.Kind.String()+" "+sym.Name)
+		}
+		return list
+	}
+	intf, impl := info.Scopes[0], info.Scopes[1]
+	check.Eq(t, symbols(intf), []string{"routine P", "routine P"})
+	check.Eq(t, symbols(impl), []string{"routine Local", "routine Local"})
+	check.Eq(t, intf.Symbols[0].Scope == nil, true)
+	check.Eq(t, impl.Symbols[0].Scope == nil, true)
+	check.Eq(t, impl.Symbols[1].Scope.Kind, pas.RoutineScope)
+	check.Eq(t, impl.Lookup("p") == intf.Symbols[0], true)
+	check.Eq(t, len(info.CheckTypes()), 0)
+}
+
+func TestResolveNamesTriesDefaultUnitScopeNames(t *testing.T) {
+	info := pas.ResolveNames(parseUnits(t,
+		"unit Main; interface uses Forms; var F: TForm; G: Forms.TForm; implementation end.",
+		"unit Vcl.Forms; interface type TForm = class end; implementation end.",
+	))
+	check.Eq(t, references(info), []string{
+		"TForm -> class Vcl.Forms.TForm",
+		"Forms.TForm -> class Vcl.Forms.TForm",
+	})
+}
+
+func TestProjectResolveNames(t *testing.T) {
+	dir := writeTestProject(t)
+	p, _ := pas.LoadProject(filepath.Join(dir, "P.dproj"))
+	info := p.ResolveNames()
+	main := info.Scopes[1]
+	check.Eq(t, main.Name, "Main")
+	check.Eq(t, main.Kind, pas.ImplementationScope)
+	check.Eq(t, main.Lookup("THelper").File.Name, "Helper")
+	check.Eq(t, main.Lookup("helper.THelper").File.Name, "Helper")
+	check.Eq(t, main.Lookup("Vcl.Dialogs.TOpenDialog") == nil, true)
+}
@@ -0,0 +1,326 @@
+package pas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// TagIndex is the list of declarations in a set of units, for jumping to
+// definitions in editors. It can be written as a tags file for Vim and other
+// editors that read the Universal Ctags format, as a TAGS file for Emacs or as
+// JSON.
+type TagIndex struct {
+	// Tags are in the order of the files and, within a file, in the order of
+	// the code.
+	Tags []Tag
+}
+
+// Tag is a declaration in a TagIndex.
+type Tag struct {
+	Name string
+	Kind TagKind
+	// Scope is the class, interface or record that declares a member or that
+	// a method implementation belongs to, e.g. "TFoo" with ScopeKind ClassTag.
+	// For other declarations, Scope is the unit with ScopeKind UnitTag. Units
+	// have no Scope.
+	Scope     string `json:",omitempty"`
+	ScopeKind TagKind
+	// Signature is the parameter list of methods and routines, e.g.
+	// "(const A: Integer; B: string)", or "()" if there are no parameters.
+	Signature string `json:",omitempty"`
+	// Type is the type of fields, properties, variables and typed constants
+	// and the result type of functions.
+	Type       string     `json:",omitempty"`
+	Visibility Visibility `json:",omitempty"`
+	// Pos is the start of the declaration, including its attributes.
+	Pos Position
+}
+
+// TagKind is the kind of declaration of a Tag.
+type TagKind int
+
+const (
+	UnitTag      TagKind = 0
+	ClassTag     TagKind = 1
+	InterfaceTag TagKind = 2
+	// MethodTag is used for both the declaration of a method in its class and
+	// for its implementation.
+	MethodTag   TagKind = 3
+	PropertyTag TagKind = 4
+	FieldTag    TagKind = 5
+	// RoutineTag is a function or procedure that is not a method.
+	RoutineTag  TagKind = 6
+	VariableTag TagKind = 7
+	ConstantTag TagKind = 8
+	RecordTag   TagKind = 9
+)
+
+func (k TagKind) String() string {
+	switch k {
+	case UnitTag:
+		return "unit"
+	case ClassTag:
+		return "class"
+	case InterfaceTag:
+		return "interface"
+	case MethodTag:
+		return "method"
+	case PropertyTag:
+		return "property"
+	case FieldTag:
+		return "field"
+	case RoutineTag:
+		return "routine"
+	case VariableTag:
+		return "variable"
+	case ConstantTag:
+		return "constant"
+	case RecordTag:
+		return "record"
+	}
+	return "unknown TagKind"
+}
+
+// NewTagIndex collects the tags of the files.
+func NewTagIndex(files []*File) *TagIndex {
+	x := &TagIndex{}
+	for _, f := range files {
+		x.addFile(f)
+	}
+	return x
+}
+
+// TagIndex collects the tags of all units of the project.
+func (p *Project) TagIndex() *TagIndex {
+	var files []*File
+	for _, u := range p.Units {
+		files = append(files, u.File)
+	}
+	return NewTagIndex(files)
+}
+
+func (x *TagIndex) addFile(f *File) {
+	x.add(Tag{Name: f.Name, Kind: UnitTag, Pos: f.Pos})
+	inUnit := func(t Tag) {
+		t.Scope, t.ScopeKind = f.Name, UnitTag
+		x.add(t)
+	}
+	classKinds := make(map[string]TagKind)
+	for _, section := range f.Sections {
+		for _, block := range section.Blocks {
+			switch b := block.(type) {
+			case TypeBlock:
+				for _, t := range b {
+					if c, ok := t.(Class); ok {
+						kind := classTagKind(c)
+						classKinds[FoldIdentifier(c.Name)] = kind
+						inUnit(Tag{Name: c.Name, Kind: kind, Pos: c.Pos})
+						x.addMembers(c, kind)
+					}
+				}
+			case VarBlock:
+				for _, v := range b {
+					inUnit(Tag{Name: v.Name, Kind: VariableTag, Type: v.Type, Pos: v.Pos})
+				}
+			case ConstBlock:
+				for _, c := range b {
+					inUnit(Tag{Name: c.Name, Kind: ConstantTag, Type: c.Type, Pos: c.Pos})
+				}
+			case Routine:
+				t := Tag{
+					Name:      b.Header.Name,
+					Kind:      RoutineTag,
+					Signature: signature(b.Header),
+					Type:      b.Header.Returns,
+					Pos:       b.Pos,
+				}
+				if b.ClassName == "" {
+					inUnit(t)
+				} else {
+					t.Kind = MethodTag
+					t.Scope, t.ScopeKind = b.ClassName, ClassTag
+					if kind, ok := classKinds[FoldIdentifier(b.ClassName)]; ok {
+						t.ScopeKind = kind
+					}
+					x.add(t)
+				}
+			}
+		}
+	}
+}
+
+func classTagKind(c Class) TagKind {
+	if c.IsInterface {
+		return InterfaceTag
+	}
+	if c.IsRecord {
+		return RecordTag
+	}
+	return ClassTag
+}
+
+func (x *TagIndex) addMembers(c Class, scopeKind TagKind) {
+	for _, section := range c.Sections {
+		for _, member := range section.Members {
+			t := Tag{Scope: c.Name, ScopeKind: scopeKind, Visibility: section.Visibility}
+			switch m := member.(type) {
+			case Variable:
+				t.Name, t.Kind, t.Type, t.Pos = m.Name, FieldTag, m.Type, m.Pos
+			case Function:
+				t.Name, t.Kind, t.Pos = m.Name, MethodTag, m.Pos
+				t.Signature, t.Type = signature(m), m.Returns
+			case Property:
+				t.Name, t.Kind, t.Type, t.Pos = m.Name, PropertyTag, m.Type, m.Pos
+			default:
+				continue
+			}
+			x.add(t)
+		}
+	}
+}
+
+func (x *TagIndex) add(t Tag) {
+	x.Tags = append(x.Tags, t)
+}
+
+// signature returns the parameter list of the function in parentheses.
+func signature(f Function) string {
+	params := make([]string, len(f.Parameters))
+	for i, p := range f.Parameters {
+		params[i] = parameterCode(p)
+	}
+	return "(" + strings.Join(params, "; ") + ")"
+}
+
+// WriteCtags writes the tags in the Universal Ctags format, sorted by name.
+// Tags are addressed by line number and have the extension fields kind, scope,
+// signature, typeref and access. The fields are separated by tabs, e.g. a
+// method is written as
+//
+//     Draw Shapes.pas 12;" kind:method class:TShape signature:(C: TCanvas) access:public
+//
+// The file names are the Filename of the tags' positions.
+func (x *TagIndex) WriteCtags(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("!_TAG_FILE_FORMAT\t2\t/extended format/\n")
+	b.WriteString("!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/\n")
+
+	tags := make([]Tag, len(x.Tags))
+	copy(tags, x.Tags)
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+	for _, t := range tags {
+		fmt.Fprintf(&b, "%s\t%s\t%d;\"\tkind:%s", t.Name, t.Pos.Filename, t.Pos.Line, t.Kind)
+		if t.Scope != "" {
+			fmt.Fprintf(&b, "\t%s:%s", t.ScopeKind, t.Scope)
+		}
+		if t.Signature != "" {
+			b.WriteString("\tsignature:" + t.Signature)
+		}
+		if t.Type != "" {
+			b.WriteString("\ttyperef:typename:" + t.Type)
+		}
+		if t.Visibility != DefaultPublished {
+			b.WriteString("\taccess:" + t.Visibility.String())
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteETags writes the tags in the Emacs TAGS format, one section per file.
+// The TAGS format contains the text of the line of each tag, so the files are
+// read again from their Filename. Their code is decoded like in
+// Options.ParseBytes, the line text is written as UTF-8 and the offsets are
+// byte offsets into the files as they are.
+func (x *TagIndex) WriteETags(w io.Writer) error {
+	var files []string
+	byFile := make(map[string][]Tag)
+	for _, t := range x.Tags {
+		name := t.Pos.Filename
+		if _, ok := byFile[name]; !ok {
+			files = append(files, name)
+		}
+		byFile[name] = append(byFile[name], t)
+	}
+
+	var b strings.Builder
+	for _, name := range files {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		code, encoding, err := Options{}.Decode(data)
+		if err != nil {
+			return err
+		}
+		byteOffsets, err := lineByteOffsets(code, encoding)
+		if err != nil {
+			return err
+		}
+		var section strings.Builder
+		for _, t := range byFile[name] {
+			if t.Pos.Offset > len(code) {
+				return errors.New(name + " changed after it was parsed")
+			}
+			lineStart := strings.LastIndexByte(code[:t.Pos.Offset], '\n') + 1
+			line := code[lineStart:]
+			if end := strings.IndexByte(line, '\n'); end != -1 {
+				line = line[:end]
+			}
+			line = strings.TrimRight(line, "\r")
+			fmt.Fprintf(&section, "%s\x7f%s\x01%d,%d\n",
+				line, t.Name, t.Pos.Line, byteOffsets[lineStart])
+		}
+		fmt.Fprintf(&b, "\x0c\n%s,%d\n%s", name, section.Len(), section.String())
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// lineByteOffsets maps the offsets of the line starts in the decoded code to
+// their offsets in the file, which has the encoding. Emacs expects the offsets
+// in the file, they differ from the decoded ones after a byte order mark or a
+// character that has a different length in the encoding.
+func lineByteOffsets(code string, encoding Encoding) (map[int]int, error) {
+	bom, err := Options{}.Encode("", encoding)
+	if err != nil {
+		return nil, err
+	}
+	offsets := make(map[int]int)
+	offset := len(bom)
+	start := 0
+	for {
+		offsets[start] = offset
+		end := strings.IndexByte(code[start:], '\n')
+		if end == -1 {
+			return offsets, nil
+		}
+		end += start + 1
+		line, err := Options{}.Encode(code[start:end], encoding)
+		if err != nil {
+			return nil, err
+		}
+		offset += len(line) - len(bom)
+		start = end
+	}
+}
+
+// WriteJSON writes the tags as a JSON array, e.g.
+//
+//     [{"Name":"TShape","Kind":"class","Scope":"Shapes","ScopeKind":"unit",
+//       "Pos":{"Filename":"Shapes.pas","Offset":30,"Line":4,"Col":3}}]
+func (x *TagIndex) WriteJSON(w io.Writer) error {
+	tags := x.Tags
+	if tags == nil {
+		tags = []Tag{}
+	}
+	return json.NewEncoder(w).Encode(tags)
+}
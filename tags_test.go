@@ -0,0 +1,175 @@
+package pas_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/pas"
+)
+
+const tagsCode = `unit Shapes;
+interface
+type
+  IDrawable = interface
+    procedure Draw(const Scale: Double);
+  end;
+type
+  TShape = class(TInterfacedObject, IDrawable)
+  private
+    FName: string;
+  public
+    procedure Draw(const Scale: Double);
+    function Area: Double; virtual;
+    property Name: string read FName;
+  end;
+var
+  Count: Integer;
+procedure Reset;
+const
+  MaxShapes = 100;
+type
+  TSize = record
+    Width: Double;
+  end;
+implementation
+procedure TShape.Draw(const Scale: Double);
+begin
+end;
+function TShape.Area: Double;
+begin
+end;
+procedure Reset;
+begin
+end;
+end.
+`
+
+func parseTagsUnit(t *testing.T) *pas.File {
+	path := filepath.Join(t.TempDir(), "Shapes.pas")
+	if err := os.WriteFile(path, []byte(tagsCode), 0666); err != nil {
+		t.Fatal(err)
+	}
+	f, err := pas.ParseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestTagIndexCoversAllDeclarations(t *testing.T) {
+	index := pas.NewTagIndex(parseUnits(t, tagsCode))
+	var tags []string
+	for _, tag := range index.Tags {
+		s := tag.Kind.String() + " " + tag.Name
+		if tag.Scope != "" {
+			s += " " + tag.ScopeKind.String() + ":" + tag.Scope
+		}
+		if tag.Signature != "" {
+			s += " " + tag.Signature
+		}
+		if tag.Type != "" {
+			s += " : " + tag.Type
+		}
+		if tag.Visibility != pas.DefaultPublished {
+			s += " " + tag.Visibility.String()
+		}
+		tags = append(tags, s)
+	}
+	check.Eq(t, tags, []string{
+		"unit Shapes",
+		"interface IDrawable unit:Shapes",
+		"method Draw interface:IDrawable (const Scale: Double)",
+		"class TShape unit:Shapes",
+		"field FName class:TShape : string private",
+		"method Draw class:TShape (const Scale: Double) public",
+		"method Area class:TShape () : Double public",
+		"property Name class:TShape : string public",
+		"variable Count unit:Shapes : Integer",
+		"routine Reset unit:Shapes ()",
+		"constant MaxShapes unit:Shapes",
+		"record TSize unit:Shapes",
+		"field Width record:TSize : Double",
+		"method Draw class:TShape (const Scale: Double)",
+		"method Area class:TShape () : Double",
+		"routine Reset unit:Shapes ()",
+	})
+	check.Eq(t, index.Tags[3].Pos.Line, 8)
+}
+
+func TestWriteCtags(t *testing.T) {
+	f := parseTagsUnit(t)
+	path := f.Pos.Filename
+	var buf bytes.Buffer
+	check.Eq(t, pas.NewTagIndex([]*pas.File{f}).WriteCtags(&buf), nil)
+	check.Eq(t, buf.String(), "!_TAG_FILE_FORMAT\t2\t/extended format/\n"+
+		"!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/\n"+
+		"Area\t"+path+"\t13;\"\tkind:method\tclass:TShape\tsignature:()\ttyperef:typename:Double\taccess:public\n"+
+		"Area\t"+path+"\t29;\"\tkind:method\tclass:TShape\tsignature:()\ttyperef:typename:Double\n"+
+		"Count\t"+path+"\t17;\"\tkind:variable\tunit:Shapes\ttyperef:typename:Integer\n"+
+		"Draw\t"+path+"\t5;\"\tkind:method\tinterface:IDrawable\tsignature:(const Scale: Double)\n"+
+		"Draw\t"+path+"\t12;\"\tkind:method\tclass:TShape\tsignature:(const Scale: Double)\taccess:public\n"+
+		"Draw\t"+path+"\t26;\"\tkind:method\tclass:TShape\tsignature:(const Scale: Double)\n"+
+		"FName\t"+path+"\t10;\"\tkind:field\tclass:TShape\ttyperef:typename:string\taccess:private\n"+
+		"IDrawable\t"+path+"\t4;\"\tkind:interface\tunit:Shapes\n"+
+		"MaxShapes\t"+path+"\t20;\"\tkind:constant\tunit:Shapes\n"+
+		"Name\t"+path+"\t14;\"\tkind:property\tclass:TShape\ttyperef:typename:string\taccess:public\n"+
+		"Reset\t"+path+"\t18;\"\tkind:routine\tunit:Shapes\tsignature:()\n"+
+		"Reset\t"+path+"\t32;\"\tkind:routine\tunit:Shapes\tsignature:()\n"+
+		"Shapes\t"+path+"\t1;\"\tkind:unit\n"+
+		"TShape\t"+path+"\t8;\"\tkind:class\tunit:Shapes\n"+
+		"TSize\t"+path+"\t22;\"\tkind:record\tunit:Shapes\n"+
+		"Width\t"+path+"\t23;\"\tkind:field\trecord:TSize\ttyperef:typename:Double\n",
+	)
+}
+
+func TestWriteETags(t *testing.T) {
+	f := parseTagsUnit(t)
+	f.Sections = nil
+	var buf bytes.Buffer
+	check.Eq(t, pas.NewTagIndex([]*pas.File{f}).WriteETags(&buf), nil)
+	section := "unit Shapes;\x7fShapes\x011,0\n"
+	check.Eq(t, buf.String(), "\x0c\n"+f.Pos.Filename+","+strconv.Itoa(len(section))+"\n"+section)
+
+	index := pas.NewTagIndex([]*pas.File{parseTagsUnit(t)})
+	buf.Reset()
+	check.Eq(t, index.WriteETags(&buf), nil)
+	offset := strings.Index(tagsCode, "  TShape")
+	check.Eq(t, strings.Contains(buf.String(),
+		"  TShape = class(TInterfacedObject, IDrawable)\x7fTShape\x018,"+strconv.Itoa(offset)+"\n"), true)
+}
+
+func TestWriteETagsCountsTheBytesOfTheFile(t *testing.T) {
+	code := strings.Replace(tagsCode, "type\n  TShape", "type\n  // Größe\n  TShape", 1)
+	for _, encoding := range []pas.Encoding{pas.UTF8, pas.UTF8BOM, pas.UTF16LE, pas.ANSI} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			data, err := pas.Options{}.Encode(code, encoding)
+			check.Eq(t, err, nil)
+			path := filepath.Join(t.TempDir(), "Shapes.pas")
+			check.Eq(t, os.WriteFile(path, data, 0666), nil)
+			f, err := pas.ParseFile(path)
+			check.Eq(t, err, nil)
+
+			var buf bytes.Buffer
+			check.Eq(t, pas.NewTagIndex([]*pas.File{f}).WriteETags(&buf), nil)
+			before, err := pas.Options{}.Encode(code[:strings.Index(code, "  TShape")], encoding)
+			check.Eq(t, err, nil)
+			check.Eq(t, strings.Contains(buf.String(),
+				"\x7fTShape\x019,"+strconv.Itoa(len(before))+"\n"), true)
+		})
+	}
+}
+
+func TestWriteTagsAsJSON(t *testing.T) {
+	index := pas.NewTagIndex(parseUnits(t, tagsCode))
+	var buf bytes.Buffer
+	check.Eq(t, index.WriteJSON(&buf), nil)
+	var tags []pas.Tag
+	check.Eq(t, json.Unmarshal(buf.Bytes(), &tags), nil)
+	check.Eq(t, tags, index.Tags)
+}
@@ -8,10 +8,59 @@ import (
 type token struct {
 	tokenType tokenType
 	text      string
+	filename  string
+	// offset is the byte offset of the token in the UTF-8 encoded source.
+	offset int
 	// line and col both start at 1.
 	line, col int
 }
 
+func (t token) pos() Position {
+	return Position{
+		Filename: t.filename,
+		Offset:   t.offset,
+		Line:     t.line,
+		Col:      t.col,
+	}
+}
+
+// end returns the position right after the token. Only comments and white
+// space can span multiple lines.
+func (t token) end() Position {
+	end := t.pos()
+	for _, r := range t.text {
+		end.Offset += utf8.RuneLen(r)
+		if r == '\n' {
+			end.Line++
+			end.Col = 1
+		} else {
+			end.Col++
+		}
+	}
+	return end
+}
+
+// kind is the exported TokenKind of the token.
+func (t token) kind() TokenKind {
+	switch t.tokenType {
+	case tokenIllegal:
+		return IllegalToken
+	case tokenEOF:
+		return EOFToken
+	case tokenWord:
+		return WordToken
+	case tokenWhiteSpace:
+		return WhiteSpaceToken
+	case tokenComment:
+		return CommentToken
+	case tokenString:
+		return StringToken
+	case tokenNumber:
+		return NumberToken
+	}
+	return SymbolToken
+}
+
 // tokenType is a rune because single characters are used directly as their
 // token type, e.g. ',' '+' or ':'.
 type tokenType rune
@@ -22,21 +71,28 @@ const (
 	tokenWord       tokenType = 256
 	tokenWhiteSpace tokenType = 257
 	tokenComment    tokenType = 258
+	tokenString     tokenType = 259
+	tokenNumber     tokenType = 260
 )
 
 func (t token) String() string {
+	return fmt.Sprintf("%s at %d:%d", t.description(), t.line, t.col)
+}
+
+// description is the token's type and text without its position.
+func (t token) description() string {
 	if t.tokenType == tokenComment {
 		text := t.text
 		const max = 20
 		if utf8.RuneCountInString(text) > max {
 			text = string([]rune(text)[:max]) + "..."
 		}
-		return fmt.Sprintf("%v %q at %d:%d", t.tokenType, text, t.line, t.col)
+		return fmt.Sprintf("%v %q", t.tokenType, text)
 	}
 	if string(t.tokenType) == t.text || t.text == "" {
-		return fmt.Sprintf("%v at %d:%d", t.tokenType, t.line, t.col)
+		return t.tokenType.String()
 	}
-	return fmt.Sprintf("%v %q at %d:%d", t.tokenType, t.text, t.line, t.col)
+	return fmt.Sprintf("%v %q", t.tokenType, t.text)
 }
 
 func (t tokenType) String() string {
@@ -51,6 +107,10 @@ func (t tokenType) String() string {
 		return "white space"
 	case tokenComment:
 		return "comment"
+	case tokenString:
+		return "string"
+	case tokenNumber:
+		return "number"
 	default:
 		if 0 <= t && t <= 127 {
 			return fmt.Sprintf("token %q", string(t))
@@ -1,6 +1,9 @@
 package pas
 
-import "unicode"
+import (
+	"unicode"
+	"unicode/utf8"
+)
 
 func newTokenizer(code []rune) tokenizer {
 	return tokenizer{
@@ -11,62 +14,103 @@ func newTokenizer(code []rune) tokenizer {
 }
 
 type tokenizer struct {
-	code []rune
-	cur  int
-	line int
-	col  int
+	// filename is stored in all tokens, see Position.Filename.
+	filename string
+	code     []rune
+	cur      int
+	// offset is the byte offset of code[cur] in the UTF-8 encoded code.
+	offset int
+	line   int
+	col    int
 }
 
 func (t *tokenizer) next() token {
 	haveType := tokenIllegal
 	start := t.cur
-	line, col := t.line, t.col
+	offset, line, col := t.offset, t.line, t.col
 
-	digit := func(r rune) bool {
-		return '0' <= r && r <= '9'
-	}
-
-	r := t.currentRune()
-	switch r {
-	case 0:
+	// A NUL character is not the end, it is an illegal token like all other
+	// control characters.
+	if t.atEnd() {
 		return token{
 			tokenType: tokenEOF,
+			filename:  t.filename,
+			offset:    offset,
 			line:      line,
 			col:       col,
 		}
-	case ';', ':', '.', ',', '=', '(', ')', '[', ']':
+	}
+	r := t.currentRune()
+	switch r {
+	case ';', ':', '.', ',', '=', ')', '[', ']',
+		'+', '-', '*', '<', '>', '@', '^':
 		t.nextRune()
 		haveType = tokenType(r)
+	case '(':
+		haveType = tokenType(r)
+		if t.nextRune() == '*' {
+			t.nextRune()
+			for {
+				r := t.currentRune()
+				if t.atEnd() {
+					break
+				}
+				t.nextRune()
+				if r == '*' && t.currentRune() == ')' {
+					t.nextRune()
+					break
+				}
+			}
+			haveType = tokenComment
+		}
 	case '{':
 		for {
 			r := t.nextRune()
-			if r == '}' || r == 0 {
+			if r == '}' || t.atEnd() {
 				break
 			}
 		}
 		t.nextRune()
 		haveType = tokenComment
 	case '/':
+		haveType = tokenType(r)
 		if t.nextRune() == '/' {
 			for {
 				r := t.nextRune()
-				if r == '\n' || r == 0 {
+				if r == '\n' || t.atEnd() {
 					break
 				}
 			}
 			t.nextRune()
 			haveType = tokenComment
 		}
+	case '\'', '#':
+		haveType = t.string()
+	case '$':
+		haveType = tokenIllegal
+		if isHexDigit(t.nextRune()) {
+			for isHexDigit(t.nextRune()) {
+			}
+			haveType = tokenNumber
+		}
+	case '&':
+		// '&' escapes identifiers, e.g. &Type, it is part of the word.
+		haveType = tokenIllegal
+		if n := t.nextRune(); n == '_' || unicode.IsLetter(n) {
+			for t.isWordRune(t.nextRune()) {
+			}
+			haveType = tokenWord
+		}
 	default:
 		if unicode.IsSpace(r) {
 			for unicode.IsSpace(t.nextRune()) {
 			}
 			haveType = tokenWhiteSpace
+		} else if '0' <= r && r <= '9' {
+			t.number()
+			haveType = tokenNumber
 		} else if r == '_' || unicode.IsLetter(r) {
-			word := func(r rune) bool {
-				return r == '_' || unicode.IsLetter(r) || digit(r)
-			}
-			for word(t.nextRune()) {
+			for t.isWordRune(t.nextRune()) {
 			}
 			haveType = tokenWord
 		} else {
@@ -77,11 +121,100 @@ func (t *tokenizer) next() token {
 	return token{
 		tokenType: haveType,
 		text:      string(t.code[start:t.cur]),
+		filename:  t.filename,
+		offset:    offset,
 		line:      line,
 		col:       col,
 	}
 }
 
+// string reads a string literal, which consists of quoted strings and control
+// characters like #13#10. In quoted strings, two quotes stand for one. It
+// returns tokenIllegal if a quoted string is not closed before the end of the
+// line.
+func (t *tokenizer) string() tokenType {
+	for {
+		switch t.currentRune() {
+		case '\'':
+			for {
+				r := t.nextRune()
+				if r == '\n' || t.atEnd() {
+					return tokenIllegal
+				}
+				if r == '\'' {
+					if t.nextRune() != '\'' {
+						break
+					}
+				}
+			}
+		case '#':
+			r := t.nextRune()
+			if r == '$' && isHexDigit(t.nextRune()) {
+				for isHexDigit(t.nextRune()) {
+				}
+			} else if '0' <= r && r <= '9' {
+				for isDigit(t.nextRune()) {
+				}
+			} else {
+				return tokenIllegal
+			}
+		default:
+			return tokenString
+		}
+	}
+}
+
+// number reads a decimal number like 1, 1.5 or 1.5e-3. A '.' must be followed
+// by a digit to be part of the number, 1..2 is a range.
+func (t *tokenizer) number() {
+	for isDigit(t.nextRune()) {
+	}
+	if t.currentRune() == '.' && isDigit(t.peekRune()) {
+		t.nextRune()
+		for isDigit(t.nextRune()) {
+		}
+	}
+	if r := t.currentRune(); r == 'e' || r == 'E' {
+		n := t.peekRune()
+		if isDigit(n) ||
+			(n == '+' || n == '-') && t.cur+2 < len(t.code) &&
+				isDigit(t.code[t.cur+2]) {
+			t.nextRune()
+			for isDigit(t.nextRune()) {
+			}
+		}
+	}
+}
+
+func isDigit(r rune) bool {
+	return '0' <= r && r <= '9'
+}
+
+func isHexDigit(r rune) bool {
+	return isDigit(r) || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F'
+}
+
+// isWordRune reports whether r can be part of an identifier after its first
+// character. Like Delphi, this allows Unicode letters, digits and combining
+// marks, e.g. in "Größe".
+func (t *tokenizer) isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) ||
+		unicode.IsMark(r)
+}
+
+// atEnd reports whether all runes were read. currentRune is 0 then.
+func (t *tokenizer) atEnd() bool {
+	return t.cur >= len(t.code)
+}
+
+// peekRune returns the rune after the current rune.
+func (t *tokenizer) peekRune() rune {
+	if t.cur+1 < len(t.code) {
+		return t.code[t.cur+1]
+	}
+	return 0
+}
+
 func (t *tokenizer) currentRune() rune {
 	if t.cur < len(t.code) {
 		return t.code[t.cur]
@@ -97,6 +230,7 @@ func (t *tokenizer) nextRune() rune {
 		} else {
 			t.col++
 		}
+		t.offset += utf8.RuneLen(t.code[t.cur])
 		t.cur++
 	}
 	return t.currentRune()
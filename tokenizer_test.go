@@ -28,16 +28,87 @@ func TestTokenizeComments(t *testing.T) {
 	checkTokens(t,
 		`{this is a
 comment} {another}//and a line comment
+(*old style*)(**)(*)*)(
 //plus a line comment just before EOF`,
 		tok(tokenComment, "{this is a\ncomment}"),
 		tok(tokenWhiteSpace, " "),
 		tok(tokenComment, "{another}"),
 		tok(tokenComment, "//and a line comment\n"),
+		tok(tokenComment, "(*old style*)"),
+		tok(tokenComment, "(**)"),
+		tok(tokenComment, "(*)*)"),
+		tok('(', "("),
+		tok(tokenWhiteSpace, "\n"),
 		tok(tokenComment, "//plus a line comment just before EOF"),
 		tok(tokenEOF, ""),
 	)
 }
 
+func TestTokenizeEscapedIdentifiers(t *testing.T) {
+	checkTokens(t,
+		`&Type &_1 & &1`,
+		tok(tokenWord, "&Type"),
+		tok(tokenWhiteSpace, " "),
+		tok(tokenWord, "&_1"),
+		tok(tokenWhiteSpace, " "),
+		tok(tokenIllegal, "&"),
+		tok(tokenWhiteSpace, " "),
+		tok(tokenIllegal, "&"),
+		tok(tokenNumber, "1"),
+		tok(tokenEOF, ""),
+	)
+}
+
+func TestTokenizeUnicodeIdentifiers(t *testing.T) {
+	checkTokens(t,
+		"Größe Fläche x\u0301 a١٢ _٣ ٣",
+		tok(tokenWord, "Größe"),
+		tok(tokenWhiteSpace, " "),
+		tok(tokenWord, "Fläche"),
+		tok(tokenWhiteSpace, " "),
+		tok(tokenWord, "x\u0301"),
+		tok(tokenWhiteSpace, " "),
+		tok(tokenWord, "a١٢"),
+		tok(tokenWhiteSpace, " "),
+		tok(tokenWord, "_٣"),
+		tok(tokenWhiteSpace, " "),
+		tok(tokenIllegal, "٣"),
+		tok(tokenEOF, ""),
+	)
+}
+
+func TestTokenizeLiteralsAndOperators(t *testing.T) {
+	checkTokens(t,
+		`'a''b'#13#$0A'c' '' 1 1.5e-3 $fF 1..2 +-*/<>@^ 'open`,
+		tok(tokenString, `'a''b'#13#$0A'c'`),
+		tok(tokenWhiteSpace, " "),
+		tok(tokenString, `''`),
+		tok(tokenWhiteSpace, " "),
+		tok(tokenNumber, "1"),
+		tok(tokenWhiteSpace, " "),
+		tok(tokenNumber, "1.5e-3"),
+		tok(tokenWhiteSpace, " "),
+		tok(tokenNumber, "$fF"),
+		tok(tokenWhiteSpace, " "),
+		tok(tokenNumber, "1"),
+		tok('.', "."),
+		tok('.', "."),
+		tok(tokenNumber, "2"),
+		tok(tokenWhiteSpace, " "),
+		tok('+', "+"),
+		tok('-', "-"),
+		tok('*', "*"),
+		tok('/', "/"),
+		tok('<', "<"),
+		tok('>', ">"),
+		tok('@', "@"),
+		tok('^', "^"),
+		tok(tokenWhiteSpace, " "),
+		tok(tokenIllegal, "'open"),
+		tok(tokenEOF, ""),
+	)
+}
+
 func tok(typ tokenType, text string) token {
 	return token{tokenType: typ, text: text}
 }
@@ -0,0 +1,178 @@
+package pas
+
+import "sort"
+
+// TypeError is a semantic error in the declarations or in a routine body, e.g.
+// an undeclared type.
+type TypeError struct {
+	// Pos is the start of the declaration that contains the error or, in a
+	// routine body, of the expression or statement.
+	Pos Position
+	Msg string
+}
+
+func (e *TypeError) Error() string {
+	return e.Msg + " at " + e.Pos.String()
+}
+
+// predeclaredClasses are the predeclared types that classes can inherit from.
+var predeclaredClasses = map[string]bool{
+	"tobject":           true,
+	"tinterfacedobject": true,
+	"tcustomattribute":  true,
+	"iinterface":        true,
+	"iunknown":          true,
+}
+
+// CheckTypes checks the declarations and routine bodies of the resolved files
+// and returns the errors, sorted by file and position. It reports:
+//
+//     - undeclared identifiers
+//     - identifiers that are used as types but are not types
+//     - super classes and attributes that are not classes
+//     - classes that inherit from themselves
+//     - identifiers that are declared twice in the same scope
+//     - assignments of incompatible types and to constants
+//     - conditions of if, while and repeat statements that are not Boolean
+//     - calls with too few or too many arguments, with arguments of
+//       incompatible types or with arguments for var and out parameters that
+//       are not variables, and calls that match none of the overloads
+//
+// A name that is not found is only reported as undeclared if all units that
+// the scope uses were resolved, otherwise it might be declared in one of the
+// missing units, e.g. in an RTL unit. Methods and routines of the same name are
+// overloads or forward declarations and no error.
+//
+// The expressions in bodies are typed as far as the types are known. The
+// simple types like Integer, string or Boolean and the classes, interfaces and
+// records of the files are known, other types, e.g. enumerations, and
+// overloaded operators are not checked. Parameters with default values can be
+// left out in calls. Bodies with local types or labels are not checked.
+func (info *Info) CheckTypes() []*TypeError {
+	// Errors are sorted by the index of their file in the Scopes and by their
+	// offset.
+	files := make(map[*File]int)
+	for _, s := range info.Scopes {
+		if _, ok := files[s.File]; !ok {
+			files[s.File] = len(files)
+		}
+	}
+	type fileError struct {
+		file int
+		err  *TypeError
+	}
+	var errs []fileError
+	var file *File
+	report := func(n Node, msg string) {
+		pos, _ := n.Span()
+		errs = append(errs, fileError{file: files[file], err: &TypeError{Pos: pos, Msg: msg}})
+	}
+
+	for _, r := range info.References {
+		if r.value {
+			// The names in bodies are checked with the bodies below, the
+			// property accessors are not checked.
+			continue
+		}
+		file = r.Scope.File
+		sym := r.Symbol
+		if sym == nil {
+			if !r.Scope.hasUnresolvedUses() {
+				report(r.Node, `undeclared identifier "`+r.Name+`"`)
+			}
+			continue
+		}
+		switch n := r.Node.(type) {
+		case Attribute:
+			if sym.Kind != ClassSymbol {
+				report(n, `attribute "`+r.Name+`" is not a class`)
+			}
+		case Class:
+			if sym.Kind != ClassSymbol && sym.Kind != InterfaceSymbol &&
+				!(sym.Kind == TypeSymbol && predeclaredClasses[FoldIdentifier(sym.Name)]) {
+				report(n, `super class "`+r.Name+`" of "`+n.Name+`" is not a class`)
+			}
+		default:
+			if sym.Kind != ClassSymbol && sym.Kind != InterfaceSymbol &&
+				sym.Kind != RecordSymbol && sym.Kind != TypeSymbol {
+				report(n, `"`+r.Name+`" is not a type but a `+sym.Kind.String())
+			}
+		}
+	}
+
+	var checkScope func(s *Scope)
+	checkScope = func(s *Scope) {
+		seen := make(map[string]*Symbol)
+		for _, sym := range s.Symbols {
+			name := FoldIdentifier(sym.Name)
+			first := seen[name]
+			if first == nil && s.Kind == ImplementationScope && s.Parent != nil {
+				first = s.Parent.names[name]
+			}
+			if first != nil && !(first.Kind == MethodSymbol && sym.Kind == MethodSymbol) &&
+				!(first.Kind == RoutineSymbol && sym.Kind == RoutineSymbol) {
+				report(sym.Decl, `"`+sym.Name+`" is declared twice`)
+			}
+			if first == nil {
+				seen[name] = sym
+			}
+			if (sym.Kind == ClassSymbol || sym.Kind == InterfaceSymbol) &&
+				inheritsFrom(sym.Scope, sym.Scope, nil) {
+				report(sym.Decl, sym.Kind.String()+` "`+sym.Name+`" inherits from itself`)
+			}
+		}
+		for _, err := range s.errors {
+			errs = append(errs, fileError{file: files[file], err: err})
+		}
+		for _, c := range s.Children {
+			checkScope(c)
+		}
+	}
+	for _, s := range info.Scopes {
+		file = s.File
+		checkScope(s)
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].file != errs[j].file {
+			return errs[i].file < errs[j].file
+		}
+		return errs[i].err.Pos.Offset < errs[j].err.Pos.Offset
+	})
+	list := make([]*TypeError, len(errs))
+	for i := range errs {
+		list[i] = errs[i].err
+	}
+	return list
+}
+
+// inheritsFrom reports whether class has ancestor among its super classes,
+// directly or indirectly.
+func inheritsFrom(class, ancestor *Scope, visited map[*Scope]bool) bool {
+	if visited == nil {
+		visited = make(map[*Scope]bool)
+	}
+	visited[class] = true
+	for _, base := range class.bases {
+		if base == ancestor {
+			return true
+		}
+		if !visited[base] && inheritsFrom(base, ancestor, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasUnresolvedUses reports whether the scope or one of its parents uses a unit
+// that was not found.
+func (s *Scope) hasUnresolvedUses() bool {
+	for scope := s; scope != nil; scope = scope.Parent {
+		for _, u := range scope.uses {
+			if u.scope == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
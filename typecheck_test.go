@@ -0,0 +1,220 @@
+package pas_test
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/pas"
+)
+
+func typeErrors(t *testing.T, units ...string) []string {
+	var errs []string
+	for _, err := range pas.ResolveNames(parseUnits(t, units...)).CheckTypes() {
+		errs = append(errs, err.Error())
+	}
+	return errs
+}
+
+func TestCheckTypesReportsDeclarationErrors(t *testing.T) {
+	errs := typeErrors(t, `unit U;
+interface
+type TA = class(TB)
+  F: Unknown;
+  procedure P(X: F);
+  procedure P(X, X: Integer);
+  F: Integer;
+end;
+type TB = class(TA)
+end;
+type TC = class(Integer)
+  [V] G: string;
+end;
+var V: TObject;
+const C: TA = nil;
+const D: C = nil;
+implementation
+var V: Integer;
+end.`)
+	check.Eq(t, errs, []string{
+		`class "TA" inherits from itself at 3:6`,
+		`undeclared identifier "Unknown" at 4:3`,
+		`"F" is not a type but a field at 5:15`,
+		`"X" is declared twice at 6:15`,
+		`"F" is declared twice at 7:3`,
+		`class "TB" inherits from itself at 9:6`,
+		`super class "Integer" of "TC" is not a class at 11:6`,
+		`attribute "V" is not a class at 12:4`,
+		`"C" is not a type but a constant at 16:7`,
+		`"V" is declared twice at 18:5`,
+	})
+}
+
+func TestCheckTypesAcceptsValidDeclarations(t *testing.T) {
+	errs := typeErrors(t,
+		`unit U; interface uses A;
+type TU = class(TBase)
+  [Info] F: string;
+  P: A.TPoint;
+  function G(const X: TObject): A.TBase;
+end;
+implementation end.`,
+		`unit A; interface
+type TBase = class(TInterfacedObject, IInterface) end;
+type InfoAttribute = class(TCustomAttribute) end;
+type TPoint = record X: Integer; end;
+implementation end.`,
+	)
+	check.Eq(t, len(errs), 0)
+}
+
+func TestCheckTypesIgnoresNamesFromUnknownUnits(t *testing.T) {
+	errs := typeErrors(t, "unit U; interface uses Classes; var L: TStringList; implementation end.")
+	check.Eq(t, len(errs), 0)
+}
+
+func TestCheckTypesReportsErrorsInRoutineBodies(t *testing.T) {
+	errs := typeErrors(t, `unit U;
+interface
+type TA = class
+  F: Integer;
+  property P: Integer read F;
+  procedure M(var X: Integer; const S: string = '');
+  procedure O(X: Integer); overload;
+  procedure O(X: string); overload;
+end;
+type TB = class end;
+const Max = 10;
+procedure R(A: Integer; out B: Integer);
+implementation
+procedure R(A: Integer; out B: Integer);
+var I: Integer; S: string; O: TA;
+begin
+  I := 'text';
+  S := 1.5;
+  Max := 2;
+  O := TB.Create;
+  if I then Exit;
+  R(1);
+  R(1, 2, 3);
+  R('a', I);
+  R(1, 2);
+  O.M(I, 'x', 3);
+  O.M(Max);
+  O.O(nil);
+  O.P := 3;
+  O.G;
+  Unknown := 1;
+end;
+procedure TA.M(var X: Integer; const S: string);
+begin
+  S := '';
+  Y := X;
+end;
+procedure TA.O(X: Integer); begin end;
+procedure TA.O(X: string); begin end;
+end.`)
+	check.Eq(t, errs, []string{
+		`incompatible types "Integer" and "string" at 17:3`,
+		`incompatible types "string" and "Extended" at 18:3`,
+		`left side cannot be assigned to at 19:3`,
+		`incompatible types "TA" and "TB" at 20:3`,
+		`condition of type "Integer" is not Boolean at 21:6`,
+		`not enough actual parameters for "R" at 22:3`,
+		`too many actual parameters for "R" at 23:3`,
+		`incompatible types "Integer" and "Char" at 24:5`,
+		`argument for the out parameter "B" must be a variable at 25:8`,
+		`too many actual parameters for "M" at 26:5`,
+		`argument for the var parameter "X" must be a variable at 27:7`,
+		`no overloaded version of "O" can be called with these arguments at 28:5`,
+		`left side cannot be assigned to at 29:3`,
+		`undeclared identifier "G" at 30:5`,
+		`undeclared identifier "Unknown" at 31:3`,
+		`left side cannot be assigned to at 35:3`,
+		`undeclared identifier "Y" at 36:3`,
+	})
+}
+
+func TestCheckTypesAcceptsValidRoutineBodies(t *testing.T) {
+	errs := typeErrors(t, `unit U;
+interface
+type IShape = interface
+  function Area: Double;
+end;
+type TShape = class(TInterfacedObject, IShape)
+  FName: string;
+  function Area: Double; virtual;
+  property Name: string read FName write FName;
+  constructor Create(const Name: string; Scale: Integer = 1);
+end;
+type TSquare = class(TShape)
+  Side: Double;
+  function Area: Double; override;
+end;
+type TPoint = record X: Integer; Y: Integer; end;
+const Greeting = 'Hello';
+procedure Swap(var A, B: Integer);
+function Sum(A: Integer; B: Integer = 0): Integer; overload;
+function Sum(const A, B: string): string; overload;
+implementation
+procedure Swap(var A, B: Integer);
+var T: Integer;
+begin
+  T := A; A := B; B := T;
+end;
+function Sum(A: Integer; B: Integer): Integer;
+begin
+  Result := A + B;
+end;
+function Sum(const A, B: string): string;
+begin
+  Result := A + ' ' + B;
+end;
+constructor TShape.Create(const Name: string; Scale: Integer);
+begin
+  inherited Create;
+  FName := Name + Greeting[1];
+end;
+function TShape.Area: Double;
+begin
+  Result := 0;
+end;
+function TSquare.Area: Double;
+  function Squared(X: Double): Double;
+  begin
+    Result := X * X;
+  end;
+var
+  I, J: Integer;
+  S: IShape;
+  Shape: TShape;
+begin
+  I := Sum(1) + Sum(1, 2) div 2;
+  J := Length(Sum('a', 'b'));
+  Swap(I, J);
+  Shape := TSquare.Create('square');
+  S := Shape;
+  Shape := Self;
+  with Shape do
+    Name := 'x' + Name;
+  if (I > 0) and not (J = 0) then
+    Result := Squared(Side) / I
+  else
+    Result := inherited Area;
+  for I := 0 to J - 1 do
+  begin
+    var K := I mod 2;
+    Inc(K);
+  end;
+  try
+    Shape.Free;
+  except
+    on E: TObject do
+      raise;
+  end;
+  if I in [1, 2..3] then
+    Exit;
+  WriteLn(Sum(1, I):10, TPoint(S).X);
+end;
+end.`)
+	check.Eq(t, len(errs), 0)
+}
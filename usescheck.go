@@ -0,0 +1,299 @@
+package pas
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UsesHint is an entry of a uses clause that is not needed where it is.
+type UsesHint struct {
+	// Unit is the name of the unit with the uses clause.
+	Unit string
+	// Section is the section with the uses clause.
+	Section FileSectionKind
+	// Uses is the entry as written in the uses clause.
+	Uses string
+	// Move is true for an entry in the interface section whose unit is only
+	// referenced in the implementation section, so it can move there. If Move
+	// is false, nothing of the used unit is referenced at all.
+	Move bool
+}
+
+func (h UsesHint) String() string {
+	if h.Move {
+		return fmt.Sprintf("%s: %s can move from the interface to the implementation uses clause", h.Unit, h.Uses)
+	}
+	return fmt.Sprintf("%s: %s in the %s uses clause is not used", h.Unit, h.Uses, h.Section)
+}
+
+// CheckUses finds the entries in the uses clauses of the files that are not
+// used, and the entries of interface uses clauses that are only used in the
+// implementation section. Used units are looked up by name among the files,
+// use Project.CheckUses to resolve them like the compiler.
+//
+// A used unit counts as used if one of the types, constants, variables,
+// routines or attributes that a unit references is declared in the interface
+// section of the used unit. Like in Delphi, the last unit in the uses clauses
+// that declares a name is the one that is used. Routine bodies, constant values
+// and the statements of initialization and finalization sections are not
+// parsed, every identifier in them counts as a reference, except for the names
+// after a dot, like Caption in Form.Caption. These units are not
+// reported:
+//
+//     - units that are not among the files, e.g. RTL units, because it is
+//       unknown what they declare
+//     - units with initialization or finalization sections, because they
+//       might be used only for these
+func CheckUses(files []*File) []UsesHint {
+	byName := make(map[string]*File)
+	for _, f := range files {
+		if _, ok := byName[FoldIdentifier(f.Name)]; !ok {
+			byName[FoldIdentifier(f.Name)] = f
+		}
+	}
+	return checkUses(files, func(name string) *File {
+		return byName[FoldIdentifier(name)]
+	})
+}
+
+// CheckUses finds uses clause entries that are not needed in the project's
+// units, see the package function CheckUses. Used units are resolved like in
+// Project.Resolve.
+func (p *Project) CheckUses() []UsesHint {
+	var files []*File
+	for _, u := range p.Units {
+		files = append(files, u.File)
+	}
+	return checkUses(files, func(name string) *File {
+		if u := p.Unit(name); u != nil {
+			return u.File
+		}
+		return nil
+	})
+}
+
+func checkUses(files []*File, resolve func(string) *File) []UsesHint {
+	var hints []UsesHint
+	for _, f := range files {
+		hints = append(hints, checkFileUses(f, resolve)...)
+	}
+	return hints
+}
+
+// usedUnit is an entry of a uses clause with the unit that it resolves to.
+type usedUnit struct {
+	name    string
+	section FileSectionKind
+	file    *File
+	// exports are the lower case names that the unit's interface declares.
+	exports map[string]bool
+	// usedIn are the sections that reference the unit.
+	usedIn map[FileSectionKind]bool
+}
+
+func checkFileUses(f *File, resolve func(string) *File) []UsesHint {
+	var uses []*usedUnit
+	for _, s := range f.Sections {
+		for _, name := range s.Uses {
+			u := &usedUnit{
+				name:    name,
+				section: s.Kind,
+				file:    resolve(name),
+				usedIn:  make(map[FileSectionKind]bool),
+			}
+			if u.file != nil {
+				u.exports = declaredNames(u.file, InterfaceSection)
+			}
+			uses = append(uses, u)
+		}
+	}
+
+	local := make(map[string]bool)
+	for _, s := range f.Sections {
+		for name := range declaredNames(f, s.Kind) {
+			local[name] = true
+		}
+		// References in the implementation section see both uses clauses,
+		// those in the interface section only the interface uses clause.
+		var visible []*usedUnit
+		for _, u := range uses {
+			if u.section == InterfaceSection || s.Kind != InterfaceSection {
+				visible = append(visible, u)
+			}
+		}
+		for _, ref := range referencedNames(s) {
+			for _, name := range ref {
+				u, found := resolveReference(name, local, visible)
+				if u != nil {
+					u.usedIn[s.Kind] = true
+				}
+				if found {
+					break
+				}
+			}
+		}
+	}
+
+	var hints []UsesHint
+	for _, u := range uses {
+		if u.file == nil || len(u.usedIn) == 0 && hasInitialization(u.file) {
+			continue
+		}
+		if len(u.usedIn) == 0 {
+			hints = append(hints, UsesHint{Unit: f.Name, Section: u.section, Uses: u.name})
+		} else if u.section == InterfaceSection && !u.usedIn[InterfaceSection] {
+			hints = append(hints, UsesHint{Unit: f.Name, Section: u.section, Uses: u.name, Move: true})
+		}
+	}
+	return hints
+}
+
+// resolveReference returns the used unit that declares the referenced name. It
+// returns nil if the name is declared locally, found is true in that case. A
+// name qualified with a unit name, like "Vcl.Forms.TForm", references that
+// unit.
+func resolveReference(ref string, local map[string]bool, uses []*usedUnit) (u *usedUnit, found bool) {
+	lower := FoldIdentifier(ref)
+	for i := len(uses) - 1; i >= 0; i-- {
+		u := uses[i]
+		if strings.HasPrefix(lower, FoldIdentifier(u.name)+".") ||
+			u.file != nil && strings.HasPrefix(lower, FoldIdentifier(u.file.Name)+".") {
+			return u, true
+		}
+	}
+	// Otherwise the first part is the name, e.g. TOuter in TOuter.TInner.
+	if i := strings.IndexByte(lower, '.'); i != -1 {
+		lower = lower[:i]
+	}
+	if local[lower] {
+		return nil, true
+	}
+	for i := len(uses) - 1; i >= 0; i-- {
+		if uses[i].exports[lower] {
+			return uses[i], true
+		}
+	}
+	return nil, false
+}
+
+// declaredNames returns the lower case names of the types, constants, variables
+// and routines that the file declares in the given section.
+func declaredNames(f *File, kind FileSectionKind) map[string]bool {
+	names := make(map[string]bool)
+	for _, s := range f.Sections {
+		if s.Kind != kind {
+			continue
+		}
+		for _, b := range s.Blocks {
+			switch b := b.(type) {
+			case TypeBlock:
+				for _, t := range b {
+					if c, ok := t.(Class); ok {
+						names[FoldIdentifier(c.Name)] = true
+					}
+				}
+			case VarBlock:
+				for _, v := range b {
+					names[FoldIdentifier(v.Name)] = true
+				}
+			case ConstBlock:
+				for _, c := range b {
+					names[FoldIdentifier(c.Name)] = true
+				}
+			case Routine:
+				if b.ClassName == "" {
+					names[FoldIdentifier(b.Header.Name)] = true
+				}
+			}
+		}
+	}
+	return names
+}
+
+// referencedNames returns the names of all types and attributes that are used
+// in the section and the identifiers in its constant values and routine
+// bodies. Each reference is a list of names to try in order, an attribute
+// [Name] refers to the class NameAttribute or, if there is none, to Name.
+func referencedNames(s FileSection) [][]string {
+	var names [][]string
+	add := func(name ...string) {
+		if name[0] != "" {
+			names = append(names, name)
+		}
+	}
+	Inspect(s, func(n Node) bool {
+		switch n := n.(type) {
+		case Class:
+			for _, super := range n.SuperClasses {
+				add(super)
+			}
+		case Variable:
+			add(n.Type)
+		case Function:
+			add(n.Returns)
+		case Property:
+			add(n.Type)
+		case Parameter:
+			add(n.Type)
+		case Attribute:
+			add(n.Name+"Attribute", n.Name)
+		case Constant:
+			add(n.Type)
+			for _, name := range codeNames(n.Value) {
+				add(name)
+			}
+		case Routine:
+			for _, name := range codeNames(n.Body) {
+				add(name)
+			}
+		}
+		return true
+	})
+	for _, name := range codeNames(s.Code) {
+		add(name)
+	}
+	return names
+}
+
+// codeNames returns the identifiers in the code, with the dotted names after
+// them, e.g. "Vcl.Forms.Application" and "Form.Caption". Keywords and the
+// names after other tokens and a dot, like Caption in Items[0].Caption, are
+// left out, they are members and not declared in a unit.
+func codeNames(code string) []string {
+	s := NewScanner(code)
+	s.SkipWhiteSpace = true
+	s.SkipComments = true
+	var names []string
+	afterDot := false
+	t := s.Scan()
+	for t.Kind != EOFToken {
+		if t.Kind != WordToken || afterDot || IsReserved(t.Text) {
+			afterDot = t.Text == "."
+			t = s.Scan()
+			continue
+		}
+		name := strings.TrimPrefix(t.Text, "&")
+		t = s.Scan()
+		for t.Text == "." {
+			next := s.Scan()
+			if next.Kind != WordToken {
+				t = next
+				break
+			}
+			name += "." + strings.TrimPrefix(next.Text, "&")
+			t = s.Scan()
+		}
+		names = append(names, name)
+		afterDot = false
+	}
+	return names
+}
+
+func hasInitialization(f *File) bool {
+	for _, s := range f.Sections {
+		if s.Kind == InitializationSection || s.Kind == FinalizationSection {
+			return true
+		}
+	}
+	return false
+}
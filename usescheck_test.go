@@ -0,0 +1,138 @@
+package pas_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/pas"
+)
+
+func TestCheckUsesFindsUnusedAndMovableUnits(t *testing.T) {
+	hints := pas.CheckUses(parseUnits(t, `unit Main;
+interface
+uses Base, Unused, Late, SysUtils;
+type TMain = class(TBase)
+end;
+implementation
+uses Impl, ImplUnused;
+type TPrivate = class
+  [Info] L: TLate;
+  I: TImpl;
+end;
+end.`,
+		"unit Base; interface type TBase = class end; implementation end.",
+		"unit Unused; interface var X: Integer; implementation end.",
+		"unit Late; interface type TLate = class end; type InfoAttribute = class end; implementation end.",
+		"unit Impl; interface type TImpl = class end; implementation end.",
+		"unit ImplUnused; interface implementation end.",
+	))
+	check.Eq(t, hints, []pas.UsesHint{
+		{Unit: "Main", Section: pas.InterfaceSection, Uses: "Unused"},
+		{Unit: "Main", Section: pas.InterfaceSection, Uses: "Late", Move: true},
+		{Unit: "Main", Section: pas.ImplementationSection, Uses: "ImplUnused"},
+	})
+	check.Eq(t, hints[0].String(), "Main: Unused in the interface uses clause is not used")
+	check.Eq(t, hints[1].String(), "Main: Late can move from the interface to the implementation uses clause")
+}
+
+func TestCheckUsesPicksLastUnitThatDeclaresName(t *testing.T) {
+	hints := pas.CheckUses(parseUnits(t,
+		"unit Main; interface uses A, B; var X: T; implementation end.",
+		"unit A; interface type T = class end; implementation end.",
+		"unit B; interface type T = class end; implementation end.",
+	))
+	check.Eq(t, hints, []pas.UsesHint{
+		{Unit: "Main", Section: pas.InterfaceSection, Uses: "A"},
+	})
+}
+
+func TestCheckUsesSeesReferencesInRoutineBodies(t *testing.T) {
+	hints := pas.CheckUses(parseUnits(t, `unit Main;
+interface
+uses Consts, Routines, Members;
+const Max = Limit * 2;
+implementation
+uses Forms, Vcl.Dialogs, Unused;
+procedure Run;
+var F: TObject;
+begin
+  // Unused.Hidden is only in a comment.
+  Show('Hidden');
+  Application.Run;
+  Vcl.Dialogs.ShowMessage(F.Free);
+  F.Caption := Items[0].Free;
+end;
+end.`,
+		"unit Consts; interface const Limit = 10; implementation end.",
+		"unit Routines; interface procedure Show(S: string); implementation end.",
+		"unit Members; interface var Free: Integer; Caption: Integer; implementation end.",
+		"unit Forms; interface var Application: TObject; implementation end.",
+		"unit Vcl.Dialogs; interface implementation end.",
+		"unit Unused; interface var Hidden: Integer; implementation end.",
+	))
+	check.Eq(t, hints, []pas.UsesHint{
+		{Unit: "Main", Section: pas.InterfaceSection, Uses: "Routines", Move: true},
+		{Unit: "Main", Section: pas.InterfaceSection, Uses: "Members"},
+		{Unit: "Main", Section: pas.ImplementationSection, Uses: "Unused"},
+	})
+}
+
+func TestCheckUsesKeepsUnitsWithInitialization(t *testing.T) {
+	hints := pas.CheckUses(parseUnits(t,
+		"unit Main; interface implementation uses Reg, Run; end.",
+		`unit Reg;
+interface
+implementation
+uses Classes, Forms;
+initialization
+  RegisterClass(TForm);
+end.`,
+		"unit Run; interface procedure Start; implementation end.",
+	))
+	check.Eq(t, hints, []pas.UsesHint{
+		{Unit: "Main", Section: pas.ImplementationSection, Uses: "Run"},
+	})
+}
+
+func TestCheckUsesSeesReferencesInInitialization(t *testing.T) {
+	hints := pas.CheckUses(parseUnits(t, `unit Main;
+interface
+uses Run, Unused;
+implementation
+initialization
+  Start;
+finalization
+  Stop;
+end.`,
+		"unit Run; interface procedure Start; procedure Stop; implementation end.",
+		"unit Unused; interface var Hidden: Integer; implementation end.",
+	))
+	check.Eq(t, hints, []pas.UsesHint{
+		{Unit: "Main", Section: pas.InterfaceSection, Uses: "Run", Move: true},
+		{Unit: "Main", Section: pas.InterfaceSection, Uses: "Unused"},
+	})
+}
+
+func TestCheckUsesHonorsQualifiedAndLocalNames(t *testing.T) {
+	hints := pas.CheckUses(parseUnits(t,
+		"unit Main; interface uses A, B; type T = class end; var X: A.T; Y: T; implementation end.",
+		"unit A; interface type T = class end; implementation end.",
+		"unit B; interface type T = class end; implementation end.",
+	))
+	check.Eq(t, hints, []pas.UsesHint{
+		{Unit: "Main", Section: pas.InterfaceSection, Uses: "B"},
+	})
+}
+
+func TestProjectCheckUsesResolvesUnitScopeNames(t *testing.T) {
+	dir := writeTestProject(t)
+	p, _ := pas.LoadProject(filepath.Join(dir, "P.dproj"))
+	// Main uses Helper in its interface and Tools in its implementation, but
+	// references nothing of them. Vcl.Dialogs is not part of the project.
+	check.Eq(t, p.CheckUses(), []pas.UsesHint{
+		{Unit: "Main", Section: pas.InterfaceSection, Uses: "Helper"},
+		{Unit: "Main", Section: pas.ImplementationSection, Uses: "Tools"},
+		{Unit: "Vcl.Tools", Section: pas.InterfaceSection, Uses: "Helper"},
+	})
+}
@@ -0,0 +1,172 @@
+package pas
+
+// Node is implemented by all nodes of the syntax tree.
+type Node interface {
+	// Span returns the position of the node's first character and the position
+	// right after its last character.
+	Span() (pos, end Position)
+}
+
+func (f File) Span() (pos, end Position)         { return f.Pos, f.End }
+func (s FileSection) Span() (pos, end Position)  { return s.Pos, s.End }
+func (c Class) Span() (pos, end Position)        { return c.Pos, c.End }
+func (s ClassSection) Span() (pos, end Position) { return s.Pos, s.End }
+func (v Variable) Span() (pos, end Position)     { return v.Pos, v.End }
+func (c Constant) Span() (pos, end Position)     { return c.Pos, c.End }
+func (f Function) Span() (pos, end Position)     { return f.Pos, f.End }
+func (p Property) Span() (pos, end Position)     { return p.Pos, p.End }
+func (p Parameter) Span() (pos, end Position)    { return p.Pos, p.End }
+func (a Attribute) Span() (pos, end Position)    { return a.Pos, a.End }
+func (r Routine) Span() (pos, end Position)      { return r.Pos, r.End }
+func (c Comment) Span() (pos, end Position)      { return c.Pos, c.End }
+
+func (g *CommentGroup) Span() (pos, end Position) {
+	if len(g.List) == 0 {
+		return
+	}
+	return g.List[0].Pos, g.List[len(g.List)-1].End
+}
+
+func (b TypeBlock) Span() (pos, end Position) {
+	if len(b) == 0 {
+		return
+	}
+	pos, _ = b[0].Span()
+	_, end = b[len(b)-1].Span()
+	return
+}
+
+func (b VarBlock) Span() (pos, end Position) {
+	if len(b) == 0 {
+		return
+	}
+	return b[0].Pos, b[len(b)-1].End
+}
+
+func (b ConstBlock) Span() (pos, end Position) {
+	if len(b) == 0 {
+		return
+	}
+	return b[0].Pos, b[len(b)-1].End
+}
+
+// A Visitor's Visit method is called for each node encountered by Walk. If the
+// result visitor w is not nil, Walk visits each of the children of node with w,
+// followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the syntax tree in depth-first order. It starts by calling
+// v.Visit(node). If the visitor w returned by v.Visit(node) is not nil, Walk is
+// called recursively with w for each of the non-nil children of node, followed
+// by a call of w.Visit(nil).
+//
+// Doc and trailing comments are visited as children of the nodes they belong
+// to, File.Comments is not walked.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *File:
+		walkSections(v, n.Sections)
+	case File:
+		walkSections(v, n.Sections)
+	case FileSection:
+		for _, b := range n.Blocks {
+			Walk(v, b)
+		}
+	case TypeBlock:
+		for _, d := range n {
+			Walk(v, d)
+		}
+	case VarBlock:
+		for _, d := range n {
+			Walk(v, d)
+		}
+	case ConstBlock:
+		for _, d := range n {
+			Walk(v, d)
+		}
+	case Class:
+		walkComment(v, n.Doc)
+		walkAttributes(v, n.Attributes)
+		for _, s := range n.Sections {
+			Walk(v, s)
+		}
+		walkComment(v, n.Comment)
+	case ClassSection:
+		for _, m := range n.Members {
+			Walk(v, m)
+		}
+	case Variable:
+		walkComment(v, n.Doc)
+		walkAttributes(v, n.Attributes)
+		walkComment(v, n.Comment)
+	case Constant:
+		walkComment(v, n.Doc)
+		walkAttributes(v, n.Attributes)
+		walkComment(v, n.Comment)
+	case Function:
+		walkComment(v, n.Doc)
+		walkAttributes(v, n.Attributes)
+		for _, p := range n.Parameters {
+			Walk(v, p)
+		}
+		walkComment(v, n.Comment)
+	case Property:
+		walkComment(v, n.Doc)
+		walkAttributes(v, n.Attributes)
+		for _, p := range n.Parameters {
+			Walk(v, p)
+		}
+		walkComment(v, n.Comment)
+	case Parameter:
+		walkAttributes(v, n.Attributes)
+	case Routine:
+		Walk(v, n.Header)
+	case *CommentGroup:
+		for _, c := range n.List {
+			Walk(v, c)
+		}
+	}
+
+	v.Visit(nil)
+}
+
+func walkSections(v Visitor, sections []FileSection) {
+	for _, s := range sections {
+		Walk(v, s)
+	}
+}
+
+func walkAttributes(v Visitor, attributes []Attribute) {
+	for _, a := range attributes {
+		Walk(v, a)
+	}
+}
+
+func walkComment(v Visitor, g *CommentGroup) {
+	if g != nil {
+		Walk(v, g)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the syntax tree in depth-first order. It starts by calling
+// f(node), node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of node, followed by a call of
+// f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,151 @@
+package pas_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/pas"
+)
+
+const walkCode = `unit U;
+interface
+type C = class
+  // Doc of A.
+  [Attr] A: Integer;
+public
+  procedure P([Attr2] X: Integer; var Y);
+end;
+var V: Integer;
+implementation
+end.`
+
+func TestInspectVisitsAllNodesInOrder(t *testing.T) {
+	f, err := pas.ParseString(walkCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nodes []string
+	pas.Inspect(f, func(n pas.Node) bool {
+		if n != nil {
+			nodes = append(nodes, nodeName(n))
+		}
+		return true
+	})
+	check.Eq(t, nodes, []string{
+		"File U",
+		"FileSection interface",
+		"TypeBlock",
+		"Class C",
+		"ClassSection 0",
+		"Variable A",
+		"CommentGroup",
+		"Comment // Doc of A.",
+		"Attribute Attr",
+		"ClassSection 2",
+		"Function P",
+		"Parameter X",
+		"Attribute Attr2",
+		"Parameter Y",
+		"VarBlock",
+		"Variable V",
+		"FileSection implementation",
+	})
+}
+
+func TestInspectCanSkipChildren(t *testing.T) {
+	f, err := pas.ParseString(walkCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nodes []string
+	pas.Inspect(f, func(n pas.Node) bool {
+		if n == nil {
+			return false
+		}
+		nodes = append(nodes, nodeName(n))
+		_, isClass := n.(pas.Class)
+		return !isClass
+	})
+	check.Eq(t, nodes, []string{
+		"File U",
+		"FileSection interface",
+		"TypeBlock",
+		"Class C",
+		"VarBlock",
+		"Variable V",
+		"FileSection implementation",
+	})
+}
+
+type depthCounter struct {
+	depth    int
+	maxDepth *int
+}
+
+func (c depthCounter) Visit(n pas.Node) pas.Visitor {
+	if n == nil {
+		return nil
+	}
+	if c.depth > *c.maxDepth {
+		*c.maxDepth = c.depth
+	}
+	return depthCounter{depth: c.depth + 1, maxDepth: c.maxDepth}
+}
+
+func TestWalkCallsVisitorPerLevel(t *testing.T) {
+	f, err := pas.ParseString(walkCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	maxDepth := 0
+	pas.Walk(depthCounter{maxDepth: &maxDepth}, f)
+	// File > FileSection > TypeBlock > Class > ClassSection > Variable >
+	// CommentGroup > Comment
+	check.Eq(t, maxDepth, 7)
+}
+
+func TestBlockSpans(t *testing.T) {
+	f, err := pas.ParseString(walkCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := f.Sections[0].Blocks[0]
+	pos, end := types.Span()
+	check.Eq(t, pos.String(), "3:6")
+	check.Eq(t, end.String(), "8:5")
+	vars := f.Sections[0].Blocks[1]
+	pos, end = vars.Span()
+	check.Eq(t, pos.String(), "9:5")
+	check.Eq(t, end.String(), "9:16")
+}
+
+func nodeName(n pas.Node) string {
+	switch n := n.(type) {
+	case *pas.File:
+		return "File " + n.Name
+	case pas.FileSection:
+		return "FileSection " + n.Kind.String()
+	case pas.TypeBlock:
+		return "TypeBlock"
+	case pas.VarBlock:
+		return "VarBlock"
+	case pas.Class:
+		return "Class " + n.Name
+	case pas.ClassSection:
+		return fmt.Sprint("ClassSection ", int(n.Visibility))
+	case pas.Variable:
+		return "Variable " + n.Name
+	case pas.Function:
+		return "Function " + n.Name
+	case pas.Parameter:
+		return "Parameter " + n.Names[0]
+	case pas.Attribute:
+		return "Attribute " + n.Name
+	case *pas.CommentGroup:
+		return "CommentGroup"
+	case pas.Comment:
+		return "Comment " + n.Text
+	}
+	return fmt.Sprintf("unknown %T", n)
+}
@@ -0,0 +1,117 @@
+package pas
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// XMLDoc is the content of an XML documentation comment, e.g.
+//
+//     /// <summary>Add returns the sum of two numbers.</summary>
+//     /// <param name="A">The first number.</param>
+//     /// <param name="B">The second number.</param>
+//     /// <returns>A + B</returns>
+//     function Add(A, B: Integer): Integer;
+//
+// White space in the texts is collapsed to single spaces.
+type XMLDoc struct {
+	Summary string
+	Params  []XMLDocParam
+	Returns string
+	Remarks string
+}
+
+type XMLDocParam struct {
+	Name string
+	Text string
+}
+
+// ParseXMLDoc parses the /// comments in the given group as XML documentation.
+// Other comments in the group are ignored. Unknown tags are skipped, the text
+// of nested tags like <c> or <b> is kept and references like
+// <see cref="TFoo"/> and <paramref name="A"/> are replaced by their names.
+func ParseXMLDoc(g *CommentGroup) (*XMLDoc, error) {
+	var text strings.Builder
+	text.WriteString("<doc>")
+	if g != nil {
+		for _, c := range g.List {
+			if strings.HasPrefix(c.Text, "///") {
+				text.WriteString(strings.TrimPrefix(c.Text, "///"))
+				text.WriteString("\n")
+			}
+		}
+	}
+	text.WriteString("</doc>")
+
+	d := xml.NewDecoder(strings.NewReader(text.String()))
+	var doc XMLDoc
+	// Skip the <doc> element that we wrapped around everything.
+	if _, err := d.Token(); err != nil {
+		return nil, err
+	}
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		s, err := xmlDocText(d)
+		if err != nil {
+			return nil, err
+		}
+		switch start.Name.Local {
+		case "summary":
+			doc.Summary = s
+		case "returns":
+			doc.Returns = s
+		case "remarks":
+			doc.Remarks = s
+		case "param":
+			doc.Params = append(doc.Params, XMLDocParam{
+				Name: xmlAttr(start, "name"),
+				Text: s,
+			})
+		}
+	}
+	return &doc, nil
+}
+
+// xmlDocText reads the text up to the end of the current element.
+func xmlDocText(d *xml.Decoder) (string, error) {
+	var s strings.Builder
+	depth := 1
+	for depth > 0 {
+		t, err := d.Token()
+		if err != nil {
+			return "", err
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			depth++
+			if name := xmlAttr(t, "cref") + xmlAttr(t, "name"); name != "" {
+				s.WriteString(name)
+			}
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			s.Write(t)
+		}
+	}
+	return strings.Join(strings.Fields(s.String()), " "), nil
+}
+
+func xmlAttr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,62 @@
+package pas_test
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/pas"
+)
+
+func TestParseXMLDoc(t *testing.T) {
+	f, err := pas.ParseString(`unit U;
+interface
+type C = class
+  /// <summary>
+  ///   Add returns the <b>sum</b> of <paramref name="A"/> and B.
+  /// </summary>
+  // This is not part of the XML.
+  /// <param name="A">The first number.</param>
+  /// <param name="B">The second number, see <see cref="TNumber"/>.</param>
+  /// <returns>A + B</returns>
+  /// <remarks>Overflows are not checked.</remarks>
+  /// <unknown>This is skipped.</unknown>
+  function Add(A, B: Integer): Integer;
+end;
+implementation
+end.`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	add := f.Sections[0].Blocks[0].(pas.TypeBlock)[0].(pas.Class).
+		Sections[0].Members[0].(pas.Function)
+	doc, err := pas.ParseXMLDoc(add.Doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check.Eq(t, doc, &pas.XMLDoc{
+		Summary: "Add returns the sum of A and B.",
+		Params: []pas.XMLDocParam{
+			{Name: "A", Text: "The first number."},
+			{Name: "B", Text: "The second number, see TNumber."},
+		},
+		Returns: "A + B",
+		Remarks: "Overflows are not checked.",
+	})
+}
+
+func TestParseEmptyXMLDoc(t *testing.T) {
+	doc, err := pas.ParseXMLDoc(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check.Eq(t, doc, &pas.XMLDoc{})
+}
+
+func TestParseInvalidXMLDoc(t *testing.T) {
+	_, err := pas.ParseXMLDoc(&pas.CommentGroup{List: []pas.Comment{
+		{Text: "/// <summary>unclosed"},
+	}})
+	if err == nil {
+		t.Fatal("error expected")
+	}
+}